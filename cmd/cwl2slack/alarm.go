@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/internal/cwl2slack"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+)
+
+// handleSNSはSNSで送信されたCloudWatchアラームの状態の変化をalarmモードで通知します。
+// アラームのメッセージでないレコードはログに出力して通知しません
+func handleSNS(ctx context.Context, event events.SNSEvent) (string, error) {
+	var errs []error
+	for _, r := range event.Records {
+		a, err := cwl2slack.ParseAlarm(r.SNS.Message)
+		if err != nil {
+			fmt.Printf("skipped sns message %s: %s\n", r.SNS.MessageID, err)
+			continue
+		}
+		if err := processAlarm(ctx, a, r.SNS.MessageID); err != nil {
+			errs = append(errs, fmt.Errorf("alarm %s: %w", a.AlarmName, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return "", err
+	}
+	return "cwl2slack executed successfully.", nil
+}
+
+// processAlarmはアラームの状態の変化を条件に一致する通知先に通知します。
+// ログと同じくMUTE_RULESに一致するアラームは通知せず、SEVERITY_CONFIGで重要度と表示を決めます
+func processAlarm(ctx context.Context, a *cwl2slack.Alarm, messageID string) error {
+	timeZone := os.Getenv("TIME_ZONE")
+	accountDirectory := os.Getenv("ACCOUNT_DIRECTORY")
+	severityConfig := os.Getenv("SEVERITY_CONFIG")
+
+	var loc *time.Location
+	if timeZone != "" {
+		var err error
+		loc, err = time.LoadLocation(timeZone)
+		if err != nil {
+			return fmt.Errorf("invalid TIME_ZONE: %w", err)
+		}
+	}
+	var accounts *cwl2slack.AccountDirectory
+	if accountDirectory != "" {
+		var err error
+		accounts, err = cwl2slack.ParseAccountDirectory(accountDirectory)
+		if err != nil {
+			return err
+		}
+	}
+	var sc *cwl2slack.SeverityConfig
+	if severityConfig != "" {
+		var err error
+		sc, err = cwl2slack.ParseSeverityConfig(severityConfig)
+		if err != nil {
+			return err
+		}
+	}
+	routes, err := loadRoutes()
+	if err != nil {
+		return err
+	}
+
+	// ミュートのルールに一致するアラームは通知せず、rollupのルールはアラーム名で件数を記録する
+	mutes, err := loadMutes(loc)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var notifications []notifier.Notification
+	if m, ok := cwl2slack.MuteAlarm(mutes.rules, a, now); ok {
+		fmt.Printf("muted alarm %s\n", a.AlarmName)
+		mutes.record(ctx, []cwl2slack.Muted{m}, a.AlarmName, cwl2slack.AlarmMode)
+	} else {
+		notifications = append(notifications, a.Notification(messageID, accounts, loc, sc))
+	}
+	suppressed := mutes.takeSuppressed(ctx, now)
+
+	account := accounts.Lookup(a.AWSAccountID)
+	destinations, err := buildDestinations(routes, func(r cwl2slack.Route) bool { return r.MatchAlarm(a, account) }, notifications, suppressed)
+	if err != nil {
+		mutes.restore(ctx, suppressed, nil)
+		return err
+	}
+	report, err := deliver(ctx, destinations, nil)
+	mutes.restore(ctx, suppressed, report)
+	return err
+}
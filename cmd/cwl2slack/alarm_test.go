@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// testAlarmEventはCloudWatchアラームの状態の変化を1つ持つSNSのイベントを返します
+func testAlarmEvent(messageID string, newState string, oldState string) events.SNSEvent {
+	message := fmt.Sprintf(`{"AlarmName":"api-5xx","AWSAccountId":"123456789012","NewStateValue":%q,"OldStateValue":%q,"NewStateReason":"Threshold Crossed","StateChangeTime":"2024-05-27T06:53:33.043+0000","AlarmArn":"arn:aws:cloudwatch:ap-northeast-1:123456789012:alarm:api-5xx","Trigger":{"MetricName":"HTTPCode_Target_5XX_Count","Namespace":"AWS/ApplicationELB","Statistic":"SUM","Period":60,"EvaluationPeriods":1,"ComparisonOperator":"GreaterThanThreshold","Threshold":10}}`, newState, oldState)
+	return events.SNSEvent{Records: []events.SNSEventRecord{
+		{EventSource: "aws:sns", SNS: events.SNSEntity{MessageID: messageID, Message: message}},
+	}}
+}
+
+func TestHandleEventAlarm(t *testing.T) {
+	// Web APIに送信したメッセージの色と返信先のスレッド
+	type posted struct {
+		color    string
+		threadTS string
+	}
+	var messages []posted
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			ThreadTS    string `json:"thread_ts"`
+			Attachments []struct {
+				Color string `json:"color"`
+			} `json:"attachments"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		messages = append(messages, posted{color: in.Attachments[0].Color, threadTS: in.ThreadTS})
+		fmt.Fprintf(w, `{"ok":true,"ts":"%d.000100"}`, len(messages))
+	}))
+	defer ts.Close()
+
+	t.Setenv("ROUTES", fmt.Sprintf(`[{"name":"alarms","type":"slack","token":"xoxb-test","channel":"C0123","url":%q,"modes":["alarm"],"rate_limit":-1},{"name":"logs","type":"slack","url":%q,"log_groups":["*"]}]`, ts.URL, ts.URL))
+
+	for _, event := range []events.SNSEvent{
+		testAlarmEvent("alarm-1", "ALARM", "OK"),
+		testAlarmEvent("alarm-2", "OK", "ALARM"),
+		// 再送されたメッセージは送信しない
+		testAlarmEvent("alarm-2", "OK", "ALARM"),
+		// アラームでないメッセージは通知しない
+		{Records: []events.SNSEventRecord{{EventSource: "aws:sns", SNS: events.SNSEntity{MessageID: "other", Message: "hello"}}}},
+	} {
+		raw, _ := json.Marshal(event)
+		if _, err := handleEvent(context.Background(), raw); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// OKは緑で、ALARMのメッセージのスレッドに返信する
+	want := []posted{{color: "danger"}, {color: "good", threadTS: "1.000100"}}
+	if len(messages) != len(want) {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Fatalf("message %d: got %+v, want %+v", i, messages[i], want[i])
+		}
+	}
+}
+
+func TestHandleEventAlarmMuteAndSeverity(t *testing.T) {
+	var posted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		posted = append(posted, string(b))
+	}))
+	defer ts.Close()
+
+	t.Setenv("ROUTES", fmt.Sprintf(`[{"name":"alarms","type":"slack","url":%q,"modes":["alarm"],"rate_limit":-1}]`, ts.URL))
+	t.Setenv("SEVERITY_CONFIG", `{"rules":[{"pattern":"5xx","severity":"critical"}],"colors":{"critical":"#000000"}}`)
+	// 状態が変化した時刻(2024-05-27T06:53:33Z)を含む期間はアラームを通知せず、rollupのルールは件数を記録する
+	t.Setenv("MUTE_RULES", `[
+		{"name":"deploy","alarms":["api-*"],"windows":[{"start":"2024-05-27T06:00:00Z","end":"2024-05-27T07:00:00Z"}],"action":"rollup"},
+		{"name":"db","alarms":["db-*"]}
+	]`)
+
+	raw, _ := json.Marshal(testAlarmEvent("alarm-muted-1", "ALARM", "OK"))
+	if _, err := handleEvent(context.Background(), raw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posted) != 0 {
+		t.Fatalf("unexpected posts: %v", posted)
+	}
+	key := fmt.Sprintf("deploy#%d", time.Date(2024, 5, 27, 6, 0, 0, 0, time.UTC).Unix())
+	if c, _ := muteMemoryStore.Take(context.Background(), key); c.Events != 1 || c.LogGroups[0] != "api-5xx" || c.Modes[0] != "alarm" {
+		t.Fatalf("unexpected count: %+v", c)
+	}
+
+	// ミュートしないアラームはSEVERITY_CONFIGの重要度の色で通知する
+	t.Setenv("MUTE_RULES", `[{"name":"db","alarms":["db-*"]}]`)
+	raw, _ = json.Marshal(testAlarmEvent("alarm-muted-2", "ALARM", "OK"))
+	if _, err := handleEvent(context.Background(), raw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posted) != 1 || !strings.Contains(posted[0], `"color":"#000000"`) {
+		t.Fatalf("unexpected posts: %v", posted)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/internal/cwl2slack"
+)
+
+// eventProbeはLambdaに渡されたイベントの種類を判定するための項目です。
+// encoding/jsonは項目名の大文字と小文字を区別しないため、RecordsはKinesisのRecordsとFirehoseのrecordsの両方に、
+// EventSourceはKinesisのeventSourceとSNSのEventSourceの両方に一致します
+type eventProbe struct {
+	AWSLogs           json.RawMessage `json:"awslogs"`
+	DeliveryStreamArn string          `json:"deliveryStreamArn"`
+	Records           []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+}
+
+// handleEventはイベントの種類を判定し、CloudWatch Logsのサブスクリプション、Kinesis Data Streams、
+// Kinesis Data Firehoseのデータ変換、CloudWatchアラームのSNS通知のいずれかとして処理します
+func handleEvent(ctx context.Context, raw json.RawMessage) (any, error) {
+	var probe eventProbe
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	switch {
+	case probe.DeliveryStreamArn != "":
+		var event events.KinesisFirehoseEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse firehose event: %w", err)
+		}
+		return handleFirehose(ctx, event), nil
+	case len(probe.Records) > 0 && probe.Records[0].EventSource == "aws:kinesis":
+		var event events.KinesisEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse kinesis event: %w", err)
+		}
+		return handleKinesis(ctx, event), nil
+	case len(probe.Records) > 0 && probe.Records[0].EventSource == "aws:sns":
+		var event events.SNSEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse sns event: %w", err)
+		}
+		return handleSNS(ctx, event)
+	case len(probe.AWSLogs) > 0:
+		var event events.CloudwatchLogsEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse cloudwatch logs event: %w", err)
+		}
+		return handler(ctx, event)
+	default:
+		return nil, errors.New("unsupported event")
+	}
+}
+
+// handleKinesisはKinesis Data Streamsのレコードごとに通知し、失敗したレコードをバッチアイテムの失敗として返します。
+// 解析できないレコードは再試行しても成功しないため、ログに出力して失敗に含めません
+func handleKinesis(ctx context.Context, event events.KinesisEvent) events.KinesisEventResponse {
+	var res events.KinesisEventResponse
+	for _, r := range event.Records {
+		id := r.Kinesis.SequenceNumber
+		data, err := decodeLogsData(r.Kinesis.Data)
+		if err != nil {
+			fmt.Printf("skipped kinesis record %s: %s\n", id, err)
+			continue
+		}
+		if err := processAll(ctx, data); err != nil {
+			fmt.Printf("kinesis record %s failed: %s\n", id, err)
+			res.BatchItemFailures = append(res.BatchItemFailures, events.KinesisBatchItemFailure{ItemIdentifier: id})
+		}
+	}
+	return res
+}
+
+// handleFirehoseはKinesis Data Firehoseのデータ変換のレコードごとに通知します。
+// 通知したレコードは元のデータのまま配信先に渡し、到達確認のメッセージのみのレコードは配信しません。
+// ProcessingFailedのレコードは配信先ではなくエラーの出力先に送られるため、解析できないレコードのみProcessingFailedにします。
+// 通知に失敗してもレコードは配信先に渡し、失敗した通知はDEAD_LETTERに保存します
+func handleFirehose(ctx context.Context, event events.KinesisFirehoseEvent) events.KinesisFirehoseResponse {
+	var res events.KinesisFirehoseResponse
+	for _, r := range event.Records {
+		rec := events.KinesisFirehoseResponseRecord{
+			RecordID: r.RecordID,
+			Result:   events.KinesisFirehoseTransformedStateOk,
+			Data:     r.Data,
+		}
+
+		data, err := decodeLogsData(r.Data)
+		switch {
+		case err != nil:
+			fmt.Printf("firehose record %s is invalid: %s\n", r.RecordID, err)
+			rec.Result = events.KinesisFirehoseTransformedStateProcessingFailed
+		case onlyControlMessages(data):
+			rec.Result = events.KinesisFirehoseTransformedStateDropped
+		default:
+			// 到達確認のメッセージはprocessで読み飛ばします
+			if err := processAll(ctx, data); err != nil {
+				fmt.Printf("firehose record %s failed to notify: %s\n", r.RecordID, err)
+			}
+		}
+		res.Records = append(res.Records, rec)
+	}
+	return res
+}
+
+// onlyControlMessagesは全てのデータが到達確認のメッセージの場合にtrueを返します
+func onlyControlMessages(data []events.CloudwatchLogsData) bool {
+	for i := range data {
+		if !cwl2slack.IsControlMessage(&data[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// processAllは全てのデータを通知し、失敗したデータのエラーをまとめて返します
+func processAll(ctx context.Context, data []events.CloudwatchLogsData) error {
+	var errs []error
+	for _, d := range data {
+		if _, err := process(ctx, d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeLogsDataはKinesisやFirehoseのレコードのデータをCloudWatch Logsのサブスクリプションのデータに変換します。
+// データはgzipで圧縮されたJSONで、Firehoseの解凍機能で解凍済みのJSONも扱います。複数のJSONが連結されている場合は全て返します
+func decodeLogsData(b []byte) ([]events.CloudwatchLogsData, error) {
+	var r io.Reader = bytes.NewReader(b)
+	if len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var data []events.CloudwatchLogsData
+	dec := json.NewDecoder(r)
+	for {
+		var d events.CloudwatchLogsData
+		if err := dec.Decode(&d); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty record")
+	}
+	return data, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// gzipLogsDataはCloudwatchLogsDataをKinesisのレコードと同じgzipで圧縮したJSONにします
+func gzipLogsData(t *testing.T, data ...events.CloudwatchLogsData) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, d := range data {
+		if err := json.NewEncoder(gz).Encode(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testLogsDataはメッセージを1つ持つplainモードのデータを返します
+func testLogsData(id string, message string) events.CloudwatchLogsData {
+	return events.CloudwatchLogsData{
+		MessageType: "DATA_MESSAGE",
+		Owner:       "123456789012",
+		LogGroup:    "/aws/lambda/test",
+		LogStream:   "stream",
+		LogEvents:   []events.CloudwatchLogsLogEvent{{ID: id, Timestamp: 1716792813043, Message: message}},
+	}
+}
+
+// setupSlackはメッセージにfailを含む場合に失敗するSlackのサーバーを起動し、通知先に設定します
+func setupSlack(t *testing.T) *[]string {
+	t.Helper()
+	var posted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(b), "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		posted = append(posted, string(b))
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Setenv("SLACK_WEBHOOK_URL", ts.URL)
+	t.Setenv("MODE", "plain")
+	t.Setenv("RATE_LIMIT", "-1")
+	return &posted
+}
+
+func TestHandleEventKinesis(t *testing.T) {
+	posted := setupSlack(t)
+
+	event := events.KinesisEvent{Records: []events.KinesisEventRecord{
+		{EventSource: "aws:kinesis", Kinesis: events.KinesisRecord{SequenceNumber: "1", Data: gzipLogsData(t, testLogsData("kinesis-1", "[ERROR] ok"))}},
+		{EventSource: "aws:kinesis", Kinesis: events.KinesisRecord{SequenceNumber: "2", Data: gzipLogsData(t, testLogsData("kinesis-2", "[ERROR] fail"))}},
+		{EventSource: "aws:kinesis", Kinesis: events.KinesisRecord{SequenceNumber: "3", Data: []byte("invalid")}},
+		{EventSource: "aws:kinesis", Kinesis: events.KinesisRecord{SequenceNumber: "4", Data: gzipLogsData(t, events.CloudwatchLogsData{MessageType: "CONTROL_MESSAGE"})}},
+	}}
+	raw, _ := json.Marshal(event)
+
+	got, err := handleEvent(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 通知に失敗したレコードのみ失敗とし、解析できないレコードと到達確認のメッセージは失敗に含めない
+	res, ok := got.(events.KinesisEventResponse)
+	if !ok || len(res.BatchItemFailures) != 1 || res.BatchItemFailures[0].ItemIdentifier != "2" {
+		t.Fatalf("unexpected response: %+v", got)
+	}
+	if len(*posted) != 1 {
+		t.Fatalf("unexpected number of posts: %d", len(*posted))
+	}
+}
+
+func TestHandleEventFirehose(t *testing.T) {
+	posted := setupSlack(t)
+	deadLetter := t.TempDir()
+	t.Setenv("DEAD_LETTER", deadLetter)
+
+	// 解凍済みのJSONと、複数のデータを連結したgzip
+	plain, _ := json.Marshal(testLogsData("firehose-1", "[ERROR] ok"))
+	event := events.KinesisFirehoseEvent{
+		DeliveryStreamArn: "arn:aws:firehose:ap-northeast-1:123456789012:deliverystream/logs",
+		Records: []events.KinesisFirehoseEventRecord{
+			{RecordID: "a", Data: plain},
+			{RecordID: "b", Data: gzipLogsData(t, testLogsData("firehose-2", "[ERROR] ok"), testLogsData("firehose-3", "[ERROR] fail"))},
+			{RecordID: "c", Data: gzipLogsData(t, events.CloudwatchLogsData{MessageType: "CONTROL_MESSAGE"})},
+			{RecordID: "d", Data: []byte("invalid")},
+			{RecordID: "e", Data: gzipLogsData(t, events.CloudwatchLogsData{MessageType: "CONTROL_MESSAGE"}, testLogsData("firehose-4", "[ERROR] ok"))},
+		},
+	}
+	raw, _ := json.Marshal(event)
+
+	got, err := handleEvent(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, ok := got.(events.KinesisFirehoseResponse)
+	if !ok || len(res.Records) != 5 {
+		t.Fatalf("unexpected response: %+v", got)
+	}
+	// 通知に失敗したレコードも配信先に渡し、到達確認のメッセージが先頭にあってもデータを通知する
+	want := map[string]string{
+		"a": events.KinesisFirehoseTransformedStateOk,
+		"b": events.KinesisFirehoseTransformedStateOk,
+		"c": events.KinesisFirehoseTransformedStateDropped,
+		"d": events.KinesisFirehoseTransformedStateProcessingFailed,
+		"e": events.KinesisFirehoseTransformedStateOk,
+	}
+	for _, r := range res.Records {
+		if r.Result != want[r.RecordID] {
+			t.Fatalf("record %s: got %s, want %s", r.RecordID, r.Result, want[r.RecordID])
+		}
+	}
+	// 元のデータのまま配信先に渡す
+	if !bytes.Equal(res.Records[0].Data, plain) {
+		t.Fatalf("data should not be changed: %s", res.Records[0].Data)
+	}
+	if len(*posted) != 3 {
+		t.Fatalf("unexpected number of posts: %d", len(*posted))
+	}
+	// 通知に失敗した通知はデッドレターに保存する
+	if entries, _ := os.ReadDir(deadLetter); len(entries) != 1 {
+		t.Fatalf("unexpected number of dead letters: %d", len(entries))
+	}
+}
+
+func TestHandleEventCloudwatchLogs(t *testing.T) {
+	posted := setupSlack(t)
+
+	rawData, err := ConvertToRawData(testLogsData("cloudwatch-1", "[ERROR] ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(events.CloudwatchLogsEvent{AWSLogs: rawData})
+
+	if _, err := handleEvent(context.Background(), raw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*posted) != 1 {
+		t.Fatalf("unexpected number of posts: %d", len(*posted))
+	}
+}
+
+func TestHandleEventUnsupported(t *testing.T) {
+	testCases := []struct {
+		name string
+		raw  string
+	}{
+		{name: "[異常系]SQSのイベント", raw: `{"Records":[{"eventSource":"aws:sqs"}]}`},
+		{name: "[異常系]空のイベント", raw: `{}`},
+		{name: "[異常系]JSONでない", raw: `awslogs`},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := handleEvent(context.Background(), json.RawMessage(tt.raw)); err == nil {
+				t.Fatalf("expected error, but got nil")
+			}
+		})
+	}
+}
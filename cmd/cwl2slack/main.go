@@ -5,30 +5,73 @@ import (
 	// "encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
+	// Lambdaの実行環境にタイムゾーンのデータベースが無い場合に備えて埋め込む
+	_ "time/tzdata"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/tomozo6/cwl2slack/internal/cwl2slack"
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+	"github.com/tomozo6/cwl2slack/pkg/deadletter"
+	"github.com/tomozo6/cwl2slack/pkg/dedup"
+	"github.com/tomozo6/cwl2slack/pkg/dispatch"
+	"github.com/tomozo6/cwl2slack/pkg/mute"
 	"github.com/tomozo6/cwl2slack/pkg/myutil"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/ratelimit"
 	"github.com/tomozo6/cwl2slack/pkg/slack"
+	"github.com/tomozo6/cwl2slack/pkg/thread"
 )
 
+// memoryStoreはDEDUP_TABLEが設定されていない場合に送信済みの通知を記録するストアです。
+// Lambdaの実行環境が再利用される間は記録が残ります
+var memoryStore = dedup.NewMemoryStore()
+
+// threadMemoryStoreはTHREAD_TABLEが設定されていない場合に、Slackのスレッドの親メッセージを記録するストアです
+var threadMemoryStore = thread.NewMemoryStore()
+
+// muteMemoryStoreはMUTE_TABLEが設定されていない場合に、通知しなかったログイベントの件数を記録するストアです
+var muteMemoryStore = mute.NewMemoryStore()
+
 func handler(ctx context.Context, event events.CloudwatchLogsEvent) (string, error) {
+	// 与えられたイベントをパースする
+	cwld, err := event.AWSLogs.Parse()
+	if err != nil {
+		return "", err
+	}
+	return process(ctx, cwld)
+}
+
+// processはCloudWatch Logsのサブスクリプションのデータを通知します
+func process(ctx context.Context, cwld events.CloudwatchLogsData) (string, error) {
 	// 環境変数の設定
-	slackURL := os.Getenv("SLACK_WEBHOOK_URL")
-	slackChannel := os.Getenv("SLACK_CHANNEL")
 	mode := os.Getenv("MODE")
 	threshold := os.Getenv("THRESHOLD")
+	criticalThreshold := os.Getenv("CRITICAL_THRESHOLD")
+	frameworkPrefixes := os.Getenv("FRAMEWORK_PREFIXES")
+	correlationKey := os.Getenv("CORRELATION_KEY")
+	region := os.Getenv("AWS_REGION")
+	insightsWindow := os.Getenv("INSIGHTS_WINDOW")
+	timeZone := os.Getenv("TIME_ZONE")
+	ingestionDelayThreshold := os.Getenv("INGESTION_DELAY_THRESHOLD")
+	severityConfig := os.Getenv("SEVERITY_CONFIG")
+	onCallSchedule := os.Getenv("ONCALL_SCHEDULE")
+	accountDirectory := os.Getenv("ACCOUNT_DIRECTORY")
+	logControlMessages := os.Getenv("LOG_CONTROL_MESSAGES") == "true"
 
 	t, err := myutil.StrconvParseFloat(threshold, 64)
 	if err != nil {
 		return "", err
 	}
 
-	// 与えられたイベントをパースする
-	cwld, err := event.AWSLogs.Parse()
-	if err != nil {
-		return "", err
+	// サブスクリプションの作成時の到達確認のメッセージは通知しない
+	if cwl2slack.IsControlMessage(&cwld) {
+		if logControlMessages {
+			fmt.Printf("control message received: log group %s, subscription filters %v\n", cwld.LogGroup, cwld.SubscriptionFilters)
+		}
+		return "cwl2slack acknowledged a control message.", nil
 	}
 
 	// cwl2slackインスタンスの作成
@@ -36,32 +79,355 @@ func handler(ctx context.Context, event events.CloudwatchLogsEvent) (string, err
 	if err != nil {
 		return "", err
 	}
+	// slowqueryモードで重要度をcriticalにするクエリ実行時間の閾値
+	if criticalThreshold != "" {
+		c.CriticalThreshold, err = myutil.StrconvParseFloat(criticalThreshold, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid CRITICAL_THRESHOLD: %w", err)
+		}
+	}
+	// 重要度の判定方法(キーワード、JSONのレベルの項目、クエリ実行時間の範囲)と、重要度ごとの色、絵文字、メンション
+	if severityConfig != "" {
+		c.SeverityConfig, err = cwl2slack.ParseSeverityConfig(severityConfig)
+		if err != nil {
+			return "", err
+		}
+	}
+	// ログを担当するチームと、チームのオンコールのスケジュール(JSONファイルのパス)
+	if onCallSchedule != "" {
+		c.OnCall, err = cwl2slack.LoadOnCallConfig(onCallSchedule)
+		if err != nil {
+			return "", err
+		}
+	}
+	// AWSアカウントIDごとの名前、環境と、環境ごとの色、絵文字
+	if accountDirectory != "" {
+		c.Accounts, err = cwl2slack.ParseAccountDirectory(accountDirectory)
+		if err != nil {
+			return "", err
+		}
+	}
+	c.FrameworkPrefixes = myutil.StringsSplit(frameworkPrefixes, ",")
+	c.Region = region
 
-	// slackインスタンスの作成
-	s := slack.Slack{
-		URL:     slackURL,
-		Channel: slackChannel,
+	// Logs Insightsのリンクに含める前後の時間(例: 10m)
+	if insightsWindow != "" {
+		c.InsightsWindow, err = time.ParseDuration(insightsWindow)
+		if err != nil {
+			return "", fmt.Errorf("invalid INSIGHTS_WINDOW: %w", err)
+		}
+	}
+
+	// ログイベントの時刻を表示するタイムゾーン(例: Asia/Tokyo)
+	if timeZone != "" {
+		c.Location, err = time.LoadLocation(timeZone)
+		if err != nil {
+			return "", fmt.Errorf("invalid TIME_ZONE: %w", err)
+		}
 	}
 
-	// Slack通知に必要なペイロードを取得
-	payloads, err := c.GetSlackPayloads()
+	// 取り込み遅延が閾値(例: 5m)を超えた場合に通知に表示する
+	c.InvokedAt = time.Now()
+	if ingestionDelayThreshold != "" {
+		c.IngestionDelayThreshold, err = time.ParseDuration(ingestionDelayThreshold)
+		if err != nil {
+			return "", fmt.Errorf("invalid INGESTION_DELAY_THRESHOLD: %w", err)
+		}
+	}
+
+	// 相関キーが設定されている場合は同じキーを持つログイベントを1つの通知にまとめる
+	if correlationKey != "" {
+		c.CorrelationKey, err = cwl2slack.NewCorrelationKey(correlationKey)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// 通知先の設定
+	routes, err := loadRoutes()
+	if err != nil {
+		return "", err
+	}
+
+	// ミュートのルールに一致するログイベントは通知しない
+	// rollupのルールは件数を記録し、期間の終了後に件数を通知する
+	mutes, err := loadMutes(c.Location)
+	if err != nil {
+		return "", err
+	}
+	if muted := c.Mute(mutes.rules); len(muted) > 0 {
+		fmt.Printf("muted %d log events\n", len(muted))
+		mutes.record(ctx, muted, c.Cwld.LogGroup, c.Mode)
+	}
+	suppressed := mutes.takeSuppressed(ctx, c.InvokedAt)
+
+	// 通知の内容を取得
+	notifications, err := c.GetNotifications()
+	if err != nil {
+		mutes.restore(ctx, suppressed, nil)
+		return "", err
+	}
+
+	// 条件に一致する通知先と通知の組み合わせを作成
+	destinations, err := buildDestinations(routes, func(r cwl2slack.Route) bool { return r.Match(c) }, notifications, suppressed)
 	if err != nil {
+		mutes.restore(ctx, suppressed, nil)
 		return "", err
 	}
 
-	// Slack通知
-	for _, p := range *payloads {
-		err = s.SendNotification(p)
+	report, err := deliver(ctx, destinations, &cwld)
+	mutes.restore(ctx, suppressed, report)
+	if err != nil {
+		return "", err
+	}
+	return "cwl2slack executed successfully.", nil
+}
+
+// mutesはミュートのルールと、rollupのルールで通知しなかった件数を記録するストアです
+type mutes struct {
+	rules []cwl2slack.MuteRule
+	store mute.Store
+	loc   *time.Location
+}
+
+// loadMutesはMUTE_RULESからミュートのルールを読み込みます。locは繰り返す期間と件数の通知のタイムゾーンです。
+// MUTE_TABLEが設定されていればDynamoDBのテーブルに、設定されていなければメモリに件数を記録する
+func loadMutes(loc *time.Location) (*mutes, error) {
+	region := os.Getenv("AWS_REGION")
+	muteRulesJSON := os.Getenv("MUTE_RULES")
+	muteTable := os.Getenv("MUTE_TABLE")
+
+	m := &mutes{store: muteMemoryStore, loc: loc}
+	if muteRulesJSON != "" {
+		var err error
+		m.rules, err = cwl2slack.ParseMuteRules(muteRulesJSON, loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if muteTable != "" {
+		m.store = &mute.DynamoDBStore{Client: awsapi.NewClient(region), Table: muteTable}
+	}
+	return m, nil
+}
+
+// recordはrollupのルールで通知しなかった件数を記録する。記録できなくても通知は続ける
+func (m *mutes) record(ctx context.Context, muted []cwl2slack.Muted, source string, mode string) {
+	if err := cwl2slack.RecordMuted(ctx, m.store, muted, source, mode); err != nil {
+		fmt.Printf("failed to record muted events of %s: %s\n", source, err)
+	}
+}
+
+// takeSuppressedはnowまでに終了した期間の件数を記録から取り出し、件数の通知を返す
+func (m *mutes) takeSuppressed(ctx context.Context, now time.Time) []cwl2slack.Suppressed {
+	suppressed, err := cwl2slack.TakeSuppressed(ctx, m.store, m.rules, now, m.loc)
+	if err != nil {
+		fmt.Printf("failed to take suppressed log events: %s\n", err)
+	}
+	return suppressed
+}
+
+// restoreは件数の通知のうち、送信に失敗してデッドレターにも保存できなかったものを記録に戻し、次の呼び出しで通知する。
+// reportがnilの場合は送信していないため全てを戻す
+func (m *mutes) restore(ctx context.Context, suppressed []cwl2slack.Suppressed, report *dispatch.Report) {
+	if err := cwl2slack.RestoreSuppressed(ctx, m.store, undelivered(suppressed, report)); err != nil {
+		fmt.Printf("failed to restore suppressed log events: %s\n", err)
+	}
+}
+
+// undeliveredは件数の通知のうち、送信に失敗してデッドレターにも保存できなかったものを返します。
+// reportがnilの場合は送信していないため全てを返します
+func undelivered(suppressed []cwl2slack.Suppressed, report *dispatch.Report) []cwl2slack.Suppressed {
+	if report == nil {
+		return suppressed
+	}
+	failed := map[string]bool{}
+	for _, r := range report.Failed() {
+		if !r.DeadLettered && len(r.Notification.EventIDs) > 0 {
+			failed[r.Notification.EventIDs[0]] = true
+		}
+	}
+	var res []cwl2slack.Suppressed
+	for _, s := range suppressed {
+		if failed[s.Notification.EventIDs[0]] {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// buildDestinationsは通知先ごとに、matchが一致する通知先には重要度が一致する通知を、
+// 条件に一致する通知先には件数の通知を割り当てます。通知が無い通知先は含めません。
+// 通知先ごとに送信間隔を制限し、Lambdaの残り時間が足りない場合は残りの通知をまとめて送信する
+func buildDestinations(routes []cwl2slack.Route, match func(cwl2slack.Route) bool, notifications []notifier.Notification, suppressed []cwl2slack.Suppressed) ([]dispatch.Destination, error) {
+	region := os.Getenv("AWS_REGION")
+
+	var destinations []dispatch.Destination
+	for _, r := range routes {
+		d := dispatch.Destination{Name: r.Name}
+		if match(r) {
+			for _, n := range notifications {
+				if r.MatchSeverity(n.Severity) {
+					d.Notifications = append(d.Notifications, n)
+				}
+			}
+		}
+		for _, s := range suppressed {
+			if r.MatchSuppressed(s) && r.MatchSeverity(s.Notification.Severity) {
+				d.Notifications = append(d.Notifications, s.Notification)
+			}
+		}
+		if len(d.Notifications) == 0 {
+			continue
+		}
+
+		n, err := newNotifier(r, region)
 		if err != nil {
-			return "", fmt.Errorf("slack notification failed: %s", err)
+			return nil, err
+		}
+		rate, burst := r.Limit()
+		d.Notifier = ratelimit.New(n, rate, burst)
+		destinations = append(destinations, d)
+	}
+	return destinations, nil
+}
 
+// deliverは通知先に通知を送信して送信結果を返し、DELIVERY_POLICYに従って全体が失敗の場合にエラーを返します。
+// dataはデッドレターに通知と一緒に保存する、通知の元になったデータです。送信する前に失敗した場合の送信結果はnilです
+func deliver(ctx context.Context, destinations []dispatch.Destination, data *events.CloudwatchLogsData) (*dispatch.Report, error) {
+	region := os.Getenv("AWS_REGION")
+	deliveryPolicy := os.Getenv("DELIVERY_POLICY")
+	deadLetterURL := os.Getenv("DEAD_LETTER")
+
+	// 全体を成功とみなす方針(all, any, critical-only)
+	policy, err := dispatch.ParsePolicy(deliveryPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DELIVERY_POLICY: %w", err)
+	}
+
+	// 送信済みの通知を記録するストアと、送信に失敗した通知を保存するデッドレター
+	d, err := newDispatcher(region)
+	if err != nil {
+		return nil, err
+	}
+	if deadLetterURL != "" {
+		d.DeadLetter, err = deadletter.Open(deadLetterURL, awsapi.NewClient(region))
+		if err != nil {
+			return nil, err
 		}
+		d.Data = data
 	}
 
-	return "cwl2slack executed successfully.", nil
+	// 途中で失敗しても全ての通知先に送信し、失敗した通知をログに出力する
+	// 再送されたログイベントで送信済みの通知は送信しない
+	// デッドレターに保存した通知は失敗に含めないため、CloudWatch Logsによる再送が繰り返されない
+	report := d.Dispatch(ctx, destinations)
+	for _, r := range report.Results {
+		if r.Skipped || r.StoreErr != nil {
+			fmt.Printf("notification deduplication: %s\n", r)
+		}
+	}
+	for _, r := range report.Failed() {
+		fmt.Printf("notification failed: %s\n", r)
+	}
+	return report, report.Err(policy)
+}
+
+// newNotifierは通知先のNotifierを返します。Slackの上限を超えて切り詰めたペイロードはログに出力する。
+// SlackのWeb APIで送信する場合は、THREAD_TABLEが設定されていればDynamoDBのテーブルに、
+// 設定されていなければメモリにスレッドの親メッセージを記録する
+func newNotifier(r cwl2slack.Route, region string) (notifier.Notifier, error) {
+	n, err := r.NewNotifier()
+	if err != nil {
+		return nil, err
+	}
+	logFixed := func(err *slack.ValidationError) {
+		fmt.Printf("route %s: fixed Slack payload: %s\n", r.Name, err)
+	}
+	if s, ok := n.(*notifier.Slack); ok {
+		s.Fixed = logFixed
+	}
+	if s, ok := n.(*notifier.SlackAPI); ok {
+		s.Fixed = logFixed
+		s.Threads = threadMemoryStore
+		if threadTable := os.Getenv("THREAD_TABLE"); threadTable != "" {
+			s.Threads = &thread.DynamoDBStore{Client: awsapi.NewClient(region), Table: threadTable}
+		}
+	}
+	return n, nil
+}
+
+// loadRoutesは環境変数から通知先の設定を返します。
+// ROUTESが設定されていない場合はSLACK_WEBHOOK_URLとSLACK_CHANNELのSlackに通知する
+func loadRoutes() ([]cwl2slack.Route, error) {
+	if routesJSON := os.Getenv("ROUTES"); routesJSON != "" {
+		return cwl2slack.ParseRoutes(routesJSON)
+	}
+	r := cwl2slack.Route{
+		Name:    "slack",
+		Type:    "slack",
+		URL:     os.Getenv("SLACK_WEBHOOK_URL"),
+		Channel: os.Getenv("SLACK_CHANNEL"),
+		Format:  os.Getenv("MESSAGE_FORMAT"),
+		Strict:  os.Getenv("PAYLOAD_STRICT") == "true",
+	}
+
+	// 1秒あたりの送信数の上限(例: 0.5)と、続けて送信できる数
+	if rateLimit := os.Getenv("RATE_LIMIT"); rateLimit != "" {
+		rate, err := myutil.StrconvParseFloat(rateLimit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMIT: %w", err)
+		}
+		r.RateLimit = rate
+	}
+	if rateBurst := os.Getenv("RATE_BURST"); rateBurst != "" {
+		burst, err := strconv.Atoi(rateBurst)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_BURST: %w", err)
+		}
+		r.Burst = burst
+	}
+	return []cwl2slack.Route{r}, nil
+}
+
+// newDispatcherは環境変数の設定で送信済みの通知を記録し、送信先ごとに並行して送信するDispatcherを返します。
+// DEDUP_TABLEが設定されている場合はDynamoDBのテーブルに、設定されていない場合はメモリに記録する
+func newDispatcher(region string) (*dispatch.Dispatcher, error) {
+	d := &dispatch.Dispatcher{Store: memoryStore}
+	if dedupTable := os.Getenv("DEDUP_TABLE"); dedupTable != "" {
+		d.Store = &dedup.DynamoDBStore{Client: awsapi.NewClient(region), Table: dedupTable}
+	}
+
+	// 送信済みの記録を保持する期間(例: 24h)
+	if dedupTTL := os.Getenv("DEDUP_TTL"); dedupTTL != "" {
+		ttl, err := time.ParseDuration(dedupTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEDUP_TTL: %w", err)
+		}
+		d.TTL = ttl
+	}
+
+	// 並行して送信する送信先の数
+	if workers := os.Getenv("DELIVERY_WORKERS"); workers != "" {
+		n, err := strconv.Atoi(workers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELIVERY_WORKERS: %w", err)
+		}
+		d.Workers = n
+	}
+	return d, nil
 }
 
 func main() {
+	// cwl2slack redrive: デッドレターに保存した通知を再送する
+	if len(os.Args) > 1 && os.Args[1] == "redrive" {
+		if err := redrive(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Println("Hello, World!")
-	lambda.Start(handler)
+	lambda.Start(handleEvent)
 }
@@ -6,11 +6,19 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/joho/godotenv"
+	"github.com/tomozo6/cwl2slack/internal/cwl2slack"
+	"github.com/tomozo6/cwl2slack/pkg/mute"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
 )
 
 // テストだけで使用する関数
@@ -235,3 +243,156 @@ func TestSlowQueryMode(t *testing.T) {
 // func TestMain(t *testing.T) {
 // main()
 // }
+
+func TestHandlerControlMessage(t *testing.T) {
+	// 到達確認のメッセージは通知しない
+	posted := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = true
+	}))
+	defer ts.Close()
+	t.Setenv("SLACK_WEBHOOK_URL", ts.URL)
+	t.Setenv("MODE", "slowquery")
+	t.Setenv("LOG_CONTROL_MESSAGES", "true")
+
+	rawData, err := ConvertToRawData(events.CloudwatchLogsData{
+		Owner:               "CloudwatchLogs",
+		LogGroup:            "",
+		LogStream:           "",
+		SubscriptionFilters: []string{""},
+		MessageType:         "CONTROL_MESSAGE",
+		LogEvents:           []events.CloudwatchLogsLogEvent{{ID: "", Timestamp: 1716792813043, Message: "CWL CONTROL MESSAGE: Checking health of destination Kinesis stream."}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := handler(context.Background(), events.CloudwatchLogsEvent{AWSLogs: rawData}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if posted {
+		t.Fatalf("control message should not be posted")
+	}
+}
+
+func TestProcessRestoresSuppressed(t *testing.T) {
+	ctx := context.Background()
+	fail := true
+	var posted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		posted = append(posted, string(b))
+	}))
+	defer ts.Close()
+	t.Setenv("SLACK_WEBHOOK_URL", ts.URL)
+	t.Setenv("MODE", "plain")
+	t.Setenv("RATE_LIMIT", "-1")
+
+	// 1時間前に終了したrollupの期間に、通知しなかったログイベントが3件あります
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	rule := cwl2slack.MuteRule{Name: "restore-test"}
+	window := cwl2slack.MuteWindow{Start: start, End: start.Add(time.Hour)}
+	rules, _ := json.Marshal([]map[string]any{{"name": rule.Name, "windows": []cwl2slack.MuteWindow{window}, "action": "rollup"}})
+	t.Setenv("MUTE_RULES", string(rules))
+	key := rule.MuteKey(window)
+	muteMemoryStore.Add(ctx, key, mute.Count{Events: 3, LogGroups: []string{"/aws/lambda/test"}, Modes: []string{"plain"}}, cwl2slack.MutedRetention)
+
+	// 通知に失敗した場合は件数を記録に戻します
+	data := events.CloudwatchLogsData{
+		LogGroup:  "/aws/lambda/other",
+		LogStream: "stream",
+		LogEvents: []events.CloudwatchLogsLogEvent{{ID: "restore-1", Timestamp: time.Now().UnixMilli(), Message: "error"}},
+	}
+	if _, err := process(ctx, data); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+	got, _ := muteMemoryStore.Take(ctx, key)
+	if got.Events != 3 {
+		t.Fatalf("suppressed count was not restored: %+v", got)
+	}
+	muteMemoryStore.Add(ctx, key, got, cwl2slack.MutedRetention)
+
+	// 再び呼び出された場合に件数を通知し、記録から削除します
+	fail = false
+	if _, err := process(ctx, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posted) != 2 || !strings.Contains(strings.Join(posted, "\n"), "3 events suppressed during restore-test") {
+		t.Fatalf("unexpected posted: %v", posted)
+	}
+	if got, _ := muteMemoryStore.Take(ctx, key); got.Events != 0 {
+		t.Fatalf("suppressed count should be taken: %+v", got)
+	}
+}
+
+func TestBuildDestinations(t *testing.T) {
+	routes := []cwl2slack.Route{
+		{Name: "all", URL: "https://example.com/all"},
+		{Name: "critical", URL: "https://example.com/critical", MinSeverity: "critical"},
+		{Name: "other", URL: "https://example.com/other", LogGroups: []string{"/aws/lambda/other"}},
+	}
+	notifications := []notifier.Notification{
+		{Severity: notifier.SeverityWarning},
+		{Severity: notifier.SeverityCritical},
+	}
+	suppressed := []cwl2slack.Suppressed{
+		{Notification: notifier.Notification{Severity: notifier.SeverityWarning}, LogGroups: []string{"/aws/lambda/other"}, Modes: []string{"plain"}},
+	}
+
+	tests := []struct {
+		name     string
+		match    func(cwl2slack.Route) bool
+		want     map[string]int
+		isNormal bool
+	}{
+		// 正常系のテストケース
+		{
+			name:     "通知の条件に一致する通知先に重大度で絞り込んで振り分ける",
+			match:    func(r cwl2slack.Route) bool { return r.Name != "other" },
+			want:     map[string]int{"all": 3, "critical": 1, "other": 1},
+			isNormal: true,
+		},
+		{
+			name:     "条件に一致しない通知先には件数の通知だけを振り分ける",
+			match:    func(r cwl2slack.Route) bool { return false },
+			want:     map[string]int{"all": 1, "other": 1},
+			isNormal: true,
+		},
+		// 異常系のテストケース
+		{
+			name:     "通知先の設定が不正な場合はエラーを返す",
+			match:    func(r cwl2slack.Route) bool { return true },
+			isNormal: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := routes
+			if !tt.isNormal {
+				rs = []cwl2slack.Route{{Name: "invalid", Type: "unknown"}}
+			}
+			destinations, err := buildDestinations(rs, tt.match, notifications, suppressed)
+			if !tt.isNormal {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := map[string]int{}
+			for _, d := range destinations {
+				got[d.Name] = len(d.Notifications)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
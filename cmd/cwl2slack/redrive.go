@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tomozo6/cwl2slack/internal/cwl2slack"
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+	"github.com/tomozo6/cwl2slack/pkg/deadletter"
+	"github.com/tomozo6/cwl2slack/pkg/dispatch"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/ratelimit"
+)
+
+// redriveはデッドレターに保存した通知を、保存したときの通知先に再送します。
+// 通知先はLambdaと同じ環境変数(ROUTESなど)で設定します。再送できた通知はデッドレターから削除します
+func redrive(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("redrive", flag.ContinueOnError)
+	deadLetterURL := fs.String("dead-letter", os.Getenv("DEAD_LETTER"), "dead-letter URL (sqs queue URL, s3://bucket/prefix or directory)")
+	dryRun := fs.Bool("dry-run", false, "list dead-lettered notifications without sending")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *deadLetterURL == "" {
+		return fmt.Errorf("dead-letter URL is required: set -dead-letter or DEAD_LETTER")
+	}
+
+	region := os.Getenv("AWS_REGION")
+
+	store, err := deadletter.Open(*deadLetterURL, awsapi.NewClient(region))
+	if err != nil {
+		return err
+	}
+	routes, err := loadRoutes()
+	if err != nil {
+		return err
+	}
+	byName := map[string]cwl2slack.Route{}
+	for _, r := range routes {
+		byName[r.Name] = r
+	}
+
+	// 送信間隔の制限は再送全体で通知先ごとに共有する
+	limiters := map[string]*ratelimit.Limiter{}
+	limited := func(n notifier.Notifier, r cwl2slack.Route) notifier.Notifier {
+		rate, burst := r.Limit()
+		if rate <= 0 {
+			return n
+		}
+		if _, ok := limiters[r.Name]; !ok {
+			limiters[r.Name] = ratelimit.NewLimiter(rate, burst)
+		}
+		return ratelimit.Wrap(n, limiters[r.Name])
+	}
+
+	// 再送した通知も送信済みとして記録し、再送を繰り返しても重複しないようにする
+	d, err := newDispatcher(region)
+	if err != nil {
+		return err
+	}
+
+	messages, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, m := range messages {
+		rec := m.Record
+		if *dryRun {
+			fmt.Fprintf(w, "%s %s %s: %s\n", rec.ID, rec.FailedAt.Format("2006-01-02T15:04:05Z07:00"), rec.Destination, rec.Error)
+			continue
+		}
+
+		route, ok := byName[rec.Destination]
+		if !ok {
+			fmt.Fprintf(w, "redrive skipped: %s: route %s not found\n", rec.ID, rec.Destination)
+			failed++
+			continue
+		}
+		n, err := newNotifier(route, region)
+		if err != nil {
+			return err
+		}
+		n = limited(n, route)
+
+		report := d.Dispatch(ctx, []dispatch.Destination{{Name: route.Name, Notifier: n, Notifications: []notifier.Notification{rec.Notification}}})
+		if err := report.Err(dispatch.PolicyAll); err != nil {
+			fmt.Fprintf(w, "redrive failed: %s: %s\n", rec.ID, err)
+			failed++
+			continue
+		}
+		if err := store.Delete(ctx, m.Handle); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "redriven: %s\n", report.Results[0])
+	}
+
+	if *dryRun {
+		return nil
+	}
+	fmt.Fprintf(w, "redriven %d of %d notifications\n", len(messages)-failed, len(messages))
+	if failed > 0 {
+		return fmt.Errorf("%d notifications could not be redriven", failed)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/deadletter"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestRedrive(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		destination string
+		isNormal    bool
+		wantLeft    int
+	}{
+		{name: "[正常系]通知先が復旧している場合", status: http.StatusOK, destination: "slack", isNormal: true, wantLeft: 0},
+		{name: "[異常系]通知先が復旧していない場合", status: http.StatusServiceUnavailable, destination: "slack", isNormal: false, wantLeft: 1},
+		{name: "[異常系]通知先の設定が無い場合", status: http.StatusOK, destination: "teams", isNormal: false, wantLeft: 1},
+	}
+
+	for i, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				received = append(received, string(b))
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			t.Setenv("ROUTES", fmt.Sprintf(`[{"name":"slack","type":"slack","url":%q}]`, ts.URL))
+			t.Setenv("DEDUP_TABLE", "")
+
+			dir := &deadletter.Dir{Path: t.TempDir()}
+			n := notifier.Notification{Payload: slack.Payload{Text: fmt.Sprintf("redrive test %d", i)}, EventIDs: []string{fmt.Sprintf("redrive%d", i)}}
+			data := &events.CloudwatchLogsData{LogGroup: "/aws/test"}
+			dir.Put(context.Background(), deadletter.NewRecord(tt.destination, n, data, errors.New("received non-2xx response: 503"), time.Now()))
+
+			var out bytes.Buffer
+			err := redrive(context.Background(), []string{"-dead-letter", dir.Path}, &out)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(received) != 1 || !strings.Contains(received[0], n.Payload.Text) {
+					t.Fatalf("unexpected request: %v", received)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+
+			left, _ := dir.List(context.Background())
+			if len(left) != tt.wantLeft {
+				t.Fatalf("unexpected dead-letter: %d, output: %s", len(left), out.String())
+			}
+		})
+	}
+}
+
+func TestRedriveDryRun(t *testing.T) {
+	dir := &deadletter.Dir{Path: t.TempDir()}
+	n := notifier.Notification{Payload: slack.Payload{Text: "dry run"}}
+	r := deadletter.NewRecord("slack", n, nil, errors.New("boom"), time.Now())
+	dir.Put(context.Background(), r)
+
+	var out bytes.Buffer
+	if err := redrive(context.Background(), []string{"-dead-letter", dir.Path, "-dry-run"}, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), r.ID) || !strings.Contains(out.String(), "boom") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+	if left, _ := dir.List(context.Background()); len(left) != 1 {
+		t.Fatalf("dry run should not delete: %d", len(left))
+	}
+}
+
+func TestRedriveWithoutDeadLetter(t *testing.T) {
+	t.Setenv("DEAD_LETTER", "")
+	if err := redrive(context.Background(), nil, io.Discard); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+}
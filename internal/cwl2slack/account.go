@@ -0,0 +1,117 @@
+package cwl2slack
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// AccountDirectoryはAWSアカウントIDごとの名前と環境、環境ごとの表示、リージョンの表示名です
+type AccountDirectory struct {
+	Accounts     map[string]Account     `json:"accounts"`
+	Environments map[string]Environment `json:"environments,omitempty"`
+
+	// リージョンごとの表示名(例: ap-northeast-1: 東京)
+	Regions map[string]string `json:"regions,omitempty"`
+}
+
+// AccountはAWSアカウントの名前と環境です。ColorとEmojiを指定した場合は環境の設定より優先します
+type Account struct {
+	ID          string `json:"-"`
+	Name        string `json:"name"`
+	Environment string `json:"environment,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Emoji       string `json:"emoji,omitempty"`
+}
+
+// Environmentは環境(prod, stagingなど)ごとのアタッチメントの色と、タイトルの先頭に付ける絵文字です
+type Environment struct {
+	Color string `json:"color,omitempty"`
+	Emoji string `json:"emoji,omitempty"`
+}
+
+// ParseAccountDirectoryはJSONからAccountDirectoryを作成します
+func ParseAccountDirectory(s string) (*AccountDirectory, error) {
+	var d AccountDirectory
+	if err := json.Unmarshal([]byte(s), &d); err != nil {
+		return nil, fmt.Errorf("failed to parse account directory: %w", err)
+	}
+	for id, a := range d.Accounts {
+		if a.Name == "" {
+			return nil, fmt.Errorf("account %s: name is required", id)
+		}
+	}
+	return &d, nil
+}
+
+// Lookupはアカウントを返します。登録されていないアカウントはIDのみを返します
+func (d *AccountDirectory) Lookup(id string) Account {
+	var a Account
+	if d != nil {
+		a = d.Accounts[id]
+		if env, ok := d.Environments[a.Environment]; ok {
+			if a.Color == "" {
+				a.Color = env.Color
+			}
+			if a.Emoji == "" {
+				a.Emoji = env.Emoji
+			}
+		}
+	}
+	a.ID = id
+	return a
+}
+
+// Regionはリージョンの表示名を「東京 (ap-northeast-1)」の形式で返します。表示名が無い場合はリージョンのみです
+func (d *AccountDirectory) Region(region string) string {
+	if d == nil || d.Regions[region] == "" {
+		return region
+	}
+	return fmt.Sprintf("%s (%s)", d.Regions[region], region)
+}
+
+// Stringはアカウントを「prod-payments (123456789012)」の形式で返します。名前が無い場合はIDのみです
+func (a Account) String() string {
+	if a.Name == "" {
+		return a.ID
+	}
+	return fmt.Sprintf("%s (%s)", a.Name, a.ID)
+}
+
+// accountはログを送信したAWSアカウントを返します
+func (c *Cwl2slack) account() Account {
+	return c.Accounts.Lookup(c.Cwld.Owner)
+}
+
+// decorateAccountはアカウントとリージョンの表示名をフッターに表示し、環境の色と絵文字を設定したペイロードを返します。
+// 項目の数には上限(slack.MaxAttachmentFields)があるため、アカウントとリージョンは項目に追加しません。
+// サブスクリプションの送信先は同じリージョンにあるため、Lambdaのリージョンをログのリージョンとして表示します
+func (c *Cwl2slack) decorateAccount(p slack.Payload) slack.Payload {
+	if c.Cwld.Owner == "" || len(p.Attachments) == 0 {
+		return p
+	}
+	a := c.account()
+	source := a.String()
+	if c.Region != "" {
+		source += " | " + c.Accounts.Region(c.Region)
+	}
+
+	attachments := make([]slack.Attachment, len(p.Attachments))
+	copy(attachments, p.Attachments)
+	for i := range attachments {
+		if a.Color != "" {
+			attachments[i].Color = a.Color
+		}
+		if a.Emoji != "" {
+			attachments[i].Title = a.Emoji + attachments[i].Title
+		}
+		footer := slack.Escape(source)
+		if attachments[i].Footer != "" {
+			footer += " | " + attachments[i].Footer
+		}
+		attachments[i].Footer = footer
+	}
+	p.Attachments = attachments
+	return p
+}
@@ -0,0 +1,146 @@
+package cwl2slack
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testAccountDirectory = `{
+	"accounts": {
+		"123456789012": {"name": "prod-payments", "environment": "prod"},
+		"210987654321": {"name": "stg-payments", "environment": "staging", "color": "#888888"}
+	},
+	"environments": {
+		"prod": {"color": "#E01E5A", "emoji": ":red_circle:"},
+		"staging": {"color": "#ECB22E"}
+	},
+	"regions": {"ap-northeast-1": "東京"}
+}`
+
+func TestParseAccountDirectory(t *testing.T) {
+	testCases := []struct {
+		name     string
+		json     string
+		isNormal bool
+	}{
+		{name: "[正常系]アカウントと環境", json: testAccountDirectory, isNormal: true},
+		{name: "[異常系]名前が無いアカウント", json: `{"accounts":{"123456789012":{"environment":"prod"}}}`, isNormal: false},
+		{name: "[異常系]JSONでない", json: `123456789012`, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAccountDirectory(tt.json)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestAccountDirectoryLookup(t *testing.T) {
+	d, _ := ParseAccountDirectory(testAccountDirectory)
+
+	testCases := []struct {
+		name      string
+		directory *AccountDirectory
+		id        string
+		want      string
+		wantColor string
+		wantEmoji string
+	}{
+		{name: "環境の色と絵文字", directory: d, id: "123456789012", want: "prod-payments (123456789012)", wantColor: "#E01E5A", wantEmoji: ":red_circle:"},
+		{name: "アカウントの色を優先する", directory: d, id: "210987654321", want: "stg-payments (210987654321)", wantColor: "#888888"},
+		{name: "登録されていないアカウント", directory: d, id: "999999999999", want: "999999999999"},
+		{name: "ディレクトリが無い場合", directory: nil, id: "123456789012", want: "123456789012"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.directory.Lookup(tt.id)
+			if a.String() != tt.want || a.Color != tt.wantColor || a.Emoji != tt.wantEmoji {
+				t.Fatalf("unexpected account: %+v", a)
+			}
+		})
+	}
+
+	if got := d.Region("ap-northeast-1"); got != "東京 (ap-northeast-1)" {
+		t.Fatalf("unexpected region: %s", got)
+	}
+	if got := d.Region("us-east-1"); got != "us-east-1" {
+		t.Fatalf("unexpected region: %s", got)
+	}
+}
+
+func TestGetNotificationsAccount(t *testing.T) {
+	cwld := events.CloudwatchLogsData{Owner: "123456789012", LogGroup: "/aws/test", LogEvents: []events.CloudwatchLogsLogEvent{{ID: "1", Message: "[ERROR] failed"}}}
+	c, _ := NewCwl2slack("plain", 0, &cwld)
+	c.Region = "ap-northeast-1"
+	c.Accounts, _ = ParseAccountDirectory(testAccountDirectory)
+
+	got, err := c.GetNotifications()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a := got[0].Payload.Attachments[0]
+	if a.Color != "#E01E5A" || a.Title[:len(":red_circle:")] != ":red_circle:" {
+		t.Fatalf("unexpected attachment: %+v", a)
+	}
+	// アカウントとリージョンの表示名はフッターに表示し、項目に追加しません
+	if a.Footer != "prod-payments (123456789012) | 東京 (ap-northeast-1) | post by cwl2slack" {
+		t.Fatalf("unexpected footer: %s", a.Footer)
+	}
+	for _, f := range a.Fields {
+		if f.Title == "AWSアカウント" || f.Title == "リージョン" {
+			t.Fatalf("unexpected field: %+v", f)
+		}
+	}
+	if got[0].Details["account_name"] != "prod-payments" || got[0].Details["environment"] != "prod" {
+		t.Fatalf("unexpected details: %+v", got[0].Details)
+	}
+}
+
+func TestGetNotificationsAccountFieldsLimit(t *testing.T) {
+	message := "# Time: 2023-10-22T02:57:55.655927Z\n# User@Host: app[app] @ [10.0.0.1] Id: 1\n# Query_time: 35.549734 Lock_time: 0.000164 Rows_sent: 1 Rows_examined: 15535\nSET timestamp=1697943475;\nSELECT SLEEP(20) /* req=a */;"
+	cwld := events.CloudwatchLogsData{Owner: "123456789012", LogGroup: "/aws/rds/cluster/db/slowquery", LogEvents: []events.CloudwatchLogsLogEvent{{ID: "1", Timestamp: 1697943475000, Message: message}}}
+	c, _ := NewCwl2slack("slowquery", 1, &cwld)
+	c.Region = "ap-northeast-1"
+	c.Accounts, _ = ParseAccountDirectory(testAccountDirectory)
+	c.InvokedAt = time.UnixMilli(1697943475000).Add(time.Hour)
+	c.IngestionDelayThreshold = time.Minute
+	c.CorrelationKey, _ = NewCorrelationKey(`req=(\w+)`)
+
+	got, err := c.GetNotifications()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("unexpected number of notifications: %d", len(got))
+	}
+
+	// 取り込み遅延とCorrelation Keyの項目があっても、項目の数の上限を超えません
+	p := got[0].Payload
+	if err := p.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var titles []string
+	for _, f := range p.Attachments[0].Fields {
+		titles = append(titles, f.Title)
+	}
+	if all := strings.Join(titles, ","); !strings.Contains(all, "Correlation Key") || !strings.Contains(all, "取り込み遅延") {
+		t.Fatalf("unexpected fields: %s", all)
+	}
+}
@@ -0,0 +1,267 @@
+package cwl2slack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsconsole"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// AlarmModeはCloudWatchアラームの状態の変化を通知するモードです。SNSから呼び出された場合に使います
+const AlarmMode = "alarm"
+
+// アラームの状態
+const (
+	AlarmStateAlarm            = "ALARM"
+	AlarmStateOK               = "OK"
+	AlarmStateInsufficientData = "INSUFFICIENT_DATA"
+)
+
+// AlarmはCloudWatchアラームがSNSに送信する状態の変化のメッセージです
+type Alarm struct {
+	AlarmName        string       `json:"AlarmName"`
+	AlarmDescription string       `json:"AlarmDescription"`
+	AlarmArn         string       `json:"AlarmArn"`
+	AWSAccountID     string       `json:"AWSAccountId"`
+	NewStateValue    string       `json:"NewStateValue"`
+	NewStateReason   string       `json:"NewStateReason"`
+	OldStateValue    string       `json:"OldStateValue"`
+	StateChangeTime  string       `json:"StateChangeTime"`
+	Trigger          AlarmTrigger `json:"Trigger"`
+}
+
+// AlarmTriggerはアラームの条件です。メトリクスの計算式を使うアラームはMetricsに指定されます
+type AlarmTrigger struct {
+	MetricName         string            `json:"MetricName"`
+	Namespace          string            `json:"Namespace"`
+	Statistic          string            `json:"Statistic"`
+	ExtendedStatistic  string            `json:"ExtendedStatistic"`
+	Dimensions         []AlarmDimension  `json:"Dimensions"`
+	Period             int               `json:"Period"`
+	EvaluationPeriods  int               `json:"EvaluationPeriods"`
+	ComparisonOperator string            `json:"ComparisonOperator"`
+	Threshold          float64           `json:"Threshold"`
+	Metrics            []AlarmMetricData `json:"Metrics"`
+}
+
+type AlarmDimension struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// AlarmMetricDataはメトリクスの計算式を使うアラームのメトリクスまたは式です
+type AlarmMetricData struct {
+	ID         string `json:"Id"`
+	Expression string `json:"Expression"`
+	Label      string `json:"Label"`
+	ReturnData bool   `json:"ReturnData"`
+}
+
+// stateChangeTimeLayoutはStateChangeTimeの形式です(例: 2024-05-27T06:53:33.043+0000)
+const stateChangeTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// comparisonOperatorsは比較演算子の表示です
+var comparisonOperators = map[string]string{
+	"GreaterThanOrEqualToThreshold": ">=",
+	"GreaterThanThreshold":          ">",
+	"LessThanThreshold":             "<",
+	"LessThanOrEqualToThreshold":    "<=",
+}
+
+// ParseAlarmはSNSのメッセージからアラームの状態の変化を読み込みます。アラームのメッセージでない場合はエラーを返します
+func ParseAlarm(message string) (*Alarm, error) {
+	var a Alarm
+	if err := json.Unmarshal([]byte(message), &a); err != nil {
+		return nil, fmt.Errorf("failed to parse alarm: %w", err)
+	}
+	if a.AlarmName == "" || a.NewStateValue == "" {
+		return nil, errors.New("not a CloudWatch alarm message")
+	}
+	return &a, nil
+}
+
+// RegionはアラームのARNからリージョンを返します
+func (a *Alarm) Region() string {
+	// arn:aws:cloudwatch:ap-northeast-1:123456789012:alarm:name
+	parts := strings.SplitN(a.AlarmArn, ":", 5)
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[3]
+}
+
+// ChangedAtは状態が変化した時刻を返します。解析できない場合はゼロ値です
+func (a *Alarm) ChangedAt() time.Time {
+	t, err := time.Parse(stateChangeTimeLayout, a.StateChangeTime)
+	if err != nil {
+		t, _ = time.Parse(time.RFC3339, a.StateChangeTime)
+	}
+	return t
+}
+
+// Severityは新しい状態に対応する重要度を返します
+func (a *Alarm) Severity() notifier.Severity {
+	switch a.NewStateValue {
+	case AlarmStateAlarm:
+		return notifier.SeverityError
+	case AlarmStateOK:
+		return notifier.SeverityInfo
+	default:
+		return notifier.SeverityWarning
+	}
+}
+
+// Metricはアラームのメトリクスを「AWS/EC2 CPUUtilization (InstanceId=i-123)」の形式で返します
+func (a *Alarm) Metric() string {
+	t := a.Trigger
+	if t.MetricName == "" {
+		// メトリクスの計算式はアラームの対象になる式を表示する
+		var exprs []string
+		for _, m := range t.Metrics {
+			if !m.ReturnData {
+				continue
+			}
+			switch {
+			case m.Label != "":
+				exprs = append(exprs, m.Label)
+			case m.Expression != "":
+				exprs = append(exprs, m.Expression)
+			default:
+				exprs = append(exprs, m.ID)
+			}
+		}
+		return strings.Join(exprs, ", ")
+	}
+
+	s := strings.TrimSpace(t.Namespace + " " + t.MetricName)
+	if len(t.Dimensions) > 0 {
+		dims := make([]string, len(t.Dimensions))
+		for i, d := range t.Dimensions {
+			dims[i] = d.Name + "=" + d.Value
+		}
+		s += " (" + strings.Join(dims, ", ") + ")"
+	}
+	return s
+}
+
+// Conditionはアラームの条件を「AVERAGE > 80 (300秒 × 1回)」の形式で返します
+func (a *Alarm) Condition() string {
+	t := a.Trigger
+	op, ok := comparisonOperators[t.ComparisonOperator]
+	if !ok {
+		op = t.ComparisonOperator
+	}
+	stat := t.Statistic
+	if t.ExtendedStatistic != "" {
+		stat = t.ExtendedStatistic
+	}
+
+	s := strings.TrimSpace(fmt.Sprintf("%s %s %s", stat, op, strconv.FormatFloat(t.Threshold, 'f', -1, 64)))
+	if t.Period > 0 && t.EvaluationPeriods > 0 {
+		s += fmt.Sprintf(" (%d秒 × %d回)", t.Period, t.EvaluationPeriods)
+	}
+	return s
+}
+
+// Notificationはアラームの状態の変化の通知を返します。idはSNSのメッセージIDで、再送時に送信済みの通知を判定するために使います。
+// ALARMへの変化はscでアラーム名と説明から重要度を判定し、重要度に応じた色、絵文字、メンションを設定します。scがnilの場合は既定の設定です。
+// OKへの変化は緑で表示し、スレッドに返信できる通知先ではALARMの通知のスレッドに返信します
+func (a *Alarm) Notification(id string, accounts *AccountDirectory, loc *time.Location, sc *SeverityConfig) notifier.Notification {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if sc == nil {
+		sc = defaultSeverityConfig
+	}
+	region := a.Region()
+	changedAt := a.ChangedAt()
+
+	emoji, color := ":warning:", "warning"
+	switch a.NewStateValue {
+	case AlarmStateAlarm:
+		emoji, color = ":rotating_light:", "danger"
+	case AlarmStateOK:
+		emoji, color = ":white_check_mark:", "good"
+	}
+
+	fields := []slack.Field{
+		{Title: "状態", Value: slack.Escape(fmt.Sprintf("%s → %s", a.OldStateValue, a.NewStateValue)), Short: true},
+	}
+	if !changedAt.IsZero() {
+		fields = append(fields, slack.Field{Title: "発生時刻", Value: changedAt.In(loc).Format("2006-01-02 15:04:05 MST"), Short: true})
+	}
+	if a.AWSAccountID != "" {
+		fields = append(fields, slack.Field{Title: "AWSアカウント", Value: slack.Escape(accounts.Lookup(a.AWSAccountID).String()), Short: true})
+	}
+	if region != "" {
+		fields = append(fields, slack.Field{Title: "リージョン", Value: slack.Escape(accounts.Region(region)), Short: true})
+	}
+	if a.AlarmDescription != "" {
+		fields = append(fields, slack.Field{Title: "説明", Value: slack.Escape(a.AlarmDescription)})
+	}
+	fields = append(fields,
+		slack.Field{Title: "理由", Value: slack.Escape(a.NewStateReason)},
+		slack.Field{Title: "メトリクス", Value: slack.Escape(a.Metric())},
+		slack.Field{Title: "閾値", Value: slack.Escape(a.Condition())},
+	)
+
+	attachment := slack.Attachment{
+		Title:  fmt.Sprintf("%sアラーム %s が %s になりました", emoji, slack.Escape(a.AlarmName), a.NewStateValue),
+		Color:  color,
+		Footer: "post by cwl2slack",
+		Fields: fields,
+	}
+	if !changedAt.IsZero() {
+		attachment.Timestamp = changedAt.Unix()
+	}
+	if region != "" {
+		attachment.TitleLink = awsconsole.AlarmURL(region, a.AlarmName)
+		attachment.Actions = []slack.Action{{Type: "button", Text: "Open in CloudWatch", Url: attachment.TitleLink, Style: "primary"}}
+	}
+
+	threadKey := a.AlarmArn
+	if threadKey == "" {
+		threadKey = a.AlarmName
+	}
+	payload := slack.Payload{
+		Username:    "CloudWatch Alarm",
+		IconEmoji:   ":robot_face:",
+		Attachments: []slack.Attachment{attachment},
+	}
+	severity := a.Severity()
+	if a.NewStateValue == AlarmStateAlarm {
+		severity = sc.Classify(AlarmMode, a.AlarmName+"\n"+a.AlarmDescription)
+		payload = sc.Decorate(payload, severity)
+	}
+
+	n := notifier.Notification{
+		Payload:     payload,
+		Severity:    severity,
+		Fingerprint: Fingerprint(threadKey, AlarmMode),
+		Source:      a.AlarmName,
+		Details: map[string]any{
+			"mode":       AlarmMode,
+			"alarm_name": a.AlarmName,
+			"alarm_arn":  a.AlarmArn,
+			"old_state":  a.OldStateValue,
+			"new_state":  a.NewStateValue,
+			"reason":     a.NewStateReason,
+			"metric":     a.Metric(),
+			"threshold":  a.Trigger.Threshold,
+			"account_id": a.AWSAccountID,
+		},
+		Timestamp:   changedAt,
+		ThreadKey:   threadKey,
+		StartThread: a.NewStateValue == AlarmStateAlarm,
+	}
+	if id != "" {
+		n.EventIDs = []string{id}
+	}
+	return n
+}
@@ -0,0 +1,159 @@
+package cwl2slack
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+)
+
+const testAlarmMessage = `{
+	"AlarmName": "api-5xx",
+	"AlarmDescription": "APIの5xxエラー",
+	"AWSAccountId": "123456789012",
+	"NewStateValue": "%s",
+	"NewStateReason": "Threshold Crossed: 1 datapoint [12.0 (27/05/24 06:48:00)] was greater than the threshold (10.0).",
+	"StateChangeTime": "2024-05-27T06:53:33.043+0000",
+	"Region": "Asia Pacific (Tokyo)",
+	"AlarmArn": "arn:aws:cloudwatch:ap-northeast-1:123456789012:alarm:api-5xx",
+	"OldStateValue": "%s",
+	"Trigger": {
+		"MetricName": "HTTPCode_Target_5XX_Count",
+		"Namespace": "AWS/ApplicationELB",
+		"Statistic": "SUM",
+		"Dimensions": [{"value": "app/api/abc", "name": "LoadBalancer"}],
+		"Period": 60,
+		"EvaluationPeriods": 1,
+		"ComparisonOperator": "GreaterThanThreshold",
+		"Threshold": 10.0
+	}
+}`
+
+func testAlarm(t *testing.T, newState string, oldState string) *Alarm {
+	t.Helper()
+	a, err := ParseAlarm(strings.NewReplacer("%s", newState).Replace(strings.Replace(testAlarmMessage, `"OldStateValue": "%s"`, `"OldStateValue": "`+oldState+`"`, 1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return a
+}
+
+func TestParseAlarm(t *testing.T) {
+	testCases := []struct {
+		name     string
+		message  string
+		isNormal bool
+	}{
+		{name: "[正常系]アラームの状態の変化", message: `{"AlarmName":"a","NewStateValue":"ALARM"}`, isNormal: true},
+		{name: "[異常系]アラームでないメッセージ", message: `{"Type":"Notification","Message":"hello"}`, isNormal: false},
+		{name: "[異常系]JSONでない", message: `hello`, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAlarm(tt.message)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestAlarmMetricAndCondition(t *testing.T) {
+	a := testAlarm(t, "ALARM", "OK")
+	if got := a.Metric(); got != "AWS/ApplicationELB HTTPCode_Target_5XX_Count (LoadBalancer=app/api/abc)" {
+		t.Fatalf("unexpected metric: %s", got)
+	}
+	if got := a.Condition(); got != "SUM > 10 (60秒 × 1回)" {
+		t.Fatalf("unexpected condition: %s", got)
+	}
+	if got := a.Region(); got != "ap-northeast-1" {
+		t.Fatalf("unexpected region: %s", got)
+	}
+
+	// メトリクスの計算式はアラームの対象の式を表示する
+	m, _ := ParseAlarm(`{"AlarmName":"m","NewStateValue":"ALARM","Trigger":{"Metrics":[{"Id":"m1","ReturnData":false},{"Id":"e1","Expression":"m1 / 60","Label":"errors per second","ReturnData":true}],"ComparisonOperator":"GreaterThanOrEqualToThreshold","Threshold":0.5}}`)
+	if got := m.Metric(); got != "errors per second" {
+		t.Fatalf("unexpected metric: %s", got)
+	}
+	if got := m.Condition(); got != ">= 0.5" {
+		t.Fatalf("unexpected condition: %s", got)
+	}
+}
+
+func TestAlarmNotification(t *testing.T) {
+	accounts, _ := ParseAccountDirectory(`{"accounts":{"123456789012":{"name":"prod-payments"}}}`)
+
+	testCases := []struct {
+		name            string
+		newState        string
+		oldState        string
+		wantColor       string
+		wantSeverity    notifier.Severity
+		wantStartThread bool
+	}{
+		{name: "ALARM", newState: "ALARM", oldState: "OK", wantColor: "danger", wantSeverity: notifier.SeverityError, wantStartThread: true},
+		{name: "OKは緑", newState: "OK", oldState: "ALARM", wantColor: "good", wantSeverity: notifier.SeverityInfo},
+		{name: "INSUFFICIENT_DATA", newState: "INSUFFICIENT_DATA", oldState: "OK", wantColor: "warning", wantSeverity: notifier.SeverityWarning},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAlarm(t, tt.newState, tt.oldState)
+			n := a.Notification("msg-1", accounts, time.FixedZone("JST", 9*60*60), nil)
+
+			att := n.Payload.Attachments[0]
+			if att.Color != tt.wantColor || n.Severity != tt.wantSeverity || n.StartThread != tt.wantStartThread {
+				t.Fatalf("unexpected notification: %s, %s, %v", att.Color, n.Severity, n.StartThread)
+			}
+			// 同じアラームの通知は同じスレッドにまとめる
+			if n.ThreadKey != a.AlarmArn || strings.Join(n.EventIDs, ",") != "msg-1" {
+				t.Fatalf("unexpected thread key or event ids: %s, %v", n.ThreadKey, n.EventIDs)
+			}
+			if !strings.Contains(att.TitleLink, "#alarmsV2:alarm/api-5xx") || len(att.Actions) != 1 {
+				t.Fatalf("unexpected link: %s", att.TitleLink)
+			}
+
+			values := map[string]string{}
+			for _, f := range att.Fields {
+				values[f.Title] = f.Value
+			}
+			if values["状態"] != tt.oldState+" → "+tt.newState || values["発生時刻"] != "2024-05-27 15:53:33 JST" || values["AWSアカウント"] != "prod-payments (123456789012)" {
+				t.Fatalf("unexpected fields: %+v", values)
+			}
+			if values["閾値"] != "SUM &gt; 10 (60秒 × 1回)" || values["理由"] == "" || values["メトリクス"] == "" {
+				t.Fatalf("unexpected fields: %+v", values)
+			}
+		})
+	}
+}
+
+func TestAlarmNotificationSeverityConfig(t *testing.T) {
+	sc, err := ParseSeverityConfig(`{"rules":[{"pattern":"5xx","severity":"critical"}],"colors":{"critical":"#000000"},"mentions":{"critical":"here"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// ALARMへの変化はアラーム名と説明で重要度を判定し、重要度の表示を設定する
+	n := testAlarm(t, "ALARM", "OK").Notification("msg-1", nil, nil, sc)
+	att := n.Payload.Attachments[0]
+	if n.Severity != notifier.SeverityCritical || att.Color != "#000000" || !strings.HasPrefix(att.Title, ":fire:") || n.Payload.Text != "<!here>" {
+		t.Fatalf("unexpected notification: %s, %s, %s, %s", n.Severity, att.Color, att.Title, n.Payload.Text)
+	}
+
+	// OKへの変化は緑のままでメンションしない
+	n = testAlarm(t, "OK", "ALARM").Notification("msg-2", nil, nil, sc)
+	if n.Severity != notifier.SeverityInfo || n.Payload.Attachments[0].Color != "good" || n.Payload.Text != "" {
+		t.Fatalf("unexpected notification: %s, %s, %s", n.Severity, n.Payload.Attachments[0].Color, n.Payload.Text)
+	}
+}
@@ -0,0 +1,169 @@
+package cwl2slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// CorrelationKeyはログイベントをグループ化するためのキー(リクエストIDやトレースIDなど)の取得方法です。
+// JSONPathとRegexpのどちらか一方が設定されます
+type CorrelationKey struct {
+	JSONPath []string
+	Regexp   *regexp.Regexp
+}
+
+// NewCorrelationKeyはCorrelationKeyのコンストラクタです。
+// "$."で始まる場合はJSONパス(例: $.context.requestId)、それ以外は正規表現として扱います。
+// 正規表現にキャプチャグループがある場合は最初のグループをキーとします
+func NewCorrelationKey(s string) (*CorrelationKey, error) {
+	if strings.HasPrefix(s, "$.") {
+		path := strings.Split(strings.TrimPrefix(s, "$."), ".")
+		for _, p := range path {
+			if p == "" {
+				return nil, fmt.Errorf("invalid correlation key json path: %s", s)
+			}
+		}
+		return &CorrelationKey{JSONPath: path}, nil
+	}
+
+	r, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid correlation key regexp: %w", err)
+	}
+	return &CorrelationKey{Regexp: r}, nil
+}
+
+// Extractはログメッセージからキーを取り出します。キーが見つからない場合は空文字列を返します
+func (k *CorrelationKey) Extract(message string) string {
+	if k.Regexp != nil {
+		m := k.Regexp.FindStringSubmatch(message)
+		switch {
+		case m == nil:
+			return ""
+		case len(m) > 1:
+			return m[1]
+		default:
+			return m[0]
+		}
+	}
+
+	// メッセージの先頭にタイムスタンプなどが付いている場合を考慮して、最初の{からJSONとして解析します
+	i := strings.Index(message, "{")
+	if i < 0 {
+		return ""
+	}
+	var v any
+	if err := json.NewDecoder(strings.NewReader(message[i:])).Decode(&v); err != nil {
+		return ""
+	}
+	for _, p := range k.JSONPath {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = m[p]
+	}
+
+	switch v := v.(type) {
+	case nil, map[string]any, []any:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// eventGroupは同じキーを持つログイベントのまとまりです
+type eventGroup struct {
+	key    string
+	events []events.CloudwatchLogsLogEvent
+}
+
+// groupLogEventsはログイベントをキーごとにグループ化します。
+// グループは最初に出現した順に並び、各グループ内のログイベントはタイムスタンプ順に並びます。
+// キーを持たないログイベントはungroupedとして元の順序のまま返します
+func groupLogEvents(k *CorrelationKey, logEvents []events.CloudwatchLogsLogEvent) (groups []eventGroup, ungrouped []events.CloudwatchLogsLogEvent) {
+	index := map[string]int{}
+	for _, e := range logEvents {
+		key := k.Extract(e.Message)
+		if key == "" {
+			ungrouped = append(ungrouped, e)
+			continue
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, eventGroup{key: key})
+		}
+		groups[i].events = append(groups[i].events, e)
+	}
+
+	for _, g := range groups {
+		sort.SliceStable(g.events, func(i, j int) bool {
+			return g.events[i].Timestamp < g.events[j].Timestamp
+		})
+	}
+	return groups, ungrouped
+}
+
+// getCorrelatedPayloadsはログイベントをキーごとにグループ化し、グループごとに1つのペイロードを返します。
+// キーを持たないログイベントは通常どおりモードごとのペイロードになります
+func (c *Cwl2slack) getCorrelatedPayloads() (*[]slack.Payload, error) {
+	groups, ungrouped := groupLogEvents(c.CorrelationKey, c.Cwld.LogEvents)
+
+	var payloads []slack.Payload
+	for _, g := range groups {
+		p, err := c.withLogEvents(g.events).getModePayloads()
+		if err != nil {
+			return nil, err
+		}
+		if len(*p) == 0 {
+			continue
+		}
+
+		// グループ内のペイロードのアタッチメントを1つのペイロードにまとめます
+		merged := (*p)[0]
+		merged.Attachments = nil
+		for _, e := range *p {
+			merged.Attachments = append(merged.Attachments, e.Attachments...)
+		}
+		if len(merged.Attachments) > 0 {
+			merged.Attachments[0].Fields = append([]slack.Field{
+				{
+					Title: "Correlation Key",
+					Value: slack.Escape(g.key),
+					Short: false,
+				},
+			}, merged.Attachments[0].Fields...)
+		}
+		payloads = append(payloads, merged)
+	}
+
+	if len(ungrouped) > 0 {
+		p, err := c.withLogEvents(ungrouped).getModePayloads()
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, *p...)
+	}
+
+	return &payloads, nil
+}
+
+// withLogEventsはログイベントだけを差し替えたCwl2slackのコピーを返します
+func (c *Cwl2slack) withLogEvents(logEvents []events.CloudwatchLogsLogEvent) *Cwl2slack {
+	cwld := *c.Cwld
+	cwld.LogEvents = logEvents
+
+	cc := *c
+	cc.Cwld = &cwld
+	return &cc
+}
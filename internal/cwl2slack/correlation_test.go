@@ -0,0 +1,152 @@
+package cwl2slack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestCorrelationKeyExtract(t *testing.T) {
+	testCases := []struct {
+		name    string
+		key     string
+		message string
+		want    string
+	}{
+		{
+			name:    "[正常系]正規表現のキャプチャグループ",
+			key:     `RequestId: ([0-9a-f-]+)`,
+			message: "[ERROR] RequestId: 8f5e-12ab failed",
+			want:    "8f5e-12ab",
+		},
+		{
+			name:    "[正常系]キャプチャグループのない正規表現",
+			key:     `Root=1-[0-9a-f]+-[0-9a-f]+`,
+			message: "X-Amzn-Trace-Id: Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1",
+			want:    "Root=1-5759e988-bd862e3fe1be46a994272793",
+		},
+		{
+			name:    "[正常系]JSONパス",
+			key:     "$.context.requestId",
+			message: `2024-05-27T06:53:33Z {"level":"error","context":{"requestId":"abc"}}`,
+			want:    "abc",
+		},
+		{
+			name:    "[正常系]JSONパスの値が数値の場合",
+			key:     "$.id",
+			message: `{"id":12345}`,
+			want:    "12345",
+		},
+		{
+			name:    "[正常系]JSONパスが存在しない場合",
+			key:     "$.context.requestId",
+			message: `{"context":{}}`,
+			want:    "",
+		},
+		{
+			name:    "[正常系]JSONでない場合",
+			key:     "$.requestId",
+			message: "plain text",
+			want:    "",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewCorrelationKey(tt.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := k.Extract(tt.message); got != tt.want {
+				t.Fatalf("\n got: %v;\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCorrelationKey(t *testing.T) {
+	testCases := []struct {
+		name     string
+		key      string
+		isNormal bool
+	}{
+		{name: "[正常系]正規表現", key: `id=(\w+)`, isNormal: true},
+		{name: "[正常系]JSONパス", key: "$.a.b", isNormal: true},
+		{name: "[異常系]正しくない正規表現", key: `id=(\w+`, isNormal: false},
+		{name: "[異常系]正しくないJSONパス", key: "$.a..b", isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCorrelationKey(tt.key)
+			if tt.isNormal && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.isNormal && err == nil {
+				t.Fatalf("expected error, but got nil")
+			}
+		})
+	}
+}
+
+func TestGetCorrelatedPayloads(t *testing.T) {
+	testCloudwatchLogsData := events.CloudwatchLogsData{
+		LogGroup:  "testLogGroup",
+		LogStream: "testLogStream",
+		LogEvents: []events.CloudwatchLogsLogEvent{
+			{Timestamp: 3, Message: "req=a second"},
+			{Timestamp: 1, Message: "no key"},
+			{Timestamp: 2, Message: "req=b only"},
+			{Timestamp: 1, Message: "req=a first"},
+		},
+	}
+
+	k, _ := NewCorrelationKey(`req=(\w+)`)
+	c, _ := NewCwl2slack("plain", 0, &testCloudwatchLogsData)
+	c.CorrelationKey = k
+
+	p, err := c.GetSlackPayloads()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// グループごとに1つ、キーを持たないログイベントで1つのペイロードになります
+	want := [][]slack.Field{
+		{
+			{Title: "Correlation Key", Value: "a"},
+			{Title: "Log Group", Value: "testLogGroup"},
+			{Title: "Log Stream", Value: "testLogStream"},
+			{Title: "Time", Value: "1970-01-01 00:00:00 UTC"},
+			{Title: "Log Messages", Value: "```\nreq=a first\nreq=a second\n```"},
+		},
+		{
+			{Title: "Correlation Key", Value: "b"},
+			{Title: "Log Group", Value: "testLogGroup"},
+			{Title: "Log Stream", Value: "testLogStream"},
+			{Title: "Time", Value: "1970-01-01 00:00:00 UTC"},
+			{Title: "Log Messages", Value: "```\nreq=b only\n```"},
+		},
+		{
+			{Title: "Log Group", Value: "testLogGroup"},
+			{Title: "Log Stream", Value: "testLogStream"},
+			{Title: "Time", Value: "1970-01-01 00:00:00 UTC"},
+			{Title: "Log Messages", Value: "```\nno key\n```"},
+		},
+	}
+
+	if len(*p) != len(want) {
+		t.Fatalf("unexpected number of payloads: %d", len(*p))
+	}
+	for i, w := range want {
+		if got := (*p)[i].Attachments[0].Fields; !reflect.DeepEqual(got, w) {
+			t.Fatalf("\n got: %+v;\nwant: %+v", got, w)
+		}
+	}
+
+	// 元のログイベントは変更されません
+	if testCloudwatchLogsData.LogEvents[0].Message != "req=a second" {
+		t.Fatalf("original log events were modified")
+	}
+}
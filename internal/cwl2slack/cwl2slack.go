@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"slices"
 	"strconv"
+	"time"
 
 	"strings"
 
@@ -15,12 +16,49 @@ type Cwl2slack struct {
 	Mode      string
 	Theashold float64
 	Cwld      *events.CloudwatchLogsData
+
+	// slowqueryモードで通知の重要度をcriticalにするクエリ実行時間の閾値(0の場合はcriticalにしません)
+	CriticalThreshold float64
+
+	// 重要度の判定方法と、重要度ごとの通知の表示(未設定の場合はDefaultSeverityConfig)
+	SeverityConfig *SeverityConfig
+
+	// ログを送信したAWSアカウントの名前と環境(未設定の場合はアカウントIDのみを表示します)
+	Accounts *AccountDirectory
+
+	// 設定されている場合、ログを担当するチームのオンコール担当者をメンションします
+	OnCall *OnCallConfig
+
+	// stacktraceモードでフレームワークとして扱うパッケージプレフィックス
+	FrameworkPrefixes []string
+
+	// 設定されている場合、同じキーを持つログイベントを1つの通知にまとめます
+	CorrelationKey *CorrelationKey
+
+	// CloudWatchコンソールへのリンクを作成するためのリージョンと、Logs Insightsでログイベントの前後に含める時間
+	Region         string
+	InsightsWindow time.Duration
+
+	// ログイベントの時刻を表示するタイムゾーン(未設定の場合はUTC)
+	Location *time.Location
+
+	// Lambdaが呼び出された時刻と、取り込み遅延を通知する閾値
+	InvokedAt               time.Time
+	IngestionDelayThreshold time.Duration
+}
+
+// ControlMessageはサブスクリプションの作成時に送信先に到達できるかを確認するためのメッセージの種類です
+const ControlMessage = "CONTROL_MESSAGE"
+
+// IsControlMessageはデータがログイベントではなく、到達確認のメッセージの場合にtrueを返します
+func IsControlMessage(d *events.CloudwatchLogsData) bool {
+	return d.MessageType == ControlMessage
 }
 
 // NewCwl2slackはCwl2slackのコンストラクタ
 func NewCwl2slack(m string, t float64, c *events.CloudwatchLogsData) (*Cwl2slack, error) {
 	// Modeが想定外の値の場合はエラーを返す
-	modes := []string{"plain", "slowquery"}
+	modes := []string{"plain", "slowquery", "stacktrace"}
 
 	if !slices.Contains(modes, m) {
 		return nil, fmt.Errorf("invalid mode: %s", m)
@@ -36,11 +74,22 @@ func NewCwl2slack(m string, t float64, c *events.CloudwatchLogsData) (*Cwl2slack
 // Slack通知に必要なペイロードの配列を返します
 func (c *Cwl2slack) GetSlackPayloads() (*[]slack.Payload, error) {
 
+	if c.CorrelationKey != nil {
+		return c.getCorrelatedPayloads()
+	}
+	return c.getModePayloads()
+}
+
+// モードに応じたSlack通知に必要なペイロードの配列を返します
+func (c *Cwl2slack) getModePayloads() (*[]slack.Payload, error) {
+
 	switch c.Mode {
 	case "plain":
 		return c.getPlainPayloads()
 	case "slowquery":
 		return c.getSlowQueryPayloads()
+	case "stacktrace":
+		return c.getStackTracePayloads()
 	default:
 		return nil, fmt.Errorf("invalid mode: %s", c.Mode)
 	}
@@ -58,6 +107,38 @@ func (c *Cwl2slack) getPlainPayloads() (*[]slack.Payload, error) {
 		messages[i] = e.Message
 	}
 	joinedMessages := strings.Join(messages, "\n")
+	titleLink, actions := c.cloudWatchLinks(c.Cwld.LogEvents)
+	first, _ := eventTimeRange(c.Cwld.LogEvents)
+
+	fields := []slack.Field{
+		{
+			Title: "Log Group",
+			Value: slack.Escape(c.Cwld.LogGroup),
+			Short: false,
+		},
+		{
+			Title: "Log Stream",
+			Value: slack.Escape(c.Cwld.LogStream),
+			Short: false,
+		},
+		{
+			Title: "Time",
+			Value: c.formatEventTime(c.Cwld.LogEvents),
+			Short: false,
+		},
+	}
+	if d, ok := c.ingestionDelay(c.Cwld.LogEvents); ok {
+		fields = append(fields, slack.Field{
+			Title: "Ingestion Delay",
+			Value: d.String(),
+			Short: false,
+		})
+	}
+	fields = append(fields, slack.Field{
+		Title: "Log Messages",
+		Value: slack.CodeBlock(joinedMessages),
+		Short: false,
+	})
 
 	return &[]slack.Payload{
 		{
@@ -65,39 +146,36 @@ func (c *Cwl2slack) getPlainPayloads() (*[]slack.Payload, error) {
 			IconEmoji: ":robot_face:",
 			Attachments: []slack.Attachment{
 				{
-					Title:  ":rotating_light:CloudWatchLogsにてアラートを検知しました",
-					Color:  "danger",
-					Footer: "post by cwl2slack",
-					Fields: []slack.Field{
-						{
-							Title: "Log Group",
-							Value: c.Cwld.LogGroup,
-							Short: false,
-						},
-						{
-							Title: "Log Stream",
-							Value: c.Cwld.LogStream,
-							Short: false,
-						},
-						{
-							Title: "Log Messages",
-							Value: "```\n" + joinedMessages + "\n```",
-							Short: false,
-						},
-					},
+					Title:     ":rotating_light:CloudWatchLogsにてアラートを検知しました",
+					TitleLink: titleLink,
+					Color:     "danger",
+					Footer:    "post by cwl2slack",
+					Timestamp: first.Unix(),
+					Actions:   actions,
+					Fields:    fields,
 				},
 			},
 		},
 	}, nil
 }
 
+// notifiesはログイベントが通知の対象になるかどうかを返します。
+// slowqueryモードでは実行時間が閾値未満のクエリは通知しません。解析できないログイベントは通知時にエラーにするため対象にします
+func (c *Cwl2slack) notifies(e events.CloudwatchLogsLogEvent) bool {
+	if c.Mode != "slowquery" {
+		return true
+	}
+	sq, err := NewSlowQuery(e.Message)
+	return err != nil || sq.QueryTime >= c.Theashold
+}
+
 func (c *Cwl2slack) getSlowQueryPayloads() (*[]slack.Payload, error) {
 
 	// ログイベントの数だけペイロードを作成します
-	payloads := make([]slack.Payload, len(c.Cwld.LogEvents))
+	payloads := make([]slack.Payload, 0, len(c.Cwld.LogEvents))
 
 	//	ログイベントのメッセージを取得します
-	for i, e := range c.Cwld.LogEvents {
+	for _, e := range c.Cwld.LogEvents {
 
 		// スロークエリーの情報を取得します
 		sq, err := NewSlowQuery(e.Message)
@@ -110,59 +188,165 @@ func (c *Cwl2slack) getSlowQueryPayloads() (*[]slack.Payload, error) {
 			continue
 		}
 
-		payloads[i] = slack.Payload{
+		titleLink, actions := c.cloudWatchLinks([]events.CloudwatchLogsLogEvent{e})
+
+		// スロークエリーログの時刻(UTC)を設定されたタイムゾーンで表示します
+		sqTime := sq.Time
+		if t, err := time.Parse(time.RFC3339Nano, sq.Time); err == nil {
+			sqTime = c.formatTime(t)
+		}
+
+		fields := []slack.Field{
+			{
+				Title: "タイムスタンプ",
+				Value: sqTime,
+				Short: true,
+			},
+			{
+				Title: "クエリ実行ユーザ",
+				Value: slack.Escape(sq.User),
+				Short: true,
+			},
+			{
+				Title: "クエリ実行時間",
+				Value: strconv.FormatFloat(sq.QueryTime, 'f', -1, 64),
+				Short: true,
+			},
+			{
+				Title: "通知閾値",
+				Value: "",
+				Short: true,
+			},
+			{
+				Title: "ロック取得までの時間",
+				Value: sq.LockTime,
+				Short: true,
+			},
+			{
+				Title: "クライアントへ送信した行数",
+				Value: sq.RowsSent,
+				Short: true,
+			},
+			{
+				Title: "クエリ実行時にスキャンした行数",
+				Value: sq.RowsExamined,
+				Short: true,
+			},
+		}
+		if d, ok := c.ingestionDelay([]events.CloudwatchLogsLogEvent{e}); ok {
+			fields = append(fields, slack.Field{
+				Title: "取り込み遅延",
+				Value: d.String(),
+				Short: true,
+			})
+		}
+		fields = append(fields, slack.Field{
+			Title: "実行したクエリ",
+			Value: slack.CodeBlock(sq.Query),
+			Short: false,
+		})
+
+		payloads = append(payloads, slack.Payload{
 			Username:  "CloudWatch Logs",
 			IconEmoji: ":turtle:",
 			Attachments: []slack.Attachment{
 				{
-					Title:  fmt.Sprintf(":rotating_light:ロググループ %s にて閾値を超えたスロークエリーが検知されました", c.Cwld.LogGroup),
-					Color:  "danger",
-					Footer: "post by cwl2slack",
-					Fields: []slack.Field{
-						{
-							Title: "タイムスタンプ",
-							Value: sq.Time,
-							Short: true,
-						},
-						{
-							Title: "クエリ実行ユーザ",
-							Value: sq.User,
-							Short: true,
-						},
-						{
-							Title: "クエリ実行時間",
-							Value: strconv.FormatFloat(sq.QueryTime, 'f', -1, 64),
-							Short: true,
-						},
-						{
-							Title: "通知閾値",
-							Value: "",
-							Short: true,
-						},
-						{
-							Title: "ロック取得までの時間",
-							Value: sq.LockTime,
-							Short: true,
-						},
-						{
-							Title: "クライアントへ送信した行数",
-							Value: sq.RowsSent,
-							Short: true,
-						},
-						{
-							Title: "クエリ実行時にスキャンした行数",
-							Value: sq.RowsExamined,
-							Short: true,
-						},
-						{
-							Title: "実行したクエリ",
-							Value: "```\n" + sq.Query + "\n```",
-							Short: false,
-						},
-					},
+					Title:     fmt.Sprintf(":rotating_light:ロググループ %s にて閾値を超えたスロークエリーが検知されました", slack.Escape(c.Cwld.LogGroup)),
+					TitleLink: titleLink,
+					Color:     "danger",
+					Footer:    "post by cwl2slack",
+					Timestamp: time.UnixMilli(e.Timestamp).Unix(),
+					Actions:   actions,
+					Fields:    fields,
 				},
 			},
+		})
+	}
+	return &payloads, nil
+}
+
+// stacktraceモードのSlack通知に必要なペイロードの配列を返します
+// スタックトレースの全文は長くなるため、Slack側で折りたたまれるようにアタッチメントのTextに入れます
+func (c *Cwl2slack) getStackTracePayloads() (*[]slack.Payload, error) {
+
+	// ログイベントの数だけペイロードを作成します
+	payloads := make([]slack.Payload, 0, len(c.Cwld.LogEvents))
+
+	for _, e := range c.Cwld.LogEvents {
+
+		// スタックトレースの情報を取得します
+		// スタックトレースでないログイベントは他のログイベントの通知を妨げないように、plainモードの形式で通知します
+		st, err := NewStackTrace(e.Message, c.FrameworkPrefixes)
+		if err != nil {
+			plain, err := c.withLogEvents([]events.CloudwatchLogsLogEvent{e}).getPlainPayloads()
+			if err != nil {
+				return nil, err
+			}
+			payloads = append(payloads, *plain...)
+			continue
+		}
+
+		fields := []slack.Field{
+			{
+				Title: "例外",
+				Value: slack.Escape(st.Exception),
+				Short: true,
+			},
+			{
+				Title: "言語",
+				Value: st.Language,
+				Short: true,
+			},
+			{
+				Title: "メッセージ",
+				Value: slack.Escape(st.Message),
+				Short: false,
+			},
+			{
+				Title: "発生箇所",
+				Value: slack.InlineCode(st.Frame),
+				Short: false,
+			},
+		}
+		if st.RootCause != "" {
+			fields = append(fields, slack.Field{
+				Title: "根本原因",
+				Value: slack.Escape(st.RootCause),
+				Short: false,
+			})
 		}
+		fields = append(fields, slack.Field{
+			Title: "発生時刻",
+			Value: c.formatTime(time.UnixMilli(e.Timestamp)),
+			Short: true,
+		})
+		if d, ok := c.ingestionDelay([]events.CloudwatchLogsLogEvent{e}); ok {
+			fields = append(fields, slack.Field{
+				Title: "取り込み遅延",
+				Value: d.String(),
+				Short: true,
+			})
+		}
+
+		titleLink, actions := c.cloudWatchLinks([]events.CloudwatchLogsLogEvent{e})
+
+		payloads = append(payloads, slack.Payload{
+			Username:  "CloudWatch Logs",
+			IconEmoji: ":boom:",
+			Attachments: []slack.Attachment{
+				{
+					Title:      fmt.Sprintf(":rotating_light:ロググループ %s にて例外が検知されました", slack.Escape(c.Cwld.LogGroup)),
+					TitleLink:  titleLink,
+					Color:      "danger",
+					Footer:     "post by cwl2slack",
+					Fields:     fields,
+					Text:       slack.CodeBlock(st.Trace),
+					MarkdownIn: []string{"text"},
+					Timestamp:  time.UnixMilli(e.Timestamp).Unix(),
+					Actions:    actions,
+				},
+			},
+		})
 	}
 	return &payloads, nil
 }
@@ -2,6 +2,7 @@ package cwl2slack
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -69,6 +70,25 @@ func TestNewCwl2slack(t *testing.T) {
 	}
 }
 
+func TestIsControlMessage(t *testing.T) {
+	testCases := []struct {
+		name        string
+		messageType string
+		want        bool
+	}{
+		{name: "到達確認のメッセージ", messageType: "CONTROL_MESSAGE", want: true},
+		{name: "ログイベント", messageType: "DATA_MESSAGE", want: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsControlMessage(&events.CloudwatchLogsData{MessageType: tt.messageType}); got != tt.want {
+				t.Fatalf("got: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetPlainPayload(t *testing.T) {
 	testCloudwatchLogsData := events.CloudwatchLogsData{
 		LogGroup:  "testLogGroup",
@@ -103,6 +123,11 @@ func TestGetPlainPayload(t *testing.T) {
 					Value: "testLogStream",
 					Short: false,
 				},
+				{
+					Title: "Time",
+					Value: "1970-01-01 00:00:00 UTC",
+					Short: false,
+				},
 				{
 					Title: "Log Messages",
 					Value: "```\nmessage1\nmessage2\n```",
@@ -170,7 +195,7 @@ func TestGetSlowQueryPayload(t *testing.T) {
 			// テスト対象のメソッドを実行してFields部分を取得
 			p, err := c.getPlainPayloads()
 			got := len(*p)
-			t.Logf("got: %v", len(got))
+			t.Logf("got: %v", got)
 
 			// 正常系のテストケース
 			if tt.isNormal {
@@ -189,3 +214,129 @@ func TestGetSlowQueryPayload(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStackTracePayload(t *testing.T) {
+	testCloudwatchLogsData := events.CloudwatchLogsData{
+		LogGroup:  "testLogGroup",
+		LogStream: "testLogStream",
+		LogEvents: []events.CloudwatchLogsLogEvent{
+			{
+				Message: "java.lang.IllegalStateException: boom\n\tat com.example.App.run(App.java:1)\nCaused by: java.io.IOException: disk full\n\tat java.io.File.write(File.java:1)",
+			},
+		},
+	}
+
+	// AttachmentsのFields以外は固定値なのでFieldsのみをテストします
+	testCases := []struct {
+		name     string
+		isNormal bool
+		want     []slack.Field
+	}{
+		{
+			name:     "[正常系]テスト",
+			isNormal: true,
+			want: []slack.Field{
+				{Title: "例外", Value: "java.lang.IllegalStateException", Short: true},
+				{Title: "言語", Value: "java", Short: true},
+				{Title: "メッセージ", Value: "boom", Short: false},
+				{Title: "発生箇所", Value: "`com.example.App.run(App.java:1)`", Short: false},
+				{Title: "根本原因", Value: "java.io.IOException: disk full", Short: false},
+				{Title: "発生時刻", Value: "1970-01-01 00:00:00 UTC", Short: true},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			// cwl2slackインスタンスの作成
+			c, _ := NewCwl2slack("stacktrace", 0, &testCloudwatchLogsData)
+
+			// テスト対象のメソッドを実行してFields部分を取得
+			p, err := c.getStackTracePayloads()
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got := (*p)[0].Attachments[0].Fields
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("\n got: %+v;\nwant: %+v", got, tt.want)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+// スタックトレースでないログイベントがあっても、他のログイベントを通知できることを確認します
+func TestGetStackTracePayloadsWithPlainMessage(t *testing.T) {
+	testCloudwatchLogsData := events.CloudwatchLogsData{
+		LogGroup:  "testLogGroup",
+		LogStream: "testLogStream",
+		LogEvents: []events.CloudwatchLogsLogEvent{
+			{Message: "java.lang.IllegalStateException: boom\n\tat com.example.App.run(App.java:1)"},
+			{Message: "[ERROR] connection reset"},
+		},
+	}
+
+	c, _ := NewCwl2slack("stacktrace", 0, &testCloudwatchLogsData)
+	p, err := c.getStackTracePayloads()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*p) != 2 {
+		t.Fatalf("unexpected payloads: %+v", *p)
+	}
+	if got := (*p)[0].Attachments[0].Fields[0]; got.Title != "例外" {
+		t.Fatalf("unexpected field: %+v", got)
+	}
+	fields := (*p)[1].Attachments[0].Fields
+	if got := fields[len(fields)-1]; got.Title != "Log Messages" || !strings.Contains(got.Value, "connection reset") {
+		t.Fatalf("unexpected field: %+v", got)
+	}
+
+	notifications, err := c.GetNotifications()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("unexpected notifications: %+v", notifications)
+	}
+}
+
+// 細工されたログメッセージでメンションやリンクが発生しないことを確認します
+func TestPayloadsEscapeUntrustedText(t *testing.T) {
+	testCloudwatchLogsData := events.CloudwatchLogsData{
+		LogGroup:  "<!channel>",
+		LogStream: "<@U0123456>",
+		LogEvents: []events.CloudwatchLogsLogEvent{
+			{Message: "java.lang.IllegalStateException: <!here> ```\n\tat com.example.App.run(App.java:1)\nCaused by: java.io.IOException: <https://evil.example.com|click>"},
+		},
+	}
+
+	for _, mode := range []string{"plain", "stacktrace"} {
+		t.Run(mode, func(t *testing.T) {
+			c, _ := NewCwl2slack(mode, 0, &testCloudwatchLogsData)
+			p, err := c.GetSlackPayloads()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			a := (*p)[0].Attachments[0]
+			texts := []string{a.Title, a.Text}
+			for _, f := range a.Fields {
+				texts = append(texts, f.Value)
+			}
+			for _, s := range texts {
+				if strings.ContainsAny(s, "<>") {
+					t.Fatalf("unescaped control character in %q", s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package cwl2slack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// 実行ごとに変わる値を取り除くための正規表現です。UUID、16進数、数値の順に置き換えます
+var (
+	uuidPattern   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	hexPattern    = regexp.MustCompile(`(?i)\b(0x)?[0-9a-f]*[0-9][0-9a-f]*\b`)
+	numberPattern = regexp.MustCompile(`\d+`)
+)
+
+// normalizeMessageはメッセージからID、時刻、数値など実行ごとに変わる値を取り除き、空白をまとめます
+func normalizeMessage(message string) string {
+	s := uuidPattern.ReplaceAllString(message, "<uuid>")
+	s = hexPattern.ReplaceAllStringFunc(s, func(m string) string {
+		// 16進数として扱うのは0xで始まるか、8文字以上のものだけです
+		if strings.HasPrefix(strings.ToLower(m), "0x") || len(m) >= 8 {
+			return "<hex>"
+		}
+		return m
+	})
+	s = numberPattern.ReplaceAllString(s, "<n>")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Fingerprintは同じ原因のログに対して同じ値になる識別子を返します。
+// ロググループとモード、正規化したメッセージのハッシュ値で、メッセージの順序や重複には影響されません
+func Fingerprint(logGroup string, mode string, messages ...string) string {
+	normalized := make([]string, len(messages))
+	for i, m := range messages {
+		normalized[i] = normalizeMessage(m)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+
+	h := sha256.New()
+	h.Write([]byte(logGroup + "\n" + mode))
+	for _, m := range normalized {
+		h.Write([]byte("\n" + m))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -0,0 +1,53 @@
+package cwl2slack
+
+import "testing"
+
+func TestNormalizeMessage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{name: "数値", message: "order 12345 not found", want: "order <n> not found"},
+		{name: "UUID", message: "request 3f2b8c1e-9d4a-4b7e-8f00-1a2b3c4d5e6f failed", want: "request <uuid> failed"},
+		{name: "16進数", message: "at 0x7ffd1234 commit deadbeef01", want: "at <hex> commit <hex>"},
+		{name: "単語は残す", message: "cafe  added\n\tbad", want: "cafe added bad"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeMessage(tt.message); got != tt.want {
+				t.Fatalf("got: %q, want: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint("/aws/lambda/app", "plain", "order 1 not found", "timeout")
+
+	testCases := []struct {
+		name     string
+		logGroup string
+		mode     string
+		messages []string
+		same     bool
+	}{
+		{name: "数値だけが異なる場合", logGroup: "/aws/lambda/app", mode: "plain", messages: []string{"order 2 not found", "timeout"}, same: true},
+		{name: "順序と重複が異なる場合", logGroup: "/aws/lambda/app", mode: "plain", messages: []string{"timeout", "order 3 not found", "timeout"}, same: true},
+		{name: "ロググループが異なる場合", logGroup: "/aws/lambda/other", mode: "plain", messages: []string{"order 1 not found", "timeout"}, same: false},
+		{name: "メッセージが異なる場合", logGroup: "/aws/lambda/app", mode: "plain", messages: []string{"user 1 not found", "timeout"}, same: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fingerprint(tt.logGroup, tt.mode, tt.messages...)
+			if (got == base) != tt.same {
+				t.Fatalf("got: %s, base: %s", got, base)
+			}
+			if len(got) != 32 {
+				t.Fatalf("unexpected length: %d", len(got))
+			}
+		})
+	}
+}
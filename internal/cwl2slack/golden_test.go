@@ -0,0 +1,94 @@
+package cwl2slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGetSlackPayloadsBlocks(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mode   string
+		golden string
+		cwld   events.CloudwatchLogsData
+	}{
+		{
+			name:   "[正常系]plainモード",
+			mode:   "plain",
+			golden: "plain_blocks.golden",
+			cwld: events.CloudwatchLogsData{
+				LogGroup:  "testLogGroup",
+				LogStream: "testLogStream",
+				LogEvents: []events.CloudwatchLogsLogEvent{
+					{Timestamp: 1716792813043, Message: "[ERROR] First test message"},
+					{Timestamp: 1716792813043, Message: "[ERROR] Second <!channel> message"},
+				},
+			},
+		},
+		{
+			name:   "[正常系]slowqueryモード",
+			mode:   "slowquery",
+			golden: "slowquery_blocks.golden",
+			cwld: events.CloudwatchLogsData{
+				LogGroup:  "testLogGroup",
+				LogStream: "testLogStream",
+				LogEvents: []events.CloudwatchLogsLogEvent{
+					{
+						Timestamp: 1716792813043,
+						Message:   "# Time: 2024-05-27T06:53:33.043104Z\n# User@Host: wsprodadminuser[wsprodadminuser] @ [172.17.0.178] Id: 1436601\n# Query_time: 4.275485 Lock_time: 0.000002 Rows_sent: 58 Rows_examined: 12158\nuse work_prod;\nSET timestamp=1716792808;\nSELECT `mp`.`project_id` FROM `meeting_project` `mp` WHERE `mp`.`prime_company_id` IN ('0000011131');",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := NewCwl2slack(tt.mode, 0, &tt.cwld)
+			c.Region = "ap-northeast-1"
+
+			p, err := c.GetSlackPayloads()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Block Kit形式に変換したペイロードをゴールデンファイルと比較します
+			blocks := make([]slack.Payload, len(*p))
+			for i, e := range *p {
+				blocks[i] = slack.PayloadToBlocks(e)
+			}
+			if blocks[0].Attachments != nil {
+				t.Fatalf("attachments should be empty: %+v", blocks[0].Attachments)
+			}
+
+			got, err := json.MarshalIndent(blocks, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("\n got: %s\nwant: %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package cwl2slack
+
+import (
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/awsconsole"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// DefaultInsightsWindowはLogs Insightsのリンクでログイベントの前後に含める既定の時間です
+const DefaultInsightsWindow = 5 * time.Minute
+
+// cloudWatchLinksはログイベントをCloudWatchコンソールで開くためのURLとボタンを返します。
+// Regionが設定されていない場合はリンクを作成しません
+func (c *Cwl2slack) cloudWatchLinks(logEvents []events.CloudwatchLogsLogEvent) (string, []slack.Action) {
+	if c.Region == "" || len(logEvents) == 0 {
+		return "", nil
+	}
+
+	// ログイベントの最初と最後のタイムスタンプを取得します
+	start, last := eventTimeRange(logEvents)
+	end := last.Add(time.Millisecond)
+
+	window := c.InsightsWindow
+	if window == 0 {
+		window = DefaultInsightsWindow
+	}
+
+	logEventsURL := awsconsole.LogEventsURL(c.Region, c.Cwld.LogGroup, c.Cwld.LogStream, start, end)
+	insightsURL := awsconsole.LogsInsightsURL(c.Region, []string{c.Cwld.LogGroup}, start.Add(-window), end.Add(window), awsconsole.DefaultInsightsQuery)
+
+	return logEventsURL, []slack.Action{
+		{
+			Type:  "button",
+			Text:  "Open in CloudWatch",
+			Url:   logEventsURL,
+			Style: "primary",
+		},
+		{
+			Type: "button",
+			Text: "Logs Insights",
+			Url:  insightsURL,
+		},
+	}
+}
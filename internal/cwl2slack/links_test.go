@@ -0,0 +1,63 @@
+package cwl2slack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCloudWatchLinks(t *testing.T) {
+	testCloudwatchLogsData := events.CloudwatchLogsData{
+		LogGroup:  "/aws/lambda/my-func",
+		LogStream: "testLogStream",
+		LogEvents: []events.CloudwatchLogsLogEvent{
+			{Timestamp: 1716792813043, Message: "message1"},
+			{Timestamp: 1716792812000, Message: "message2"},
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		region      string
+		wantLink    string
+		wantActions int
+	}{
+		{
+			name:        "[正常系]リージョンが設定されている場合",
+			region:      "ap-northeast-1",
+			wantLink:    "https://ap-northeast-1.console.aws.amazon.com/cloudwatch/home?region=ap-northeast-1#logsV2:log-groups/log-group/$252Faws$252Flambda$252Fmy-func/log-events/testLogStream$3Fstart$3D1716792812000$26end$3D1716792813044",
+			wantActions: 2,
+		},
+		{
+			name:        "[正常系]リージョンが設定されていない場合",
+			region:      "",
+			wantLink:    "",
+			wantActions: 0,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := NewCwl2slack("plain", 0, &testCloudwatchLogsData)
+			c.Region = tt.region
+
+			p, err := c.GetSlackPayloads()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			a := (*p)[0].Attachments[0]
+
+			if a.TitleLink != tt.wantLink {
+				t.Fatalf("\n got: %v;\nwant: %v", a.TitleLink, tt.wantLink)
+			}
+			if len(a.Actions) != tt.wantActions {
+				t.Fatalf("unexpected actions: %+v", a.Actions)
+			}
+			// Logs Insightsのリンクはログイベントの前後5分を含みます
+			if tt.wantActions > 0 && !strings.Contains(a.Actions[1].Url, "end~'2024-05-27T06*3a58*3a33.044Z~start~'2024-05-27T06*3a48*3a32.000Z") {
+				t.Fatalf("unexpected insights url: %v", a.Actions[1].Url)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package cwl2slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/mute"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// MuteRuleは通知しないログイベントの条件と期間です。
+// 条件を指定しない項目は全てに一致し、期間を指定しない場合は常に通知しません
+type MuteRule struct {
+	Name string `json:"name"`
+
+	// 通知しない条件。LogGroupsとAlarmsは*をワイルドカードとして使えます。
+	// Patternはログメッセージの正規表現、Usersはslowqueryモードのユーザー名、Alarmsはalarmモードのアラーム名です。
+	// Alarmsを指定したルールはログイベントに、LogGroups、Pattern、Usersを指定したルールはアラームに一致しません
+	LogGroups []string `json:"log_groups,omitempty"`
+	Modes     []string `json:"modes,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Users     []string `json:"users,omitempty"`
+	Alarms    []string `json:"alarms,omitempty"`
+
+	// 通知しない期間。日時を指定する期間と、曜日と時刻で繰り返す期間を指定できます
+	Windows   []MuteWindow   `json:"windows,omitempty"`
+	Schedules []MuteSchedule `json:"schedules,omitempty"`
+
+	// 繰り返す期間の時刻のタイムゾーン(例: Asia/Tokyo)。未設定の場合はParseMuteRulesに指定したタイムゾーンです
+	TimeZone string `json:"time_zone,omitempty"`
+
+	// 通知しないログイベントの扱い(drop: 捨てる、rollup: 期間の終了後に件数を通知する)。未設定の場合はdropです
+	Action string `json:"action,omitempty"`
+
+	pattern  *regexp.Regexp
+	location *time.Location
+}
+
+// MuteWindowは通知しない期間です。Endは期間に含みません
+type MuteWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// MuteScheduleは曜日と時刻で繰り返す通知しない期間です。
+// Daysは開始する曜日(sun, mon, tue, wed, thu, fri, sat)で、未設定の場合は毎日です。
+// StartとEndはHH:MM形式で、EndがStart以前の場合は翌日のEndまでです
+type MuteSchedule struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// MutedRetentionは通知しなかった件数を保持する期間です。
+// 期間の終了後、この期間内にLambdaが呼び出されなかった場合は件数を通知しません
+const MutedRetention = 24 * time.Hour
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseMuteRulesはJSONの配列からMuteRuleの配列を返します。locは繰り返す期間のタイムゾーンの既定値です
+func ParseMuteRules(s string, loc *time.Location) ([]MuteRule, error) {
+	var rules []MuteRule
+	if err := json.Unmarshal([]byte(s), &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse mute rules: %w", err)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("mute#%d", i)
+		}
+
+		switch r.Action {
+		case "":
+			r.Action = "drop"
+		case "drop":
+		case "rollup":
+			// 終了しない期間の件数は通知できないため、期間を必須とします
+			if len(r.Windows) == 0 && len(r.Schedules) == 0 {
+				return nil, fmt.Errorf("mute rule %s: windows or schedules are required for rollup", r.Name)
+			}
+		default:
+			return nil, fmt.Errorf("mute rule %s: invalid action: %s", r.Name, r.Action)
+		}
+
+		if r.Pattern != "" {
+			p, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("mute rule %s: invalid pattern: %w", r.Name, err)
+			}
+			r.pattern = p
+		}
+
+		r.location = loc
+		if r.TimeZone != "" {
+			l, err := time.LoadLocation(r.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("mute rule %s: invalid time zone: %w", r.Name, err)
+			}
+			r.location = l
+		}
+
+		for _, w := range r.Windows {
+			if !w.End.After(w.Start) {
+				return nil, fmt.Errorf("mute rule %s: window end must be after start", r.Name)
+			}
+		}
+		for _, s := range r.Schedules {
+			if _, _, err := s.clock(); err != nil {
+				return nil, fmt.Errorf("mute rule %s: %w", r.Name, err)
+			}
+			for _, d := range s.Days {
+				if !slices.Contains(weekdays, strings.ToLower(d)) {
+					return nil, fmt.Errorf("mute rule %s: invalid day: %s", r.Name, d)
+				}
+			}
+		}
+	}
+	return rules, nil
+}
+
+// clockはStartとEndを0時からの時間にして返します
+func (s MuteSchedule) clock() (time.Duration, time.Duration, error) {
+	parse := func(v string) (time.Duration, error) {
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time: %s", v)
+		}
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+	}
+	start, err := parse(s.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parse(s.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		end += 24 * time.Hour
+	}
+	return start, end, nil
+}
+
+// occurrencesはfromからtoまでの日に開始する、繰り返す期間の一覧を返します
+func (s MuteSchedule) occurrences(from time.Time, to time.Time, loc *time.Location) []MuteWindow {
+	start, end, err := s.clock()
+	if err != nil {
+		return nil
+	}
+
+	var windows []MuteWindow
+	from = from.In(loc)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if len(s.Days) > 0 && !slices.ContainsFunc(s.Days, func(d string) bool { return strings.ToLower(d) == weekdays[day.Weekday()] }) {
+			continue
+		}
+		// 夏時間の切り替えがあっても時刻がずれないように、日付と時刻から期間を作ります
+		ws := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc).Add(start)
+		we := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc).Add(end)
+		windows = append(windows, MuteWindow{Start: ws, End: we})
+	}
+	return windows
+}
+
+// windowsはfromからtoまでに重なる期間の一覧を返します
+func (r MuteRule) windows(from time.Time, to time.Time) []MuteWindow {
+	var windows []MuteWindow
+	for _, w := range r.Windows {
+		if w.Start.Before(to) && w.End.After(from) {
+			windows = append(windows, w)
+		}
+	}
+	for _, s := range r.Schedules {
+		// 前日に開始して日をまたぐ期間も含めます
+		for _, w := range s.occurrences(from.AddDate(0, 0, -1), to, r.location) {
+			if w.Start.Before(to) && w.End.After(from) {
+				windows = append(windows, w)
+			}
+		}
+	}
+	return windows
+}
+
+// Activeは時刻tを含む期間を返します。期間を指定しないルールは常に有効で、ゼロ値の期間を返します
+func (r MuteRule) Active(t time.Time) (MuteWindow, bool) {
+	if len(r.Windows) == 0 && len(r.Schedules) == 0 {
+		return MuteWindow{}, true
+	}
+	for _, w := range r.windows(t, t.Add(time.Nanosecond)) {
+		if !t.Before(w.Start) && t.Before(w.End) {
+			return w, true
+		}
+	}
+	return MuteWindow{}, false
+}
+
+// Endedはfromより後、to以前に終了した期間を返します
+func (r MuteRule) Ended(from time.Time, to time.Time) []MuteWindow {
+	var ended []MuteWindow
+	for _, w := range r.windows(from, to) {
+		if w.End.After(from) && !w.End.After(to) {
+			ended = append(ended, w)
+		}
+	}
+	return ended
+}
+
+// Matchはログイベントが条件に一致する場合にtrueを返します
+func (r MuteRule) Match(logGroup string, mode string, message string) bool {
+	if len(r.Alarms) > 0 {
+		return false
+	}
+	if len(r.Modes) > 0 && !slices.Contains(r.Modes, mode) {
+		return false
+	}
+	if len(r.LogGroups) > 0 && !slices.ContainsFunc(r.LogGroups, func(p string) bool { return matchGlob(p, logGroup) }) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(message) {
+		return false
+	}
+	if len(r.Users) > 0 {
+		sq, err := NewSlowQuery(message)
+		if err != nil || !slices.Contains(r.Users, sq.User) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchAlarmはアラームが条件に一致する場合にtrueを返します
+func (r MuteRule) MatchAlarm(a *Alarm) bool {
+	if len(r.LogGroups) > 0 || r.pattern != nil || len(r.Users) > 0 {
+		return false
+	}
+	if len(r.Modes) > 0 && !slices.Contains(r.Modes, AlarmMode) {
+		return false
+	}
+	if len(r.Alarms) > 0 && !slices.ContainsFunc(r.Alarms, func(p string) bool { return matchGlob(p, a.AlarmName) }) {
+		return false
+	}
+	return true
+}
+
+// MuteKeyは期間の終了後に件数を通知するために、通知しなかった件数を記録するキーを返します
+func (r MuteRule) MuteKey(w MuteWindow) string {
+	return fmt.Sprintf("%s#%d", r.Name, w.Start.Unix())
+}
+
+// Mutedは通知しなかったログイベントと、一致したルールと期間です。アラームの場合はEventはゼロ値です
+type Muted struct {
+	Rule   MuteRule
+	Window MuteWindow
+	Event  events.CloudwatchLogsLogEvent
+}
+
+// Muteはルールに一致し、ログイベントの時刻が期間内のログイベントを通知の対象から除き、除いたログイベントを返します。
+// 閾値未満のスロークエリーのように元々通知しないログイベントは、通知しなかった件数に含めないように除きません
+func (c *Cwl2slack) Mute(rules []MuteRule) []Muted {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var muted []Muted
+	var kept []events.CloudwatchLogsLogEvent
+	for _, e := range c.Cwld.LogEvents {
+		if !c.notifies(e) {
+			kept = append(kept, e)
+			continue
+		}
+		m, ok := c.mute(rules, e)
+		if ok {
+			muted = append(muted, m)
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	cwld := *c.Cwld
+	cwld.LogEvents = kept
+	c.Cwld = &cwld
+	return muted
+}
+
+// muteはログイベントに最初に一致したルールを返します
+func (c *Cwl2slack) mute(rules []MuteRule, e events.CloudwatchLogsLogEvent) (Muted, bool) {
+	t := time.UnixMilli(e.Timestamp)
+	for _, r := range rules {
+		if !r.Match(c.Cwld.LogGroup, c.Mode, e.Message) {
+			continue
+		}
+		if w, ok := r.Active(t); ok {
+			return Muted{Rule: r, Window: w, Event: e}, true
+		}
+	}
+	return Muted{}, false
+}
+
+// MuteAlarmはアラームの状態の変化に最初に一致したルールを返します。
+// 状態が変化した時刻で期間を判定し、時刻が分からない場合はnowを使います
+func MuteAlarm(rules []MuteRule, a *Alarm, now time.Time) (Muted, bool) {
+	t := a.ChangedAt()
+	if t.IsZero() {
+		t = now
+	}
+	for _, r := range rules {
+		if !r.MatchAlarm(a) {
+			continue
+		}
+		if w, ok := r.Active(t); ok {
+			return Muted{Rule: r, Window: w}, true
+		}
+	}
+	return Muted{}, false
+}
+
+// SuppressedNotificationは期間中に通知しなかったログイベントの件数の通知を返します
+func SuppressedNotification(r MuteRule, w MuteWindow, count int, logGroups []string, modes []string, loc *time.Location) notifier.Notification {
+	if loc == nil {
+		loc = time.UTC
+	}
+	title := fmt.Sprintf(":mute: %d events suppressed during %s", count, slack.Escape(r.Name))
+	// alarmモードはロググループの代わりにアラーム名を記録します
+	sourceTitle := "LogGroup"
+	if len(modes) == 1 && modes[0] == AlarmMode {
+		sourceTitle = "Alarm"
+	}
+	period := fmt.Sprintf("%s - %s", w.Start.In(loc).Format("2006-01-02 15:04"), w.End.In(loc).Format("2006-01-02 15:04 MST"))
+
+	return notifier.Notification{
+		Payload: slack.Payload{
+			Attachments: []slack.Attachment{
+				{
+					Fallback: title,
+					Color:    "#439FE0",
+					Title:    title,
+					Fields: []slack.Field{
+						{Title: "期間", Value: period},
+						{Title: sourceTitle, Value: slack.Escape(strings.Join(logGroups, ", "))},
+						{Title: "件数", Value: fmt.Sprint(count), Short: true},
+					},
+				},
+			},
+		},
+		Severity: notifier.SeverityInfo,
+		Source:   strings.Join(logGroups, ","),
+		Details: map[string]any{
+			"mute_rule":  r.Name,
+			"suppressed": count,
+			"log_groups": logGroups,
+			"modes":      modes,
+		},
+		Timestamp: w.End,
+	}
+}
+
+// RecordMutedはrollupのルールで通知しなかったログイベントの件数を、ルールと期間ごとに記録します。
+// アラームの場合はlogGroupにアラーム名、modeにAlarmModeを指定します
+func RecordMuted(ctx context.Context, store mute.Store, muted []Muted, logGroup string, mode string) error {
+	counts := map[string]mute.Count{}
+	var keys []string
+	for _, m := range muted {
+		if m.Rule.Action != "rollup" {
+			continue
+		}
+		key := m.Rule.MuteKey(m.Window)
+		if _, ok := counts[key]; !ok {
+			keys = append(keys, key)
+		}
+		counts[key] = mute.Count{Events: counts[key].Events + 1, LogGroups: []string{logGroup}, Modes: []string{mode}}
+	}
+
+	for _, key := range keys {
+		if err := store.Add(ctx, key, counts[key], MutedRetention); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Suppressedは期間中に通知しなかったログイベントの件数の通知と、通知しなかったログイベントのロググループとモードです。
+// Keyは件数を記録していたキーで、通知できなかった場合に記録に戻すために使います
+type Suppressed struct {
+	Notification notifier.Notification
+	Key          string
+	Events       int
+	LogGroups    []string
+	Modes        []string
+}
+
+// TakeSuppressedはnowまでに終了したrollupのルールの期間について、通知しなかったログイベントの件数の通知を返します。
+// 件数は記録から削除するため、同じ期間の件数を2回通知しません。通知できなかった場合はRestoreSuppressedで記録に戻します。
+// 件数の通知は期間の終了後に呼び出された時に送信するため、MutedRetention以内にLambdaが呼び出されなかった場合は通知しません
+func TakeSuppressed(ctx context.Context, store mute.Store, rules []MuteRule, now time.Time, loc *time.Location) ([]Suppressed, error) {
+	var suppressed []Suppressed
+	for _, r := range rules {
+		if r.Action != "rollup" {
+			continue
+		}
+		for _, w := range r.Ended(now.Add(-MutedRetention), now) {
+			c, err := store.Take(ctx, r.MuteKey(w))
+			if err != nil {
+				return suppressed, err
+			}
+			if c.Events == 0 {
+				continue
+			}
+			key := r.MuteKey(w)
+			n := SuppressedNotification(r, w, c.Events, c.LogGroups, c.Modes, loc)
+			// 記録に戻して再び通知する場合に、送信済みの通知先に重複して送信しないように、キーを通知のIDにします
+			n.EventIDs = []string{"mute:" + key}
+			suppressed = append(suppressed, Suppressed{
+				Notification: n,
+				Key:          key,
+				Events:       c.Events,
+				LogGroups:    c.LogGroups,
+				Modes:        c.Modes,
+			})
+		}
+	}
+	return suppressed, nil
+}
+
+// RestoreSuppressedは通知できなかった件数を記録に戻し、次に呼び出された時に再び通知できるようにします
+func RestoreSuppressed(ctx context.Context, store mute.Store, suppressed []Suppressed) error {
+	for _, s := range suppressed {
+		c := mute.Count{Events: s.Events, LogGroups: s.LogGroups, Modes: s.Modes}
+		if err := store.Add(ctx, s.Key, c, MutedRetention); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package cwl2slack
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/mute"
+)
+
+func TestParseMuteRules(t *testing.T) {
+	testCases := []struct {
+		name     string
+		json     string
+		isNormal bool
+	}{
+		{name: "[正常系]期間とスケジュール", json: `[{"name":"maintenance","log_groups":["/aws/rds/*"],"windows":[{"start":"2024-06-01T01:00:00+09:00","end":"2024-06-01T05:00:00+09:00"}],"schedules":[{"days":["sun"],"start":"22:00","end":"02:00"}],"time_zone":"Asia/Tokyo","action":"rollup"}]`, isNormal: true},
+		{name: "[正常系]期間を指定しない", json: `[{"pattern":"healthcheck"}]`, isNormal: true},
+		{name: "[異常系]未対応の扱い", json: `[{"action":"ignore"}]`, isNormal: false},
+		{name: "[異常系]期間を指定しないrollup", json: `[{"action":"rollup"}]`, isNormal: false},
+		{name: "[異常系]正規表現が不正", json: `[{"pattern":"("}]`, isNormal: false},
+		{name: "[異常系]タイムゾーンが不正", json: `[{"time_zone":"Mars/Olympus"}]`, isNormal: false},
+		{name: "[異常系]時刻が不正", json: `[{"schedules":[{"start":"25:00","end":"02:00"}]}]`, isNormal: false},
+		{name: "[異常系]曜日が不正", json: `[{"schedules":[{"days":["someday"],"start":"01:00","end":"02:00"}]}]`, isNormal: false},
+		{name: "[異常系]終了が開始より前", json: `[{"windows":[{"start":"2024-06-01T05:00:00Z","end":"2024-06-01T01:00:00Z"}]}]`, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMuteRules(tt.json, time.UTC)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestMuteRuleActive(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	rules, err := ParseMuteRules(`[
+		{"name":"window","windows":[{"start":"2024-06-01T01:00:00+09:00","end":"2024-06-01T05:00:00+09:00"}]},
+		{"name":"sunday-night","schedules":[{"days":["sun"],"start":"22:00","end":"02:00"}]},
+		{"name":"always"}
+	]`, tokyo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	window, schedule, always := rules[0], rules[1], rules[2]
+
+	testCases := []struct {
+		name  string
+		rule  MuteRule
+		time  string
+		want  bool
+		start string
+	}{
+		{name: "期間内", rule: window, time: "2024-06-01T03:00:00+09:00", want: true, start: "2024-06-01T01:00:00+09:00"},
+		{name: "期間の終了時刻は含まない", rule: window, time: "2024-06-01T05:00:00+09:00", want: false},
+		{name: "日曜日の夜", rule: schedule, time: "2024-06-02T23:00:00+09:00", want: true, start: "2024-06-02T22:00:00+09:00"},
+		{name: "日をまたいだ月曜日の深夜", rule: schedule, time: "2024-06-03T01:59:00+09:00", want: true, start: "2024-06-02T22:00:00+09:00"},
+		{name: "UTCでは日曜日でも東京では月曜日の夜", rule: schedule, time: "2024-06-03T13:30:00Z", want: false},
+		{name: "土曜日の夜", rule: schedule, time: "2024-06-01T23:00:00+09:00", want: false},
+		{name: "期間を指定しない", rule: always, time: "2024-06-01T03:00:00Z", want: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339, tt.time)
+			w, got := tt.rule.Active(at)
+			if got != tt.want {
+				t.Fatalf("got: %v, want: %v", got, tt.want)
+			}
+			if tt.start != "" {
+				start, _ := time.Parse(time.RFC3339, tt.start)
+				if !w.Start.Equal(start) {
+					t.Fatalf("unexpected window: %+v", w)
+				}
+			}
+		})
+	}
+}
+
+func TestMuteRuleEnded(t *testing.T) {
+	rules, _ := ParseMuteRules(`[{"schedules":[{"start":"01:00","end":"03:00"}]}]`, time.UTC)
+	now := time.Date(2024, 6, 1, 4, 0, 0, 0, time.UTC)
+
+	got := rules[0].Ended(now.Add(-24*time.Hour), now)
+	if len(got) != 1 || !got[0].End.Equal(time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected windows: %+v", got)
+	}
+	// 終了していない期間は含まない
+	if got := rules[0].Ended(now.Add(-24*time.Hour), time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)); len(got) != 0 {
+		t.Fatalf("unexpected windows: %+v", got)
+	}
+}
+
+func TestMuteRuleMatch(t *testing.T) {
+	rules, _ := ParseMuteRules(`[{"log_groups":["/aws/rds/*"],"modes":["slowquery"],"pattern":"FROM .task.","users":["batch"]}]`, time.UTC)
+	r := rules[0]
+	batch := strings.Replace(fmt.Sprintf(testSlowQueryMessage, "4.2", "1716792808", "1"), "admin[admin]", "batch[batch]", 1)
+	admin := fmt.Sprintf(testSlowQueryMessage, "4.2", "1716792808", "1")
+
+	testCases := []struct {
+		name     string
+		logGroup string
+		mode     string
+		message  string
+		want     bool
+	}{
+		{name: "全ての条件に一致", logGroup: "/aws/rds/cluster/db/slowquery", mode: "slowquery", message: batch, want: true},
+		{name: "ユーザーが異なる", logGroup: "/aws/rds/cluster/db/slowquery", mode: "slowquery", message: admin, want: false},
+		{name: "ロググループが異なる", logGroup: "/aws/lambda/app", mode: "slowquery", message: batch, want: false},
+		{name: "モードが異なる", logGroup: "/aws/rds/cluster/db/slowquery", mode: "plain", message: batch, want: false},
+		{name: "メッセージが一致しない", logGroup: "/aws/rds/cluster/db/slowquery", mode: "slowquery", message: strings.Replace(batch, "`task`", "`user`", 1), want: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Match(tt.logGroup, tt.mode, tt.message); got != tt.want {
+				t.Fatalf("got: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMuteSlowQueryThreshold(t *testing.T) {
+	ctx := context.Background()
+	rules, _ := ParseMuteRules(`[{"name":"batch","schedules":[{"start":"06:00","end":"07:00"}],"action":"rollup"}]`, time.UTC)
+	at := time.Date(2024, 5, 27, 6, 53, 33, 0, time.UTC).UnixMilli()
+
+	cwld := &events.CloudwatchLogsData{LogGroup: "/aws/rds/cluster/db/slowquery", LogEvents: []events.CloudwatchLogsLogEvent{
+		{ID: "1", Timestamp: at, Message: fmt.Sprintf(testSlowQueryMessage, "4.2", "1716792813", "1")},
+		{ID: "2", Timestamp: at, Message: fmt.Sprintf(testSlowQueryMessage, "0.5", "1716792813", "2")},
+	}}
+	c, _ := NewCwl2slack("slowquery", 1, cwld)
+
+	// 閾値未満のスロークエリーは元々通知しないため、期間内でも通知しなかった件数に含めない
+	muted := c.Mute(rules)
+	if len(muted) != 1 || muted[0].Event.ID != "1" {
+		t.Fatalf("unexpected muted: %+v", muted)
+	}
+
+	store := mute.NewMemoryStore()
+	if err := RecordMuted(ctx, store, muted, cwld.LogGroup, c.Mode); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := TakeSuppressed(ctx, store, rules, time.Date(2024, 5, 27, 7, 10, 0, 0, time.UTC), time.UTC)
+	if err != nil || len(got) != 1 || got[0].Events != 1 {
+		t.Fatalf("unexpected result: %+v, %v", got, err)
+	}
+
+	// 閾値未満のスロークエリーは通知しない
+	notifications, err := c.GetNotifications()
+	if err != nil || len(notifications) != 0 {
+		t.Fatalf("unexpected notifications: %+v, %v", notifications, err)
+	}
+}
+
+func TestMuteAlarm(t *testing.T) {
+	rules, err := ParseMuteRules(`[
+		{"name":"logs","log_groups":["*"]},
+		{"name":"api","alarms":["api-*"],"windows":[{"start":"2024-05-27T06:00:00Z","end":"2024-05-27T07:00:00Z"}],"action":"rollup"}
+	]`, time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// 状態が変化した時刻が期間内のアラームに一致し、ロググループの条件のルールはアラームに一致しない
+	a := testAlarm(t, "ALARM", "OK")
+	m, ok := MuteAlarm(rules, a, now)
+	if !ok || m.Rule.Name != "api" {
+		t.Fatalf("unexpected result: %+v, %v", m, ok)
+	}
+	// アラーム名の条件のルールはログイベントに一致しない
+	if rules[1].Match("/aws/test", "plain", "api-5xx") {
+		t.Fatalf("alarm rule should not match log events")
+	}
+
+	a.StateChangeTime = "2024-05-27T07:00:00.000+0000"
+	if _, ok := MuteAlarm(rules, a, now); ok {
+		t.Fatalf("alarm after the window should not be muted")
+	}
+	a.AlarmName = "db-cpu"
+	a.StateChangeTime = "2024-05-27T06:53:33.043+0000"
+	if _, ok := MuteAlarm(rules, a, now); ok {
+		t.Fatalf("other alarm should not be muted")
+	}
+
+	// rollupのルールはアラーム名で件数を記録する
+	ctx := context.Background()
+	store := mute.NewMemoryStore()
+	if err := RecordMuted(ctx, store, []Muted{m}, "api-5xx", AlarmMode); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := TakeSuppressed(ctx, store, rules, time.Date(2024, 5, 27, 7, 10, 0, 0, time.UTC), time.UTC)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("unexpected result: %+v, %v", got, err)
+	}
+	if f := got[0].Notification.Payload.Attachments[0].Fields[1]; f.Title != "Alarm" || f.Value != "api-5xx" {
+		t.Fatalf("unexpected field: %+v", f)
+	}
+}
+
+func TestMuteAndRollup(t *testing.T) {
+	ctx := context.Background()
+	rules, _ := ParseMuteRules(`[
+		{"name":"healthcheck","pattern":"healthcheck"},
+		{"name":"maintenance","schedules":[{"start":"01:00","end":"03:00"}],"action":"rollup"}
+	]`, time.UTC)
+	at := func(h int) int64 { return time.Date(2024, 6, 1, h, 0, 0, 0, time.UTC).UnixMilli() }
+
+	cwld := &events.CloudwatchLogsData{LogGroup: "/aws/test", LogEvents: []events.CloudwatchLogsLogEvent{
+		{ID: "1", Timestamp: at(0), Message: "[ERROR] before"},
+		{ID: "2", Timestamp: at(1), Message: "[ERROR] during"},
+		{ID: "3", Timestamp: at(2), Message: "[ERROR] during"},
+		{ID: "4", Timestamp: at(4), Message: "healthcheck failed"},
+	}}
+	c, _ := NewCwl2slack("plain", 0, cwld)
+
+	muted := c.Mute(rules)
+	if len(muted) != 3 || len(c.Cwld.LogEvents) != 1 || c.Cwld.LogEvents[0].ID != "1" {
+		t.Fatalf("unexpected result: muted: %d, kept: %+v", len(muted), c.Cwld.LogEvents)
+	}
+	// 元のデータは変更しない
+	if len(cwld.LogEvents) != 4 {
+		t.Fatalf("original data is modified")
+	}
+
+	store := mute.NewMemoryStore()
+	if err := RecordMuted(ctx, store, muted, "/aws/test", "plain"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 期間の終了前は通知しない
+	got, err := TakeSuppressed(ctx, store, rules, time.Date(2024, 6, 1, 2, 30, 0, 0, time.UTC), time.UTC)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("unexpected result: %+v, %v", got, err)
+	}
+
+	// 期間の終了後に1回だけ件数を通知する
+	now := time.Date(2024, 6, 1, 3, 10, 0, 0, time.UTC)
+	got, err = TakeSuppressed(ctx, store, rules, now, time.UTC)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("unexpected result: %+v, %v", got, err)
+	}
+	if title := got[0].Notification.Payload.Attachments[0].Title; !strings.Contains(title, "2 events suppressed during maintenance") {
+		t.Fatalf("unexpected title: %s", title)
+	}
+	if got[0].LogGroups[0] != "/aws/test" || got[0].Modes[0] != "plain" {
+		t.Fatalf("unexpected suppressed: %+v", got[0])
+	}
+	if got, _ := TakeSuppressed(ctx, store, rules, now, time.UTC); len(got) != 0 {
+		t.Fatalf("suppressed should be notified once: %+v", got)
+	}
+
+	// 通知できなかった件数は記録に戻し、次の呼び出しで同じ通知を返す
+	if err := RestoreSuppressed(ctx, store, got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored, err := TakeSuppressed(ctx, store, rules, now.Add(time.Minute), time.UTC)
+	if err != nil || len(restored) != 1 {
+		t.Fatalf("unexpected result: %+v, %v", restored, err)
+	}
+	if !reflect.DeepEqual(restored[0], got[0]) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", restored[0], got[0])
+	}
+}
@@ -0,0 +1,192 @@
+package cwl2slack
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+)
+
+// defaultSeverityConfigはSeverityConfigが設定されていない場合の重要度の判定方法と表示です
+var defaultSeverityConfig = DefaultSeverityConfig()
+
+// GetNotificationsは通知先に依存しない通知の配列を返します。
+// 表示内容はGetSlackPayloadsと同じで、重要度、フィンガープリント、ログを解析した内容を付けて返します
+func (c *Cwl2slack) GetNotifications() ([]notifier.Notification, error) {
+	var notifications []notifier.Notification
+	for _, logEvents := range c.notificationUnits() {
+		cc := c.withLogEvents(logEvents)
+
+		payloads, err := cc.GetSlackPayloads()
+		if err != nil {
+			return nil, err
+		}
+		if len(*payloads) == 0 {
+			continue
+		}
+
+		n, err := cc.analyze()
+		if err != nil {
+			return nil, err
+		}
+		// 重要度に応じて色、絵文字、メンションを設定し、ログイベントの時刻のオンコール担当者をメンションする。
+		// AWSアカウントの環境の色と絵文字は重要度の設定より優先する
+		for _, p := range *payloads {
+			n.Payload = c.decorateAccount(c.severityConfig().Decorate(p, n.Severity))
+			if mention := cc.onCallMention(n.Timestamp); mention != "" {
+				n.Payload.Text = strings.TrimSpace(n.Payload.Text + " " + mention)
+			}
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+// notificationUnitsはログイベントを1つの通知にまとめられる単位に分けます。
+// 相関キーのグループ、plainモードの場合は残り全て、それ以外のモードの場合はログイベントごとに分けます
+func (c *Cwl2slack) notificationUnits() [][]events.CloudwatchLogsLogEvent {
+	var units [][]events.CloudwatchLogsLogEvent
+
+	logEvents := c.Cwld.LogEvents
+	if c.CorrelationKey != nil {
+		groups, ungrouped := groupLogEvents(c.CorrelationKey, logEvents)
+		for _, g := range groups {
+			units = append(units, g.events)
+		}
+		logEvents = ungrouped
+	}
+
+	if len(logEvents) == 0 {
+		return units
+	}
+	if c.Mode == "plain" {
+		return append(units, logEvents)
+	}
+	for _, e := range logEvents {
+		units = append(units, []events.CloudwatchLogsLogEvent{e})
+	}
+	return units
+}
+
+// analyzeはログイベントを解析し、重要度、フィンガープリント、解析した内容を設定した通知を返します
+func (c *Cwl2slack) analyze() (notifier.Notification, error) {
+	sc := c.severityConfig()
+	n := notifier.Notification{
+		Source: c.Cwld.LogGroup,
+		Details: map[string]any{
+			"log_group":  c.Cwld.LogGroup,
+			"log_stream": c.Cwld.LogStream,
+			"mode":       c.Mode,
+		},
+	}
+	if len(c.Cwld.SubscriptionFilters) > 0 {
+		n.Details["subscription_filters"] = c.Cwld.SubscriptionFilters
+	}
+	if c.Cwld.Owner != "" {
+		a := c.account()
+		n.Details["account_id"] = a.ID
+		if a.Name != "" {
+			n.Details["account_name"] = a.Name
+		}
+		if a.Environment != "" {
+			n.Details["environment"] = a.Environment
+		}
+	}
+	if c.CorrelationKey != nil && len(c.Cwld.LogEvents) > 0 {
+		if key := c.CorrelationKey.Extract(c.Cwld.LogEvents[0].Message); key != "" {
+			n.Details["correlation_key"] = key
+		}
+	}
+
+	var fingerprints []string
+	switch c.Mode {
+	case "slowquery":
+		// 閾値を超えたスロークエリーのみを対象にします
+		var queries []*SlowQuery
+		for _, e := range c.Cwld.LogEvents {
+			sq, err := NewSlowQuery(e.Message)
+			if err != nil {
+				return n, err
+			}
+			if sq.QueryTime < c.Theashold {
+				continue
+			}
+			n.Severity = notifier.MaxSeverity(n.Severity, sc.ClassifyQueryTime(sq.QueryTime))
+			if c.CriticalThreshold > 0 && sq.QueryTime >= c.CriticalThreshold {
+				n.Severity = notifier.SeverityCritical
+			}
+			queries = append(queries, sq)
+			fingerprints = append(fingerprints, sq.Query)
+		}
+		setDetail(n.Details, "slow_query", "slow_queries", queries)
+	case "stacktrace":
+		var traces []*StackTrace
+		for _, e := range c.Cwld.LogEvents {
+			st, err := NewStackTrace(e.Message, c.FrameworkPrefixes)
+			if err != nil {
+				// スタックトレースでないログイベントはplainモードの形式で通知するため、メッセージで判定します
+				n.Severity = notifier.MaxSeverity(n.Severity, sc.Classify(c.Mode, e.Message))
+				fingerprints = append(fingerprints, e.Message)
+				continue
+			}
+			n.Severity = notifier.MaxSeverity(n.Severity, sc.Classify(c.Mode, st.Exception))
+			traces = append(traces, st)
+			fingerprints = append(fingerprints, st.Exception+"\n"+st.Frame)
+		}
+		setDetail(n.Details, "stack_trace", "stack_traces", traces)
+	default:
+		messages := make([]string, len(c.Cwld.LogEvents))
+		for i, e := range c.Cwld.LogEvents {
+			n.Severity = notifier.MaxSeverity(n.Severity, sc.Classify(c.Mode, e.Message))
+			messages[i] = e.Message
+		}
+		n.Details["log_messages"] = messages
+		fingerprints = messages
+	}
+
+	// 送信先ごとに発生した順に送信するため、最初のログイベントの時刻を設定します
+	for i, e := range c.Cwld.LogEvents {
+		n.EventIDs = append(n.EventIDs, e.ID)
+		if t := time.UnixMilli(e.Timestamp); i == 0 || t.Before(n.Timestamp) {
+			n.Timestamp = t
+		}
+	}
+
+	if n.Severity == "" {
+		n.Severity = sc.Default(c.Mode)
+	}
+	n.Fingerprint = Fingerprint(c.Cwld.LogGroup, c.Mode, fingerprints...)
+	return n, nil
+}
+
+// onCallMentionは時刻tのオンコール担当者のメンションを返します。サービス名は最初のログイベントから判定します
+func (c *Cwl2slack) onCallMention(t time.Time) string {
+	if c.OnCall == nil {
+		return ""
+	}
+	var message string
+	if len(c.Cwld.LogEvents) > 0 {
+		message = c.Cwld.LogEvents[0].Message
+	}
+	return c.OnCall.Mention(c.Cwld.LogGroup, message, t)
+}
+
+// severityConfigは重要度の判定方法と表示を返します
+func (c *Cwl2slack) severityConfig() *SeverityConfig {
+	if c.SeverityConfig != nil {
+		return c.SeverityConfig
+	}
+	return defaultSeverityConfig
+}
+
+// setDetailは解析した内容が1つの場合はそのまま、複数の場合は配列として設定します
+func setDetail[T any](details map[string]any, single string, plural string, values []T) {
+	switch len(values) {
+	case 0:
+	case 1:
+		details[single] = values[0]
+	default:
+		details[plural] = values
+	}
+}
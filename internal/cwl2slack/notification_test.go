@@ -0,0 +1,136 @@
+package cwl2slack
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+)
+
+const testSlowQueryMessage = "# Time: 2024-05-27T06:53:33.043104Z\n# User@Host: admin[admin] @ [172.17.0.178] Id: 1436601\n# Query_time: %s Lock_time: 0.000002 Rows_sent: 58 Rows_examined: 12158\nSET timestamp=%s;\nSELECT * FROM `task` WHERE `company_id` = '%s';"
+
+func TestGetNotifications(t *testing.T) {
+	slowQuery := func(queryTime string, timestamp string, companyID string) events.CloudwatchLogsLogEvent {
+		return events.CloudwatchLogsLogEvent{Message: fmt.Sprintf(testSlowQueryMessage, queryTime, timestamp, companyID)}
+	}
+
+	testCases := []struct {
+		name              string
+		mode              string
+		threshold         float64
+		criticalThreshold float64
+		correlationKey    string
+		logEvents         []events.CloudwatchLogsLogEvent
+		wantSeverities    []notifier.Severity
+		wantDetail        string
+		sameFingerprint   bool
+	}{
+		{
+			name:           "[plain]全てのログイベントで1つの通知",
+			mode:           "plain",
+			logEvents:      []events.CloudwatchLogsLogEvent{{Message: "[ERROR] a"}, {Message: "[ERROR] b"}},
+			wantSeverities: []notifier.Severity{notifier.SeverityError},
+			wantDetail:     "log_messages",
+		},
+		{
+			name:           "[plain]キーワードでcritical",
+			mode:           "plain",
+			logEvents:      []events.CloudwatchLogsLogEvent{{Message: "[FATAL] database is down"}},
+			wantSeverities: []notifier.Severity{notifier.SeverityCritical},
+			wantDetail:     "log_messages",
+		},
+		{
+			name:              "[slowquery]閾値でcritical、閾値未満は通知しない",
+			mode:              "slowquery",
+			threshold:         1,
+			criticalThreshold: 10,
+			logEvents: []events.CloudwatchLogsLogEvent{
+				slowQuery("0.5", "1716792808", "0000000001"),
+				slowQuery("4.2", "1716792809", "0000000002"),
+				slowQuery("12.5", "1716792810", "0000000003"),
+			},
+			wantSeverities:  []notifier.Severity{notifier.SeverityWarning, notifier.SeverityCritical},
+			wantDetail:      "slow_query",
+			sameFingerprint: true,
+		},
+		{
+			name:           "[stacktrace]ログイベントごとに通知",
+			mode:           "stacktrace",
+			logEvents:      []events.CloudwatchLogsLogEvent{{Message: "java.lang.IllegalStateException: boom\n\tat com.example.App.run(App.java:1)"}},
+			wantSeverities: []notifier.Severity{notifier.SeverityError},
+			wantDetail:     "stack_trace",
+		},
+		{
+			name:           "[相関キー]グループとキーの無いログイベント",
+			mode:           "plain",
+			correlationKey: `req=(\w+)`,
+			logEvents:      []events.CloudwatchLogsLogEvent{{Message: "req=a first"}, {Message: "no key"}, {Message: "req=a second"}},
+			wantSeverities: []notifier.Severity{notifier.SeverityError, notifier.SeverityError},
+			wantDetail:     "log_messages",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			cwld := events.CloudwatchLogsData{LogGroup: "/aws/test", LogStream: "stream", LogEvents: tt.logEvents}
+			c, _ := NewCwl2slack(tt.mode, tt.threshold, &cwld)
+			c.CriticalThreshold = tt.criticalThreshold
+			if tt.correlationKey != "" {
+				c.CorrelationKey, _ = NewCorrelationKey(tt.correlationKey)
+			}
+
+			got, err := c.GetNotifications()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.wantSeverities) {
+				t.Fatalf("unexpected number of notifications: %d", len(got))
+			}
+
+			for i, n := range got {
+				if n.Severity != tt.wantSeverities[i] {
+					t.Fatalf("notification %d: got severity %s, want %s", i, n.Severity, tt.wantSeverities[i])
+				}
+				if n.Source != "/aws/test" || n.Fingerprint == "" || len(n.Payload.Attachments) == 0 {
+					t.Fatalf("notification %d: unexpected notification: %+v", i, n)
+				}
+				if _, ok := n.Details[tt.wantDetail]; !ok {
+					t.Fatalf("notification %d: %s not found in details: %+v", i, tt.wantDetail, n.Details)
+				}
+			}
+
+			if tt.sameFingerprint && got[0].Fingerprint != got[1].Fingerprint {
+				t.Fatalf("fingerprints should be same: %s, %s", got[0].Fingerprint, got[1].Fingerprint)
+			}
+		})
+	}
+}
+
+func TestGetNotificationsEventIDs(t *testing.T) {
+	cwld := events.CloudwatchLogsData{LogGroup: "/aws/test", LogEvents: []events.CloudwatchLogsLogEvent{
+		{ID: "1", Timestamp: 1716792813043, Message: "req=a first"},
+		{ID: "2", Timestamp: 1716792812000, Message: "no key"},
+		{ID: "3", Timestamp: 1716792814000, Message: "req=a second"},
+	}}
+	c, _ := NewCwl2slack("plain", 0, &cwld)
+	c.CorrelationKey, _ = NewCorrelationKey(`req=(\w+)`)
+
+	got, err := c.GetNotifications()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 通知ごとに元になったログイベントのIDと、最も古いログイベントの時刻を持つ
+	want := []string{"1,3", "2"}
+	wantTimestamp := []int64{1716792813043, 1716792812000}
+	for i, n := range got {
+		if ids := strings.Join(n.EventIDs, ","); ids != want[i] {
+			t.Fatalf("notification %d: got %s, want %s", i, ids, want[i])
+		}
+		if n.Timestamp.UnixMilli() != wantTimestamp[i] {
+			t.Fatalf("notification %d: got %s", i, n.Timestamp)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package cwl2slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// OnCallConfigはロググループやサービスを担当するチームと、チームごとのオンコールのスケジュールです
+type OnCallConfig struct {
+	// ログを担当するチーム。先に一致したものを使います
+	Owners []OnCallOwner `json:"owners"`
+
+	// チーム名ごとのオンコールのスケジュール
+	Teams map[string]OnCallTeam `json:"teams"`
+
+	// JSON形式のログでサービス名を表す項目(ドット区切りでネストした項目を指定できます)。未設定の場合はserviceとservice.nameです
+	ServiceFields []string `json:"service_fields,omitempty"`
+
+	// ユーザーグループのハンドル(例: @payments-oncall)ごとのID(Sで始まるID)。
+	// 担当者にハンドルを指定した場合はこのIDに置き換えます。Slackはハンドルをメンションとして解釈しないためです
+	UserGroups map[string]string `json:"user_groups,omitempty"`
+}
+
+// OnCallOwnerはロググループまたはサービスと、それを担当するチームです。
+// LogGroupsは*をワイルドカードとして使えます。両方を指定した場合はどちらかに一致すれば担当とします
+type OnCallOwner struct {
+	LogGroups []string `json:"log_groups,omitempty"`
+	Services  []string `json:"services,omitempty"`
+	Team      string   `json:"team"`
+}
+
+// OnCallTeamはチームのオンコールのスケジュールです。
+// オーバーライドに一致する期間はその担当者、それ以外はローテーションの担当者で、どちらも無い場合はFallbackをメンションします
+type OnCallTeam struct {
+	Rotation  *OnCallRotation  `json:"rotation,omitempty"`
+	Overrides []OnCallOverride `json:"overrides,omitempty"`
+
+	// 担当者が決まらない場合のメンション(ユーザーグループIDなど)
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// OnCallRotationはStartからPeriod(例: 168h)ごとにMembersを順番に交代するローテーションです。
+// Membersはユーザー(Uで始まるID)、ユーザーグループ(Sで始まるID)またはUserGroupsに指定したハンドルです
+type OnCallRotation struct {
+	Start   time.Time `json:"start"`
+	Period  string    `json:"period"`
+	Members []string  `json:"members"`
+
+	period time.Duration
+}
+
+// OnCallOverrideはローテーションに関わらずMemberが担当する期間です。Endは期間に含みません
+type OnCallOverride struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Member string    `json:"member"`
+}
+
+// defaultServiceFieldsはJSON形式のログでサービス名を表す既定の項目です
+var defaultServiceFields = []string{"service", "service.name"}
+
+// LoadOnCallConfigはJSON形式のファイルからOnCallConfigを読み込みます
+func LoadOnCallConfig(path string) (*OnCallConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read on-call config: %w", err)
+	}
+	return ParseOnCallConfig(string(b))
+}
+
+// ParseOnCallConfigはJSONからOnCallConfigを作成します。
+// 担当者のハンドルはUserGroupsのIDに置き換え、メンションにできない担当者はエラーにします
+func ParseOnCallConfig(s string) (*OnCallConfig, error) {
+	var c OnCallConfig
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return nil, fmt.Errorf("failed to parse on-call config: %w", err)
+	}
+	if len(c.ServiceFields) == 0 {
+		c.ServiceFields = defaultServiceFields
+	}
+
+	// ハンドルは@の有無を問わず一致させます
+	groups := make(map[string]string, len(c.UserGroups))
+	for h, id := range c.UserGroups {
+		if !strings.HasPrefix(id, "S") || !slack.IsMention(id) {
+			return nil, fmt.Errorf("on-call user group %s: invalid user group ID: %s", h, id)
+		}
+		groups[handle(h)] = id
+	}
+	c.UserGroups = groups
+
+	for i, o := range c.Owners {
+		if _, ok := c.Teams[o.Team]; !ok {
+			return nil, fmt.Errorf("on-call owner #%d: unknown team: %s", i, o.Team)
+		}
+	}
+	for name, t := range c.Teams {
+		if r := t.Rotation; r != nil {
+			if len(r.Members) == 0 {
+				return nil, fmt.Errorf("on-call team %s: rotation members are required", name)
+			}
+			d, err := time.ParseDuration(r.Period)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("on-call team %s: invalid rotation period: %s", name, r.Period)
+			}
+			r.period = d
+			for i, m := range r.Members {
+				if r.Members[i], err = c.member(m); err != nil {
+					return nil, fmt.Errorf("on-call team %s: %w", name, err)
+				}
+			}
+		}
+		for i, o := range t.Overrides {
+			if !o.End.After(o.Start) {
+				return nil, fmt.Errorf("on-call team %s: override end must be after start", name)
+			}
+			if o.Member == "" {
+				return nil, fmt.Errorf("on-call team %s: override member is required", name)
+			}
+			m, err := c.member(o.Member)
+			if err != nil {
+				return nil, fmt.Errorf("on-call team %s: %w", name, err)
+			}
+			t.Overrides[i].Member = m
+		}
+		if t.Fallback != "" {
+			m, err := c.member(t.Fallback)
+			if err != nil {
+				return nil, fmt.Errorf("on-call team %s: %w", name, err)
+			}
+			t.Fallback = m
+			c.Teams[name] = t
+		}
+	}
+	return &c, nil
+}
+
+// memberは担当者のハンドルをUserGroupsのIDに置き換えます。メンションにできない担当者はエラーです
+func (c *OnCallConfig) member(s string) (string, error) {
+	if id, ok := c.UserGroups[handle(s)]; ok {
+		return id, nil
+	}
+	if !slack.IsMention(s) {
+		return "", fmt.Errorf("unknown member: %s (use a user ID, a user group ID or a handle in user_groups)", s)
+	}
+	return s, nil
+}
+
+// handleはハンドルの先頭の@と空白を取り除きます
+func handle(s string) string {
+	return strings.TrimPrefix(strings.TrimSpace(s), "@")
+}
+
+// Teamはロググループとログメッセージのサービス名から担当するチームを返します。担当が無い場合は空文字です
+func (c *OnCallConfig) Team(logGroup string, message string) string {
+	service := c.service(message)
+	for _, o := range c.Owners {
+		if slices.ContainsFunc(o.LogGroups, func(p string) bool { return matchGlob(p, logGroup) }) {
+			return o.Team
+		}
+		if service != "" && slices.Contains(o.Services, service) {
+			return o.Team
+		}
+	}
+	return ""
+}
+
+// Mentionはロググループとログメッセージを担当するチームの、時刻tのオンコール担当者のメンションを返します。
+// 担当するチームや担当者が無い場合は空文字です
+func (c *OnCallConfig) Mention(logGroup string, message string, t time.Time) string {
+	team, ok := c.Teams[c.Team(logGroup, message)]
+	if !ok {
+		return ""
+	}
+	return slack.Mention(team.OnCall(t))
+}
+
+// OnCallは時刻tの担当者を返します。オーバーライドが重なる場合は後に指定したものを優先します
+func (t OnCallTeam) OnCall(at time.Time) string {
+	for i := len(t.Overrides) - 1; i >= 0; i-- {
+		o := t.Overrides[i]
+		if !at.Before(o.Start) && at.Before(o.End) {
+			return o.Member
+		}
+	}
+	if r := t.Rotation; r != nil && r.period > 0 && len(r.Members) > 0 {
+		n := int64(len(r.Members))
+		// Startより前の時刻は逆向きにローテーションをたどる
+		d := at.Sub(r.Start)
+		i := int64(d / r.period)
+		if d < 0 && d%r.period != 0 {
+			i--
+		}
+		return r.Members[(i%n+n)%n]
+	}
+	return t.Fallback
+}
+
+// serviceはJSON形式のログメッセージからサービス名を返します
+func (c *OnCallConfig) service(message string) string {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "{") {
+		return ""
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(message), &obj); err != nil {
+		return ""
+	}
+	for _, field := range c.ServiceFields {
+		if v, ok := lookup(obj, field); ok {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
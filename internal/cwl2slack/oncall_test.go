@@ -0,0 +1,130 @@
+package cwl2slack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testOnCallConfig = `{
+	"owners": [
+		{"log_groups": ["/aws/lambda/payments-*"], "team": "payments"},
+		{"services": ["search"], "team": "search"},
+		{"log_groups": ["/aws/lambda/billing-*"], "team": "billing"}
+	],
+	"teams": {
+		"payments": {
+			"rotation": {"start": "2024-06-03T09:00:00+09:00", "period": "168h", "members": ["U0000001", "U0000002", "U0000003"]},
+			"overrides": [{"start": "2024-06-12T00:00:00+09:00", "end": "2024-06-13T00:00:00+09:00", "member": "U0000009"}]
+		},
+		"search": {"fallback": "S0000001"},
+		"billing": {
+			"rotation": {"start": "2024-06-03T09:00:00+09:00", "period": "168h", "members": ["@billing-oncall", "U0000004"]},
+			"fallback": "billing-leads"
+		}
+	},
+	"user_groups": {"@billing-oncall": "S0000003", "billing-leads": "S0000004"}
+}`
+
+func TestParseOnCallConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		json     string
+		isNormal bool
+	}{
+		{name: "[正常系]ローテーションとオーバーライド", json: testOnCallConfig, isNormal: true},
+		{name: "[異常系]存在しないチーム", json: `{"owners":[{"log_groups":["*"],"team":"x"}],"teams":{}}`, isNormal: false},
+		{name: "[異常系]ローテーションの期間が不正", json: `{"teams":{"a":{"rotation":{"period":"1w","members":["U1"]}}}}`, isNormal: false},
+		{name: "[異常系]ローテーションの担当者が無い", json: `{"teams":{"a":{"rotation":{"period":"24h"}}}}`, isNormal: false},
+		{name: "[異常系]オーバーライドの期間が不正", json: `{"teams":{"a":{"overrides":[{"start":"2024-06-02T00:00:00Z","end":"2024-06-01T00:00:00Z","member":"U1"}]}}}`, isNormal: false},
+		{name: "[正常系]ユーザーグループのハンドル", json: `{"user_groups":{"@payments-oncall":"S0000002"},"teams":{"a":{"fallback":"@payments-oncall"}}}`, isNormal: true},
+		{name: "[異常系]IDが分からないハンドル", json: `{"teams":{"a":{"rotation":{"period":"24h","members":["U1","@team"]}}}}`, isNormal: false},
+		{name: "[異常系]オーバーライドの担当者がハンドル", json: `{"teams":{"a":{"overrides":[{"start":"2024-06-01T00:00:00Z","end":"2024-06-02T00:00:00Z","member":"@team"}]}}}`, isNormal: false},
+		{name: "[異常系]ユーザーグループのIDが不正", json: `{"user_groups":{"@team":"U0000001"},"teams":{}}`, isNormal: false},
+		{name: "[異常系]JSONでない", json: `payments`, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOnCallConfig(tt.json)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestOnCallConfigMention(t *testing.T) {
+	c, err := ParseOnCallConfig(testOnCallConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jst := time.FixedZone("JST", 9*60*60)
+
+	testCases := []struct {
+		name     string
+		logGroup string
+		message  string
+		at       time.Time
+		want     string
+	}{
+		{name: "ローテーションの最初の担当者", logGroup: "/aws/lambda/payments-api", message: "ERROR", at: time.Date(2024, 6, 3, 9, 0, 0, 0, jst), want: "<@U0000001>"},
+		{name: "ローテーションの次の担当者", logGroup: "/aws/lambda/payments-api", message: "ERROR", at: time.Date(2024, 6, 10, 9, 0, 0, 0, jst), want: "<@U0000002>"},
+		{name: "一巡した担当者", logGroup: "/aws/lambda/payments-api", message: "ERROR", at: time.Date(2024, 6, 24, 10, 0, 0, 0, jst), want: "<@U0000001>"},
+		{name: "開始前は逆向きにたどる", logGroup: "/aws/lambda/payments-api", message: "ERROR", at: time.Date(2024, 6, 3, 8, 0, 0, 0, jst), want: "<@U0000003>"},
+		{name: "オーバーライド", logGroup: "/aws/lambda/payments-api", message: "ERROR", at: time.Date(2024, 6, 12, 12, 0, 0, 0, jst), want: "<@U0000009>"},
+		{name: "JSONのサービス名とフォールバック", logGroup: "/ecs/app", message: `{"service":{"name":"search"},"level":"error"}`, at: time.Date(2024, 6, 12, 12, 0, 0, 0, jst), want: "<!subteam^S0000001>"},
+		{name: "ハンドルはユーザーグループのIDに置き換える", logGroup: "/aws/lambda/billing-api", message: "ERROR", at: time.Date(2024, 6, 3, 9, 0, 0, 0, jst), want: "<!subteam^S0000003>"},
+		{name: "担当するチームが無い", logGroup: "/ecs/app", message: `{"service":"inventory"}`, at: time.Date(2024, 6, 12, 12, 0, 0, 0, jst), want: ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Mention(tt.logGroup, tt.message, tt.at); got != tt.want {
+				t.Fatalf("got: %q, want: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadOnCallConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oncall.json")
+	if err := os.WriteFile(path, []byte(testOnCallConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOnCallConfig(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := LoadOnCallConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+}
+
+func TestGetNotificationsOnCall(t *testing.T) {
+	cwld := events.CloudwatchLogsData{LogGroup: "/aws/lambda/payments-api", LogEvents: []events.CloudwatchLogsLogEvent{
+		{ID: "1", Timestamp: time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC).UnixMilli(), Message: "[ERROR] failed"},
+	}}
+	c, _ := NewCwl2slack("plain", 0, &cwld)
+	c.OnCall, _ = ParseOnCallConfig(testOnCallConfig)
+
+	got, err := c.GetNotifications()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// ログイベントの時刻(2024-06-10 09:00 JST)の担当者をメンションする
+	if len(got) != 1 || got[0].Payload.Text != "<@U0000002>" {
+		t.Fatalf("unexpected notifications: %+v", got)
+	}
+}
@@ -0,0 +1,362 @@
+package cwl2slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tomozo6/cwl2slack/pkg/chatwork"
+	"github.com/tomozo6/cwl2slack/pkg/discord"
+	"github.com/tomozo6/cwl2slack/pkg/email"
+	"github.com/tomozo6/cwl2slack/pkg/googlechat"
+	"github.com/tomozo6/cwl2slack/pkg/lineworks"
+	"github.com/tomozo6/cwl2slack/pkg/mattermost"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/opsgenie"
+	"github.com/tomozo6/cwl2slack/pkg/pagerduty"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+	"github.com/tomozo6/cwl2slack/pkg/teams"
+	"github.com/tomozo6/cwl2slack/pkg/webhook"
+)
+
+// Routeは通知先と、その通知先に通知する条件です。
+// 条件を指定しない項目は全てに一致します
+type Route struct {
+	Name string `json:"name"`
+
+	// 通知先の種類(slack, teams, discord, googlechat, mattermost, pagerduty, opsgenie, webhook, email, chatwork, lineworks)と接続先。
+	// emailのURLはsmtp://ユーザー名:パスワード@ホスト:ポートの形式です
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Channel string `json:"channel,omitempty"`
+
+	// PagerDutyのインテグレーションのルーティングキーと、OpsgenieのAPIキー
+	RoutingKey string `json:"routing_key,omitempty"`
+	APIKey     string `json:"api_key,omitempty"`
+
+	// webhookの本文のテンプレート(text/template)、追加するヘッダー、署名に使う秘密鍵とヘッダーの名前
+	Template        string            `json:"template,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Secret          string            `json:"secret,omitempty"`
+	SignatureHeader string            `json:"signature_header,omitempty"`
+
+	// ChatworkのAPIトークンとルームID。slackでtokenを指定した場合はボットトークンとしてWeb APIでchannelに送信し、
+	// アラームの状態の変化をスレッドにまとめます(urlはWeb APIのURLを変更する場合のみ指定します)
+	Token  string `json:"token,omitempty"`
+	RoomID string `json:"room_id,omitempty"`
+
+	// LINE WORKSのボットとトークルーム、Service Account認証の情報。tokenを指定した場合はアクセストークンとして使います
+	BotID          string `json:"bot_id,omitempty"`
+	ChannelID      string `json:"channel_id,omitempty"`
+	ClientID       string `json:"client_id,omitempty"`
+	ClientSecret   string `json:"client_secret,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+	PrivateKey     string `json:"private_key,omitempty"`
+
+	// メールの送信元と宛先。insecureがtrueの場合はSTARTTLSに対応していないSMTPサーバーにも送信します
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	Insecure bool     `json:"insecure,omitempty"`
+
+	// DiscordとMattermostに表示する送信者の名前
+	Username string `json:"username,omitempty"`
+
+	// Slackの通知の形式(attachmentsまたはblocks、未設定の場合はattachments)
+	Format string `json:"format,omitempty"`
+
+	// trueの場合、Slackの上限を超える通知を切り詰めずにエラーにします
+	Strict bool `json:"strict,omitempty"`
+
+	// 1秒あたりの送信数の上限と、続けて送信できる数。
+	// 未設定の場合、Slackは1秒に1件(Incoming Webhookの制限)で、その他は制限しません。0未満の場合は制限しません
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	Burst     int     `json:"burst,omitempty"`
+
+	// 通知する条件。LogGroupsは*をワイルドカードとして使えます
+	LogGroups  []string `json:"log_groups,omitempty"`
+	Modes      []string `json:"modes,omitempty"`
+	Severities []string `json:"severities,omitempty"`
+
+	// alarmモードで通知する条件のうち、アラーム名。*をワイルドカードとして使えます
+	Alarms []string `json:"alarms,omitempty"`
+
+	// 通知する条件のうち、ログを送信したサブスクリプションフィルターの名前。*をワイルドカードとして使えます
+	SubscriptionFilters []string `json:"subscription_filters,omitempty"`
+
+	// 通知する条件のうち、ログを送信したAWSアカウント(IDまたはAccountDirectoryの名前)と環境。
+	// 通知しなかったログイベントの件数の通知には適用しません
+	Accounts     []string `json:"accounts,omitempty"`
+	Environments []string `json:"environments,omitempty"`
+
+	// 通知する最低の重要度(critical, error, warning, info)。
+	// pagerdutyでseveritiesとmin_severityのどちらも指定しない場合は、criticalの通知のみ呼び出すようにcriticalです
+	MinSeverity string `json:"min_severity,omitempty"`
+}
+
+// ParseRoutesはJSONの配列からRouteの配列を作成します
+func ParseRoutes(s string) ([]Route, error) {
+	var routes []Route
+	if err := json.Unmarshal([]byte(s), &routes); err != nil {
+		return nil, fmt.Errorf("failed to parse routes: %w", err)
+	}
+
+	for i, r := range routes {
+		if r.Name == "" {
+			routes[i].Name = fmt.Sprintf("%s#%d", r.Type, i)
+		}
+		if r.MinSeverity != "" {
+			sev, err := notifier.ParseSeverity(r.MinSeverity)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %w", routes[i].Name, err)
+			}
+			routes[i].MinSeverity = string(sev)
+		}
+		// 大文字や小文字の違いは正規化し、一致することの無い重要度はエラーにします
+		for j, s := range r.Severities {
+			sev, err := notifier.ParseSeverity(s)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %w", routes[i].Name, err)
+			}
+			routes[i].Severities[j] = string(sev)
+		}
+		// APIを使う通知先はURLの代わりにキーなどが必要です
+		var missing string
+		switch r.Type {
+		case "pagerduty":
+			if r.RoutingKey == "" {
+				missing = "routing_key"
+			}
+			if r.MinSeverity == "" && len(r.Severities) == 0 {
+				routes[i].MinSeverity = string(notifier.SeverityCritical)
+			}
+		case "opsgenie":
+			if r.APIKey == "" {
+				missing = "api_key"
+			}
+		case "", "slack":
+			if r.Token != "" && r.Channel == "" {
+				missing = "channel"
+			} else if r.Token == "" && r.URL == "" {
+				missing = "url"
+			}
+		case "chatwork":
+			if r.Token == "" || r.RoomID == "" {
+				missing = "token and room_id"
+			}
+		case "lineworks":
+			if r.BotID == "" || r.ChannelID == "" {
+				missing = "bot_id and channel_id"
+			} else if r.Token == "" && (r.ClientID == "" || r.ClientSecret == "" || r.ServiceAccount == "" || r.PrivateKey == "") {
+				missing = "token or client_id, client_secret, service_account and private_key"
+			}
+		default:
+			if r.URL == "" {
+				missing = "url"
+			}
+		}
+		if missing != "" {
+			return nil, fmt.Errorf("route %s: %s is required", routes[i].Name, missing)
+		}
+	}
+	return routes, nil
+}
+
+// Matchはログがこの通知先に通知する条件に一致するかどうかを返します
+func (r Route) Match(c *Cwl2slack) bool {
+	return r.MatchSource(c.Cwld.LogGroup, c.Mode) && r.MatchAccount(c.account()) && r.MatchSubscriptionFilters(c.Cwld.SubscriptionFilters)
+}
+
+// MatchSubscriptionFiltersはサブスクリプションフィルターのいずれかが条件に一致する場合にtrueを返します
+func (r Route) MatchSubscriptionFilters(filters []string) bool {
+	if len(r.SubscriptionFilters) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(filters, func(f string) bool {
+		return slices.ContainsFunc(r.SubscriptionFilters, func(p string) bool { return matchGlob(p, f) })
+	})
+}
+
+// MatchAlarmはアラームの状態の変化がこの通知先に通知する条件に一致するかどうかを返します。
+// ロググループとサブスクリプションフィルターはログの条件のため、指定した通知先にはアラームを通知しません
+func (r Route) MatchAlarm(a *Alarm, account Account) bool {
+	return r.matchAlarmName(a.AlarmName) && r.MatchAccount(account)
+}
+
+// matchAlarmNameはアラーム名がalarmモードの条件に一致する場合にtrueを返します
+func (r Route) matchAlarmName(name string) bool {
+	if len(r.LogGroups) > 0 || len(r.SubscriptionFilters) > 0 {
+		return false
+	}
+	if len(r.Modes) > 0 && !slices.Contains(r.Modes, AlarmMode) {
+		return false
+	}
+	return len(r.Alarms) == 0 || slices.ContainsFunc(r.Alarms, func(p string) bool { return matchGlob(p, name) })
+}
+
+// MatchAccountはAWSアカウントとその環境が条件に一致する場合にtrueを返します
+func (r Route) MatchAccount(a Account) bool {
+	if len(r.Accounts) > 0 && !slices.Contains(r.Accounts, a.ID) && (a.Name == "" || !slices.Contains(r.Accounts, a.Name)) {
+		return false
+	}
+	if len(r.Environments) > 0 && !slices.Contains(r.Environments, a.Environment) {
+		return false
+	}
+	return true
+}
+
+// MatchSuppressedは通知しなかったログイベントのロググループとモードのいずれかが条件に一致する場合にtrueを返します。
+// alarmモードはロググループの代わりにアラーム名を条件と比べます
+func (r Route) MatchSuppressed(s Suppressed) bool {
+	for _, g := range s.LogGroups {
+		for _, m := range s.Modes {
+			if m == AlarmMode && r.matchAlarmName(g) {
+				return true
+			}
+			if m != AlarmMode && r.MatchSource(g, m) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchSourceはロググループとモードが条件に一致する場合にtrueを返します
+func (r Route) MatchSource(logGroup string, mode string) bool {
+	if len(r.Modes) > 0 && !slices.Contains(r.Modes, mode) {
+		return false
+	}
+	if len(r.LogGroups) > 0 && !slices.ContainsFunc(r.LogGroups, func(p string) bool { return matchGlob(p, logGroup) }) {
+		return false
+	}
+	return true
+}
+
+// slackRateLimitはSlackのIncoming Webhookの1秒あたりの送信数の上限です
+const slackRateLimit = 1
+
+// Limitは1秒あたりの送信数の上限と、続けて送信できる数を返します。制限しない場合は0を返します
+func (r Route) Limit() (float64, int) {
+	rate := r.RateLimit
+	if rate == 0 && (r.Type == "" || r.Type == "slack") {
+		rate = slackRateLimit
+	}
+	if rate < 0 {
+		return 0, 0
+	}
+	burst := r.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return rate, burst
+}
+
+// MatchSeverityは通知の重要度がこの通知先に通知する条件に一致するかどうかを返します
+func (r Route) MatchSeverity(s notifier.Severity) bool {
+	if r.MinSeverity != "" && s.Level() < notifier.Severity(r.MinSeverity).Level() {
+		return false
+	}
+	return len(r.Severities) == 0 || slices.Contains(r.Severities, string(s))
+}
+
+// NewNotifierは通知先の種類に応じたNotifierを返します
+func (r Route) NewNotifier() (notifier.Notifier, error) {
+	switch r.Type {
+	case "", "slack":
+		if r.Format != "" && r.Format != "attachments" && r.Format != "blocks" {
+			return nil, fmt.Errorf("route %s: invalid format: %s", r.Name, r.Format)
+		}
+		if r.Token != "" {
+			return &notifier.SlackAPI{
+				WebAPI: slack.WebAPI{Token: r.Token, Channel: r.Channel, URL: r.URL, Strict: r.Strict},
+				Blocks: r.Format == "blocks",
+			}, nil
+		}
+		return &notifier.Slack{
+			Slack:  slack.Slack{URL: r.URL, Channel: r.Channel, Strict: r.Strict},
+			Blocks: r.Format == "blocks",
+		}, nil
+	case "teams":
+		return &teams.Teams{URL: r.URL}, nil
+	case "discord":
+		return &discord.Discord{URL: r.URL, Username: r.Username}, nil
+	case "googlechat":
+		return &googlechat.GoogleChat{URL: r.URL}, nil
+	case "mattermost":
+		return &mattermost.Mattermost{URL: r.URL, Channel: r.Channel, Username: r.Username}, nil
+	case "pagerduty":
+		return &pagerduty.PagerDuty{URL: r.URL, RoutingKey: r.RoutingKey}, nil
+	case "opsgenie":
+		return &opsgenie.Opsgenie{URL: r.URL, APIKey: r.APIKey}, nil
+	case "webhook":
+		w := &webhook.Webhook{URL: r.URL, Header: http.Header{}, Secret: r.Secret, SignatureHeader: r.SignatureHeader}
+		for k, v := range r.Headers {
+			w.Header.Set(k, v)
+		}
+		if r.Template != "" {
+			tmpl, err := webhook.ParseTemplate(r.Template)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: invalid template: %w", r.Name, err)
+			}
+			w.Template = tmpl
+		}
+		return w, nil
+	case "email":
+		return r.newEmail()
+	case "chatwork":
+		return &chatwork.Chatwork{URL: r.URL, Token: r.Token, RoomID: r.RoomID}, nil
+	case "lineworks":
+		return &lineworks.LineWorks{
+			URL:            r.URL,
+			BotID:          r.BotID,
+			ChannelID:      r.ChannelID,
+			AccessToken:    r.Token,
+			ClientID:       r.ClientID,
+			ClientSecret:   r.ClientSecret,
+			ServiceAccount: r.ServiceAccount,
+			PrivateKey:     r.PrivateKey,
+		}, nil
+	default:
+		return nil, fmt.Errorf("route %s: invalid type: %s", r.Name, r.Type)
+	}
+}
+
+// newEmailはsmtp://形式のURLからメールのNotifierを作成します。ポートを省略した場合は587を使います
+func (r Route) newEmail() (*email.Email, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil || u.Scheme != "smtp" || u.Hostname() == "" {
+		return nil, fmt.Errorf("route %s: invalid SMTP URL", r.Name)
+	}
+	if r.From == "" || len(r.To) == 0 {
+		return nil, fmt.Errorf("route %s: from and to are required", r.Name)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "587"
+	}
+	e := &email.Email{
+		Addr:     net.JoinHostPort(u.Hostname(), port),
+		From:     r.From,
+		To:       r.To,
+		Insecure: r.Insecure,
+	}
+	if u.User != nil {
+		e.Username = u.User.Username()
+		e.Password, _ = u.User.Password()
+	}
+	return e, nil
+}
+
+// matchGlobは*を任意の文字列(/を含む)として文字列がパターンに一致するかどうかを返します
+func matchGlob(pattern string, s string) bool {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(s)
+}
@@ -0,0 +1,252 @@
+package cwl2slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// SeverityRuleはログメッセージに一致する正規表現と、一致した場合の重要度です
+type SeverityRule struct {
+	Pattern  string            `json:"pattern"`
+	Severity notifier.Severity `json:"severity"`
+
+	pattern *regexp.Regexp
+}
+
+// QueryTimeBandはslowqueryモードでクエリ実行時間(秒)がMin以上の場合の重要度です
+type QueryTimeBand struct {
+	Min      float64           `json:"min"`
+	Severity notifier.Severity `json:"severity"`
+}
+
+// SeverityConfigはログイベントの重要度の判定方法と、重要度ごとの通知の表示です。
+// 判定は、JSON形式のログのレベルの項目、キーワードのルール(先に一致したもの)、モードごとの既定値の順に行います。
+// slowqueryモードはクエリ実行時間の範囲で判定し、alarmモードはALARMへの変化のみアラーム名と説明で判定します
+type SeverityConfig struct {
+	Rules          []SeverityRule               `json:"rules,omitempty"`
+	LevelFields    []string                     `json:"level_fields,omitempty"`
+	QueryTimeBands []QueryTimeBand              `json:"query_time_bands,omitempty"`
+	Defaults       map[string]notifier.Severity `json:"defaults,omitempty"`
+
+	// 重要度ごとのアタッチメントの色、タイトルの先頭の絵文字、メンション(here, channel, ユーザーグループID, ユーザーID)
+	Colors   map[notifier.Severity]string `json:"colors,omitempty"`
+	Emojis   map[notifier.Severity]string `json:"emojis,omitempty"`
+	Mentions map[notifier.Severity]string `json:"mentions,omitempty"`
+}
+
+// DefaultSeverityConfigは既定の判定方法と表示を返します。メンションはしません
+func DefaultSeverityConfig() *SeverityConfig {
+	c := &SeverityConfig{
+		Rules: []SeverityRule{
+			{Pattern: `(?i)\b(fatal|panic|critical|crit|emerg|emergency|alert)\b`, Severity: notifier.SeverityCritical},
+			{Pattern: `(?i)\b(error|err|exception)\b`, Severity: notifier.SeverityError},
+			{Pattern: `(?i)\b(warn|warning)\b`, Severity: notifier.SeverityWarning},
+			{Pattern: `(?i)\b(info|notice|debug)\b`, Severity: notifier.SeverityInfo},
+		},
+		LevelFields: []string{"level", "severity", "log.level", "levelname"},
+		Defaults: map[string]notifier.Severity{
+			"plain":      notifier.SeverityError,
+			"stacktrace": notifier.SeverityError,
+			"slowquery":  notifier.SeverityWarning,
+			AlarmMode:    notifier.SeverityError,
+		},
+		Colors: map[notifier.Severity]string{
+			notifier.SeverityCritical: "#E01E5A",
+			notifier.SeverityError:    "danger",
+			notifier.SeverityWarning:  "warning",
+			notifier.SeverityInfo:     "#439FE0",
+		},
+		Emojis: map[notifier.Severity]string{
+			notifier.SeverityCritical: ":fire:",
+			notifier.SeverityError:    ":rotating_light:",
+			notifier.SeverityWarning:  ":warning:",
+			notifier.SeverityInfo:     ":information_source:",
+		},
+		Mentions: map[notifier.Severity]string{},
+	}
+	c.compile()
+	return c
+}
+
+// ParseSeverityConfigはJSONから判定方法と表示を返します。
+// 指定しない項目は既定値を使い、defaults、colors、emojis、mentionsは指定した重要度やモードのみ既定値を上書きします
+func ParseSeverityConfig(s string) (*SeverityConfig, error) {
+	var in SeverityConfig
+	if err := json.Unmarshal([]byte(s), &in); err != nil {
+		return nil, fmt.Errorf("failed to parse severity config: %w", err)
+	}
+
+	c := DefaultSeverityConfig()
+	if in.Rules != nil {
+		c.Rules = in.Rules
+	}
+	if in.LevelFields != nil {
+		c.LevelFields = in.LevelFields
+	}
+	if in.QueryTimeBands != nil {
+		c.QueryTimeBands = in.QueryTimeBands
+	}
+	for k, v := range in.Defaults {
+		c.Defaults[k] = v
+	}
+	for k, v := range in.Colors {
+		c.Colors[k] = v
+	}
+	for k, v := range in.Emojis {
+		c.Emojis[k] = v
+	}
+	for k, v := range in.Mentions {
+		c.Mentions[k] = v
+	}
+
+	// 重要度の値を確認します
+	var severities []notifier.Severity
+	for _, r := range c.Rules {
+		severities = append(severities, r.Severity)
+	}
+	for _, b := range c.QueryTimeBands {
+		severities = append(severities, b.Severity)
+	}
+	for _, v := range c.Defaults {
+		severities = append(severities, v)
+	}
+	for _, m := range []map[notifier.Severity]string{c.Colors, c.Emojis, c.Mentions} {
+		for k := range m {
+			severities = append(severities, k)
+		}
+	}
+	for _, sev := range severities {
+		if _, err := notifier.ParseSeverity(string(sev)); err != nil {
+			return nil, fmt.Errorf("failed to parse severity config: %w", err)
+		}
+	}
+
+	// @teamのようなハンドルはメンションにならないため、IDを指定します
+	for k, v := range c.Mentions {
+		if v != "" && !slack.IsMention(v) {
+			return nil, fmt.Errorf("failed to parse severity config: invalid mention for %s: %s", k, v)
+		}
+	}
+
+	for _, r := range c.Rules {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return nil, fmt.Errorf("failed to parse severity config: invalid pattern: %w", err)
+		}
+	}
+	c.compile()
+	return c, nil
+}
+
+// compileはルールの正規表現をコンパイルし、クエリ実行時間の範囲を下限の大きい順に並べます
+func (c *SeverityConfig) compile() {
+	for i, r := range c.Rules {
+		c.Rules[i].pattern = regexp.MustCompile(r.Pattern)
+	}
+	sort.SliceStable(c.QueryTimeBands, func(i, j int) bool {
+		return c.QueryTimeBands[i].Min > c.QueryTimeBands[j].Min
+	})
+}
+
+// Classifyはログメッセージの重要度を返します
+func (c *SeverityConfig) Classify(mode string, message string) notifier.Severity {
+	if s, ok := c.level(message); ok {
+		return s
+	}
+	for _, r := range c.Rules {
+		if r.pattern.MatchString(message) {
+			return r.Severity
+		}
+	}
+	return c.Default(mode)
+}
+
+// ClassifyQueryTimeはslowqueryモードのクエリ実行時間(秒)の重要度を返します
+func (c *SeverityConfig) ClassifyQueryTime(seconds float64) notifier.Severity {
+	for _, b := range c.QueryTimeBands {
+		if seconds >= b.Min {
+			return b.Severity
+		}
+	}
+	return c.Default("slowquery")
+}
+
+// Defaultはモードの既定の重要度を返します
+func (c *SeverityConfig) Default(mode string) notifier.Severity {
+	if s, ok := c.Defaults[mode]; ok {
+		return s
+	}
+	return notifier.SeverityError
+}
+
+// levelはJSON形式のログメッセージのレベルの項目から重要度を返します。
+// 項目名のドットはネストしたオブジェクトを表します(例: log.level)
+func (c *SeverityConfig) level(message string) (notifier.Severity, bool) {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "{") {
+		return "", false
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(message), &obj); err != nil {
+		return "", false
+	}
+
+	for _, field := range c.LevelFields {
+		v, ok := lookup(obj, field)
+		if !ok {
+			continue
+		}
+		level, ok := v.(string)
+		if !ok {
+			continue
+		}
+		for _, r := range c.Rules {
+			if r.pattern.MatchString(level) {
+				return r.Severity, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lookupはドットで区切った項目名の値を返します。項目名そのものにドットを含む場合も探します
+func lookup(obj map[string]any, field string) (any, bool) {
+	if v, ok := obj[field]; ok {
+		return v, true
+	}
+	head, rest, ok := strings.Cut(field, ".")
+	if !ok {
+		return nil, false
+	}
+	child, ok := obj[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return lookup(child, rest)
+}
+
+// Decorateは重要度に応じてアタッチメントの色とタイトルの絵文字を設定し、メンションをTextの先頭に追加します
+func (c *SeverityConfig) Decorate(p slack.Payload, s notifier.Severity) slack.Payload {
+	emoji := c.Emojis[s]
+	attachments := make([]slack.Attachment, len(p.Attachments))
+	for i, a := range p.Attachments {
+		if color, ok := c.Colors[s]; ok {
+			a.Color = color
+		}
+		if emoji != "" {
+			a.Title = emoji + strings.TrimPrefix(a.Title, ":rotating_light:")
+		}
+		attachments[i] = a
+	}
+	p.Attachments = attachments
+
+	if mention := slack.Mention(c.Mentions[s]); mention != "" {
+		p.Text = strings.TrimSpace(mention + " " + p.Text)
+	}
+	return p
+}
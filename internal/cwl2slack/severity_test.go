@@ -0,0 +1,127 @@
+package cwl2slack
+
+import (
+	"testing"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestSeverityConfigClassify(t *testing.T) {
+	c := DefaultSeverityConfig()
+
+	testCases := []struct {
+		name    string
+		mode    string
+		message string
+		want    notifier.Severity
+	}{
+		{name: "FATAL", mode: "plain", message: "[FATAL] database is down", want: notifier.SeverityCritical},
+		{name: "ERROR", mode: "plain", message: "2024-06-01 ERROR failed to connect", want: notifier.SeverityError},
+		{name: "WARN", mode: "plain", message: "WARN retrying", want: notifier.SeverityWarning},
+		{name: "INFO", mode: "plain", message: "INFO started", want: notifier.SeverityInfo},
+		{name: "キーワードが無い場合はモードの既定値", mode: "plain", message: "something happened", want: notifier.SeverityError},
+		{name: "単語の一部は一致しない", mode: "plain", message: "Terror information", want: notifier.SeverityError},
+		{name: "JSONのレベル", mode: "plain", message: `{"level":"warn","msg":"fatal in message"}`, want: notifier.SeverityWarning},
+		{name: "JSONのネストしたレベル", mode: "plain", message: `{"log":{"level":"CRITICAL"},"msg":"x"}`, want: notifier.SeverityCritical},
+		{name: "レベルの無いJSON", mode: "plain", message: `{"msg":"ERROR x"}`, want: notifier.SeverityError},
+		{name: "stacktraceのpanic", mode: "stacktrace", message: "panic: runtime error", want: notifier.SeverityCritical},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Classify(tt.mode, tt.message); got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityConfigClassifyQueryTime(t *testing.T) {
+	c, err := ParseSeverityConfig(`{"query_time_bands":[{"min":3,"severity":"error"},{"min":10,"severity":"critical"}]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		seconds float64
+		want    notifier.Severity
+	}{
+		{seconds: 1.5, want: notifier.SeverityWarning},
+		{seconds: 3, want: notifier.SeverityError},
+		{seconds: 12.5, want: notifier.SeverityCritical},
+	}
+
+	for _, tt := range testCases {
+		if got := c.ClassifyQueryTime(tt.seconds); got != tt.want {
+			t.Fatalf("%v: got: %s, want: %s", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestParseSeverityConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		json     string
+		isNormal bool
+	}{
+		{name: "[正常系]メンションとモードの既定値", json: `{"mentions":{"critical":"here","error":"S0123ABCD"},"defaults":{"plain":"warning"}}`, isNormal: true},
+		{name: "[異常系]重要度が不正", json: `{"defaults":{"plain":"urgent"}}`, isNormal: false},
+		{name: "[異常系]正規表現が不正", json: `{"rules":[{"pattern":"(","severity":"error"}]}`, isNormal: false},
+		{name: "[異常系]メンションがハンドル", json: `{"mentions":{"critical":"@ops-team"}}`, isNormal: false},
+		{name: "[異常系]JSONでない", json: `critical`, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverityConfig(tt.json)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// 指定しない項目は既定値を使う
+				if got.Default("plain") != notifier.SeverityWarning || got.Default("stacktrace") != notifier.SeverityError || got.Colors[notifier.SeverityError] != "danger" {
+					t.Fatalf("unexpected config: %+v", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestSeverityConfigDecorate(t *testing.T) {
+	c, _ := ParseSeverityConfig(`{"mentions":{"critical":"here","error":"S0123ABCD"}}`)
+	p := slack.Payload{Attachments: []slack.Attachment{{Title: ":rotating_light:ロググループ /aws/test にて例外が検知されました", Color: "danger"}}}
+
+	testCases := []struct {
+		name      string
+		severity  notifier.Severity
+		wantText  string
+		wantColor string
+		wantTitle string
+	}{
+		{name: "critical", severity: notifier.SeverityCritical, wantText: "<!here>", wantColor: "#E01E5A", wantTitle: ":fire:ロググループ /aws/test にて例外が検知されました"},
+		{name: "error", severity: notifier.SeverityError, wantText: "<!subteam^S0123ABCD>", wantColor: "danger", wantTitle: ":rotating_light:ロググループ /aws/test にて例外が検知されました"},
+		{name: "warning", severity: notifier.SeverityWarning, wantText: "", wantColor: "warning", wantTitle: ":warning:ロググループ /aws/test にて例外が検知されました"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Decorate(p, tt.severity)
+			a := got.Attachments[0]
+			if got.Text != tt.wantText || a.Color != tt.wantColor || a.Title != tt.wantTitle {
+				t.Fatalf("unexpected payload: %q, %q, %q", got.Text, a.Color, a.Title)
+			}
+		})
+	}
+	// 元のペイロードは変更しない
+	if p.Attachments[0].Color != "danger" || p.Text != "" {
+		t.Fatalf("original payload is modified: %+v", p)
+	}
+}
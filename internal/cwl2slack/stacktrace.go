@@ -0,0 +1,272 @@
+package cwl2slack
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 言語ごとのフレームワーク(ランタイム)のパッケージプレフィックスです。
+// これらに一致するフレームはアプリケーションのフレームとして扱いません。
+var defaultFrameworkPrefixes = map[string][]string{
+	"java":   {"java.", "javax.", "jdk.", "sun.", "com.sun.", "org.springframework.", "org.apache.", "org.hibernate."},
+	"python": {"/usr/lib/python", "/usr/local/lib/python", "site-packages/", "dist-packages/", "<frozen "},
+	"go":     {"runtime.", "net/http.", "testing.", "github.com/aws/aws-lambda-go/"},
+	"nodejs": {"node:", "node_modules/"},
+}
+
+var (
+	// Java
+	javaExceptionPattern = regexp.MustCompile(`^(?:Exception in thread "[^"]*" )?([\w$]+(?:\.[\w$]+)+)(?::\s*(.*))?$`)
+	javaFramePattern     = regexp.MustCompile(`^\s+at ([\w$.<>/]+)\(([^)]*)\)$`)
+	javaCausedByPattern  = regexp.MustCompile(`^Caused by: ([\w$.]+)(?::\s*(.*))?$`)
+
+	// Python
+	pythonTracebackPattern = regexp.MustCompile(`^Traceback \(most recent call last\):$`)
+	pythonFramePattern     = regexp.MustCompile(`^\s+File "([^"]+)", line (\d+), in (.+)$`)
+	pythonExceptionPattern = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?::\s*(.*))?$`)
+
+	// Go
+	goPanicPattern      = regexp.MustCompile(`^panic: (.*?)(?: \[recovered(?:, repanicked)?\])?$`)
+	goPanicFramePattern = regexp.MustCompile(`^panic\(.*\)$`)
+	goGoroutinePattern  = regexp.MustCompile(`^goroutine \d+ \[[^\]]+\]:$`)
+	goFilePattern       = regexp.MustCompile(`^\s+(\S+\.go:\d+)(?: \+0x[0-9a-f]+)?$`)
+
+	// Node.js
+	nodeExceptionPattern = regexp.MustCompile(`^(?:Uncaught )?([A-Z]\w*(?:Error|Exception)|Error)(?: \[\w+\])?(?::\s*(.*))?$`)
+	nodeFramePattern     = regexp.MustCompile(`^\s+at (?:(?:async )?(.+?) \()?(.+?:\d+:\d+)\)?$`)
+	nodeCausePattern     = regexp.MustCompile(`^\s*\[cause\]: ([A-Z]\w*(?:Error|Exception)|Error)(?::\s*(.*))?`)
+)
+
+type StackTrace struct {
+	Language  string
+	Exception string
+	Message   string
+	Frame     string
+	RootCause string
+	Trace     string
+}
+
+// NewStackTraceはログテキストからスタックトレースの言語を判定して解析し、StackTraceインスタンスを返します。
+// prefixesには言語ごとの既定値に加えてフレームワークとして扱うパッケージプレフィックスを指定します。
+// ログテキストがどの言語のスタックトレースとも一致しない場合、エラーを返します
+func NewStackTrace(logText string, prefixes []string) (*StackTrace, error) {
+	lines := strings.Split(strings.TrimRight(logText, "\n"), "\n")
+
+	var s *StackTrace
+	switch detectLanguage(lines) {
+	case "java":
+		s = parseJavaStackTrace(lines, prefixes)
+	case "python":
+		s = parsePythonStackTrace(lines, prefixes)
+	case "go":
+		s = parseGoStackTrace(lines, prefixes)
+	case "nodejs":
+		s = parseNodeStackTrace(lines, prefixes)
+	}
+
+	if s == nil || s.Exception == "" {
+		return nil, fmt.Errorf("failed to parse stack trace")
+	}
+	s.Trace = logText
+
+	return s, nil
+}
+
+// detectLanguageはスタックトレースの特徴的な行から言語を判定します。
+// 判定できない場合は空文字列を返します
+func detectLanguage(lines []string) string {
+	for _, l := range lines {
+		switch {
+		case pythonTracebackPattern.MatchString(l):
+			return "python"
+		case goGoroutinePattern.MatchString(l):
+			return "go"
+		case javaFramePattern.MatchString(l):
+			return "java"
+		case nodeFramePattern.MatchString(l):
+			return "nodejs"
+		}
+	}
+	return ""
+}
+
+// frameはスタックトレースの1フレームです。
+// keyはプレフィックスの判定に使う値で、JavaとGoでは関数名、PythonとNode.jsではファイルパスです
+type frame struct {
+	key  string
+	text string
+}
+
+// isFrameworkはフレームがフレームワークのものかどうかを返します。
+// プレフィックスはkeyの先頭、またはパスの区切り文字の直後に一致した場合にフレームワークとみなします
+func (f frame) isFramework(language string, prefixes []string) bool {
+	for _, p := range append(defaultFrameworkPrefixes[language], prefixes...) {
+		if p == "" {
+			continue
+		}
+		if strings.HasPrefix(f.key, p) || strings.Contains(f.key, "/"+p) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstApplicationFrameはフレームの中からフレームワーク以外の最初のフレームを返します。
+// 全てフレームワークのフレームの場合は最初のフレームを返します
+func firstApplicationFrame(frames []frame, language string, prefixes []string) string {
+	for _, f := range frames {
+		if !f.isFramework(language, prefixes) {
+			return f.text
+		}
+	}
+	if len(frames) > 0 {
+		return frames[0].text
+	}
+	return ""
+}
+
+// Javaのスタックトレースは例外が発生した箇所から順にフレームが並びます。
+// Caused by: が複数ある場合は最後のものが根本原因です
+func parseJavaStackTrace(lines []string, prefixes []string) *StackTrace {
+	s := &StackTrace{Language: "java"}
+	var frames []frame
+
+	for _, l := range lines {
+		if m := javaCausedByPattern.FindStringSubmatch(l); m != nil {
+			s.RootCause = strings.TrimSuffix(m[1]+": "+m[2], ": ")
+			continue
+		}
+		if m := javaFramePattern.FindStringSubmatch(l); m != nil {
+			// 最初の例外のフレームのみを発生箇所の候補にします
+			if s.RootCause == "" {
+				frames = append(frames, frame{key: m[1], text: m[1] + "(" + m[2] + ")"})
+			}
+			continue
+		}
+		if s.Exception == "" {
+			if m := javaExceptionPattern.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+				s.Exception = m[1]
+				s.Message = m[2]
+			}
+		}
+	}
+	s.Frame = firstApplicationFrame(frames, s.Language, prefixes)
+
+	return s
+}
+
+// Pythonのスタックトレースは最も新しい呼び出しが最後に並び、最終行が例外です。
+// 例外が連鎖している場合は最初のトレースバックの例外が根本原因です
+func parsePythonStackTrace(lines []string, prefixes []string) *StackTrace {
+	s := &StackTrace{Language: "python"}
+
+	// トレースバックごとにフレームと例外を集めます
+	type traceback struct {
+		frames    []frame
+		exception string
+		message   string
+	}
+	var tbs []traceback
+
+	for _, l := range lines {
+		if pythonTracebackPattern.MatchString(l) {
+			tbs = append(tbs, traceback{})
+			continue
+		}
+		if len(tbs) == 0 {
+			continue
+		}
+		tb := &tbs[len(tbs)-1]
+		if m := pythonFramePattern.FindStringSubmatch(l); m != nil {
+			tb.frames = append(tb.frames, frame{key: m[1], text: fmt.Sprintf("%s:%s in %s", m[1], m[2], m[3])})
+			continue
+		}
+		// インデントされていない行のうち例外の形式のものを例外とします
+		if tb.exception == "" && !strings.HasPrefix(l, " ") {
+			if m := pythonExceptionPattern.FindStringSubmatch(l); m != nil {
+				tb.exception = m[1]
+				tb.message = m[2]
+			}
+		}
+	}
+	if len(tbs) == 0 {
+		return s
+	}
+
+	last := tbs[len(tbs)-1]
+	s.Exception = last.exception
+	s.Message = last.message
+
+	// 最も新しい呼び出しから順に探します
+	frames := make([]frame, len(last.frames))
+	for i, f := range last.frames {
+		frames[len(last.frames)-1-i] = f
+	}
+	s.Frame = firstApplicationFrame(frames, s.Language, prefixes)
+
+	if len(tbs) > 1 {
+		s.RootCause = strings.TrimSuffix(tbs[0].exception+": "+tbs[0].message, ": ")
+	}
+
+	return s
+}
+
+// Goのpanicは関数名の行とファイル名の行が交互に並びます。
+// recoverした値を再びpanicした場合は、panic({0x...})のフレームより上にdeferで呼ばれた関数が並ぶため、
+// panic(のフレームより下をpanicを発生させた箇所とします
+func parseGoStackTrace(lines []string, prefixes []string) *StackTrace {
+	s := &StackTrace{Language: "go"}
+	var frames []frame
+
+	for i, l := range lines {
+		if s.Exception == "" {
+			if m := goPanicPattern.FindStringSubmatch(l); m != nil {
+				s.Exception = "panic"
+				s.Message = m[1]
+			}
+			continue
+		}
+		if goPanicFramePattern.MatchString(l) {
+			frames = nil
+			continue
+		}
+		if i+1 < len(lines) && !strings.HasPrefix(l, "\t") && !goGoroutinePattern.MatchString(l) {
+			if m := goFilePattern.FindStringSubmatch(lines[i+1]); m != nil {
+				frames = append(frames, frame{key: l, text: l + " " + m[1]})
+			}
+		}
+	}
+	s.Frame = firstApplicationFrame(frames, s.Language, prefixes)
+
+	return s
+}
+
+// Node.jsのスタックトレースは1行目が例外で、以降に呼び出し元のフレームが並びます。
+// Error の cause は [cause]: として出力されます
+func parseNodeStackTrace(lines []string, prefixes []string) *StackTrace {
+	s := &StackTrace{Language: "nodejs"}
+	var frames []frame
+
+	for _, l := range lines {
+		if m := nodeCausePattern.FindStringSubmatch(l); m != nil {
+			s.RootCause = strings.TrimSuffix(m[1]+": "+m[2], ": ")
+			continue
+		}
+		if m := nodeFramePattern.FindStringSubmatch(l); m != nil {
+			if s.RootCause == "" {
+				frames = append(frames, frame{key: m[2], text: strings.TrimPrefix(strings.TrimSpace(l), "at ")})
+			}
+			continue
+		}
+		if s.Exception == "" {
+			if m := nodeExceptionPattern.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+				s.Exception = m[1]
+				s.Message = m[2]
+			}
+		}
+	}
+	s.Frame = firstApplicationFrame(frames, s.Language, prefixes)
+
+	return s
+}
@@ -0,0 +1,114 @@
+package cwl2slack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewStackTrace(t *testing.T) {
+	testCases := []struct {
+		name     string
+		logText  string
+		prefixes []string
+		isNormal bool
+		want     *StackTrace
+	}{
+		{
+			name:     "[正常系]Javaのスタックトレース",
+			logText:  "Exception in thread \"main\" java.lang.IllegalStateException: order not found\n\tat org.springframework.web.Foo.invoke(Foo.java:10)\n\tat com.example.order.OrderService.find(OrderService.java:42)\n\tat com.example.order.OrderController.get(OrderController.java:21)\nCaused by: java.sql.SQLException: connection refused\n\tat com.mysql.Driver.connect(Driver.java:1)\nCaused by: java.net.ConnectException: Connection refused\n\tat java.net.Socket.connect(Socket.java:1)",
+			isNormal: true,
+			want: &StackTrace{
+				Language:  "java",
+				Exception: "java.lang.IllegalStateException",
+				Message:   "order not found",
+				Frame:     "com.example.order.OrderService.find(OrderService.java:42)",
+				RootCause: "java.net.ConnectException: Connection refused",
+			},
+		},
+		{
+			name:     "[正常系]Javaのスタックトレースで追加のプレフィックスを指定した場合",
+			logText:  "java.lang.NullPointerException\n\tat com.example.lib.Util.check(Util.java:3)\n\tat com.example.app.Main.run(Main.java:8)",
+			prefixes: []string{"com.example.lib."},
+			isNormal: true,
+			want: &StackTrace{
+				Language:  "java",
+				Exception: "java.lang.NullPointerException",
+				Frame:     "com.example.app.Main.run(Main.java:8)",
+			},
+		},
+		{
+			name:     "[正常系]Pythonのスタックトレース",
+			logText:  "Traceback (most recent call last):\n  File \"/var/task/app.py\", line 10, in handler\n    fetch()\nKeyError: 'id'\n\nThe above exception was the direct cause of the following exception:\n\nTraceback (most recent call last):\n  File \"/var/task/app.py\", line 20, in handler\n    process()\n  File \"/var/lang/lib/python3.12/site-packages/requests/api.py\", line 5, in get\n    raise ValueError(\"bad\")\nValueError: invalid order",
+			isNormal: true,
+			want: &StackTrace{
+				Language:  "python",
+				Exception: "ValueError",
+				Message:   "invalid order",
+				Frame:     "/var/task/app.py:20 in handler",
+				RootCause: "KeyError: 'id'",
+			},
+		},
+		{
+			name:     "[正常系]Goのpanic",
+			logText:  "panic: runtime error: index out of range [3] with length 3\n\ngoroutine 1 [running]:\nmain.lookup({0x1299e2c84e68?, 0x1299e2c84ea8?, 0x413f7d?}, 0x0?)\n\t/app/main.go:10 +0xb4\nmain.main()\n\t/app/main.go:14 +0x6d\nexit status 2",
+			isNormal: true,
+			want: &StackTrace{
+				Language:  "go",
+				Exception: "panic",
+				Message:   "runtime error: index out of range [3] with length 3",
+				Frame:     "main.lookup({0x1299e2c84e68?, 0x1299e2c84ea8?, 0x413f7d?}, 0x0?) /app/main.go:10",
+			},
+		},
+		{
+			name:     "[正常系]recoverした値を再びpanicした場合",
+			logText:  "panic: unexpected value: 42 [recovered, repanicked]\n\ngoroutine 1 [running]:\nmain.(*handler).handle.func1()\n\t/app/main.go:10 +0x25\npanic({0x5493e0?, 0x2129eba2a070?})\n\t/usr/local/go/src/runtime/panic.go:859 +0x125\nmain.(*handler).handle(0x2129eba62ea8?, {0x559a38?, 0x4a4710?})\n\t/app/main.go:13 +0x78\nmain.main()\n\t/app/main.go:17 +0x26\nexit status 2",
+			isNormal: true,
+			want: &StackTrace{
+				Language:  "go",
+				Exception: "panic",
+				Message:   "unexpected value: 42",
+				Frame:     "main.(*handler).handle(0x2129eba62ea8?, {0x559a38?, 0x4a4710?}) /app/main.go:13",
+			},
+		},
+		{
+			name:     "[正常系]Node.jsのスタックトレース",
+			logText:  "TypeError: Cannot read properties of undefined (reading 'id')\n    at Module._compile (node:internal/modules/cjs/loader:1256:14)\n    at getOrder (/var/task/src/order.js:10:5)\n    at /var/task/node_modules/express/lib/router.js:1:1\n  [cause]: Error: upstream timeout\n    at fetch (/var/task/src/client.js:3:9)",
+			isNormal: true,
+			want: &StackTrace{
+				Language:  "nodejs",
+				Exception: "TypeError",
+				Message:   "Cannot read properties of undefined (reading 'id')",
+				Frame:     "getOrder (/var/task/src/order.js:10:5)",
+				RootCause: "Error: upstream timeout",
+			},
+		},
+		{
+			name:     "[異常系]スタックトレースの形式でない場合",
+			logText:  "[ERROR] something went wrong",
+			isNormal: false,
+			want:     nil,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewStackTrace(tt.logText, tt.prefixes)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				tt.want.Trace = tt.logText
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("\n got: %+v;\nwant: %+v", got, tt.want)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
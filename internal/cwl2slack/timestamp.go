@@ -0,0 +1,63 @@
+package cwl2slack
+
+import (
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// timeFormatは通知に表示する時刻のフォーマットです
+const timeFormat = "2006-01-02 15:04:05 MST"
+
+// locationは時刻を表示するタイムゾーンを返します。設定されていない場合はUTCです
+func (c *Cwl2slack) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// formatTimeは時刻を設定されたタイムゾーンで表示用の文字列にします
+func (c *Cwl2slack) formatTime(t time.Time) string {
+	return t.In(c.location()).Format(timeFormat)
+}
+
+// formatEventTimeはログイベントの時刻の範囲を表示用の文字列にします。
+// 表示上全て同じ時刻の場合は1つの時刻のみを返します
+func (c *Cwl2slack) formatEventTime(logEvents []events.CloudwatchLogsLogEvent) string {
+	first, last := eventTimeRange(logEvents)
+	if f, l := c.formatTime(first), c.formatTime(last); f != l {
+		return f + " 〜 " + l
+	}
+	return c.formatTime(first)
+}
+
+// ingestionDelayはログイベントの時刻からLambdaが呼び出されるまでの遅延を返します。
+// 遅延が閾値を超えていない場合や、閾値が設定されていない場合はfalseを返します
+func (c *Cwl2slack) ingestionDelay(logEvents []events.CloudwatchLogsLogEvent) (time.Duration, bool) {
+	if c.IngestionDelayThreshold <= 0 || c.InvokedAt.IsZero() || len(logEvents) == 0 {
+		return 0, false
+	}
+
+	// 最も古いログイベントの遅延を返します
+	first, _ := eventTimeRange(logEvents)
+	d := c.InvokedAt.Sub(first)
+	if d <= c.IngestionDelayThreshold {
+		return 0, false
+	}
+	return d.Round(time.Second), true
+}
+
+// eventTimeRangeはログイベントの最初と最後の時刻を返します
+func eventTimeRange(logEvents []events.CloudwatchLogsLogEvent) (time.Time, time.Time) {
+	if len(logEvents) == 0 {
+		return time.Time{}, time.Time{}
+	}
+
+	first, last := logEvents[0].Timestamp, logEvents[0].Timestamp
+	for _, e := range logEvents {
+		first = min(first, e.Timestamp)
+		last = max(last, e.Timestamp)
+	}
+	return time.UnixMilli(first), time.UnixMilli(last)
+}
@@ -0,0 +1,93 @@
+package cwl2slack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestFormatEventTime(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		location  *time.Location
+		logEvents []events.CloudwatchLogsLogEvent
+		want      string
+	}{
+		{
+			name:      "[正常系]タイムゾーンが設定されていない場合はUTC",
+			location:  nil,
+			logEvents: []events.CloudwatchLogsLogEvent{{Timestamp: 1716792813043}},
+			want:      "2024-05-27 06:53:33 UTC",
+		},
+		{
+			name:      "[正常系]Asia/Tokyo",
+			location:  tokyo,
+			logEvents: []events.CloudwatchLogsLogEvent{{Timestamp: 1716792813043}},
+			want:      "2024-05-27 15:53:33 JST",
+		},
+		{
+			name:      "[正常系]複数のログイベントの時刻が異なる場合は範囲",
+			location:  tokyo,
+			logEvents: []events.CloudwatchLogsLogEvent{{Timestamp: 1716792823000}, {Timestamp: 1716792813043}},
+			want:      "2024-05-27 15:53:33 JST 〜 2024-05-27 15:53:43 JST",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cwl2slack{Location: tt.location}
+			if got := c.formatEventTime(tt.logEvents); got != tt.want {
+				t.Fatalf("\n got: %v;\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngestionDelay(t *testing.T) {
+	logEvents := []events.CloudwatchLogsLogEvent{{Timestamp: 1716792813043}, {Timestamp: 1716792853043}}
+	invokedAt := time.UnixMilli(1716792813043).Add(3 * time.Minute)
+
+	testCases := []struct {
+		name      string
+		threshold time.Duration
+		invokedAt time.Time
+		wantOK    bool
+		want      time.Duration
+	}{
+		{
+			name:      "[正常系]遅延が閾値を超えている場合",
+			threshold: time.Minute,
+			invokedAt: invokedAt,
+			wantOK:    true,
+			want:      3 * time.Minute,
+		},
+		{
+			name:      "[正常系]遅延が閾値を超えていない場合",
+			threshold: 5 * time.Minute,
+			invokedAt: invokedAt,
+			wantOK:    false,
+		},
+		{
+			name:      "[正常系]閾値が設定されていない場合",
+			threshold: 0,
+			invokedAt: invokedAt,
+			wantOK:    false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cwl2slack{InvokedAt: tt.invokedAt, IngestionDelayThreshold: tt.threshold}
+			got, ok := c.ingestionDelay(logEvents)
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("\n got: %v, %v;\nwant: %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
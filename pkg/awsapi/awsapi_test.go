@@ -0,0 +1,134 @@
+package awsapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AWSのSignature Version 4のテストスイートの認証情報と時刻です
+var (
+	testCredentials = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	testTime        = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+func TestSign(t *testing.T) {
+	testCases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "get-vanilla",
+			url:  "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "get-vanilla-query-order-key-case",
+			url:  "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			Sign(req, nil, "service", "us-east-1", testCredentials, testTime)
+
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Fatalf("\n got: %s;\nwant: %s", got, tt.want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Fatalf("unexpected X-Amz-Date: %s", got)
+			}
+		})
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "https://bucket.s3.us-east-1.amazonaws.com/key", nil)
+	creds := testCredentials
+	creds.SessionToken = "session"
+	Sign(req, []byte("body"), "s3", "us-east-1", creds, testTime)
+
+	if req.Header.Get("X-Amz-Security-Token") != "session" || req.Header.Get("X-Amz-Content-Sha256") == "" {
+		t.Fatalf("unexpected header: %v", req.Header)
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Fatalf("unexpected authorization: %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]成功した場合", status: http.StatusOK, isNormal: true},
+		{name: "[異常系]エラーが返された場合", status: http.StatusBadRequest, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var target, auth, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				target = r.Header.Get("X-Amz-Target")
+				auth = r.Header.Get("Authorization")
+				b, _ := io.ReadAll(r.Body)
+				body = string(b)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"Item":{"pk":{"S":"key"}}}`))
+			}))
+			defer server.Close()
+
+			c := &Client{Region: "ap-northeast-1", Credentials: testCredentials, Endpoint: server.URL}
+			var out struct {
+				Item map[string]map[string]string
+			}
+			err := c.JSON(context.Background(), "dynamodb", "DynamoDB_20120810.GetItem", map[string]string{"TableName": "t"}, &out)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if target != "DynamoDB_20120810.GetItem" || body != `{"TableName":"t"}` || !strings.Contains(auth, "/ap-northeast-1/dynamodb/aws4_request") {
+					t.Fatalf("unexpected request: %s %s %s", target, body, auth)
+				}
+				if out.Item["pk"]["S"] != "key" {
+					t.Fatalf("unexpected response: %+v", out)
+				}
+				// 異常系のテストケース
+			} else {
+				var e *Error
+				if !errors.As(err, &e) || e.StatusCode != tt.status {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	testCases := []struct {
+		region string
+		want   string
+	}{
+		{region: "ap-northeast-1", want: "https://sqs.ap-northeast-1.amazonaws.com"},
+		{region: "cn-north-1", want: "https://sqs.cn-north-1.amazonaws.com.cn"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.region, func(t *testing.T) {
+			if got := (&Client{Region: tt.region}).EndpointURL("sqs"); got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
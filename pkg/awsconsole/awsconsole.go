@@ -0,0 +1,101 @@
+package awsconsole
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultInsightsQueryはLogs Insightsのリンクで使用する既定のクエリです
+const DefaultInsightsQuery = "fields @timestamp, @logStream, @message\n| sort @timestamp desc"
+
+// Endpointはリージョンに対応するマネジメントコンソールのURLを返します
+func Endpoint(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return fmt.Sprintf("https://%s.console.amazonaws.cn", region)
+	case strings.HasPrefix(region, "us-gov-"):
+		return fmt.Sprintf("https://%s.console.amazonaws-us-gov.com", region)
+	default:
+		return fmt.Sprintf("https://%s.console.aws.amazon.com", region)
+	}
+}
+
+// CloudWatchURLはCloudWatchコンソールのフラグメント(#以降)を指定してURLを返します
+func CloudWatchURL(region string, fragment string) string {
+	return fmt.Sprintf("%s/cloudwatch/home?region=%s#%s", Endpoint(region), url.QueryEscape(region), fragment)
+}
+
+// LogEventsURLはログストリームのログイベントを指定した期間で表示するURLを返します
+func LogEventsURL(region string, logGroup string, logStream string, start time.Time, end time.Time) string {
+	fragment := "logsV2:log-groups/log-group/" + Escape(EncodeURIComponent(logGroup)) +
+		"/log-events/" + Escape(EncodeURIComponent(logStream)) +
+		Escape(fmt.Sprintf("?start=%d&end=%d", start.UnixMilli(), end.UnixMilli()))
+
+	return CloudWatchURL(region, fragment)
+}
+
+// AlarmURLはCloudWatchアラームの詳細を表示するURLを返します
+func AlarmURL(region string, alarmName string) string {
+	return CloudWatchURL(region, "alarmsV2:alarm/"+EncodeURIComponent(alarmName))
+}
+
+// LogsInsightsURLはロググループに対してクエリを指定した期間で実行するLogs InsightsのURLを返します
+func LogsInsightsURL(region string, logGroups []string, start time.Time, end time.Time, query string) string {
+	var sources strings.Builder
+	for _, g := range logGroups {
+		sources.WriteString("~" + jsurlString(g))
+	}
+
+	detail := "~(end~" + jsurlString(end.UTC().Format("2006-01-02T15:04:05.000Z")) +
+		"~start~" + jsurlString(start.UTC().Format("2006-01-02T15:04:05.000Z")) +
+		"~timeType~'ABSOLUTE~tz~'UTC" +
+		"~editorString~" + jsurlString(query) +
+		"~source~(" + sources.String() + "))"
+
+	return CloudWatchURL(region, "logsV2:logs-insights"+Escape("?queryDetail="+detail))
+}
+
+// EscapeはCloudWatchコンソールのフラグメント用に文字列をエスケープします。
+// コンソールはEncodeURIComponentした文字列の%を$に置き換えた形式を使用します。
+// ロググループ名などは事前にEncodeURIComponentしたものを渡すため、例えば"/"は"$252F"になります
+func Escape(s string) string {
+	return strings.ReplaceAll(EncodeURIComponent(s), "%", "$")
+}
+
+// EncodeURIComponentはJavaScriptのencodeURIComponentと同じ規則で文字列をエンコードします。
+// url.QueryEscapeと違い、空白は%20になり、!~*'()はエンコードされません
+func EncodeURIComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.IndexByte("-_.!~*'()", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// jsurlStringはLogs InsightsのqueryDetailで使われるJSURL形式の文字列を返します。
+// 英数字と-_.以外の文字は*XX(16進数)にエスケープされます
+func jsurlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		case r == '$':
+			b.WriteByte('!')
+		case r < 0x100:
+			fmt.Fprintf(&b, "*%02x", r)
+		default:
+			fmt.Fprintf(&b, "**%04x", r)
+		}
+	}
+	return b.String()
+}
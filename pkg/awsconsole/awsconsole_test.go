@@ -0,0 +1,79 @@
+package awsconsole
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndpoint(t *testing.T) {
+	testCases := []struct {
+		name   string
+		region string
+		want   string
+	}{
+		{name: "商用リージョン", region: "ap-northeast-1", want: "https://ap-northeast-1.console.aws.amazon.com"},
+		{name: "中国リージョン", region: "cn-north-1", want: "https://cn-north-1.console.amazonaws.cn"},
+		{name: "GovCloudリージョン", region: "us-gov-west-1", want: "https://us-gov-west-1.console.amazonaws-us-gov.com"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Endpoint(tt.region); got != tt.want {
+				t.Fatalf("\n got: %v;\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogEventsURL(t *testing.T) {
+	start := time.UnixMilli(1716792813043)
+	end := time.UnixMilli(1716792813044)
+
+	got := LogEventsURL("ap-northeast-1", "/aws/lambda/my-func", "2024/05/27/[$LATEST]abc", start, end)
+	want := "https://ap-northeast-1.console.aws.amazon.com/cloudwatch/home?region=ap-northeast-1#logsV2:log-groups/log-group/$252Faws$252Flambda$252Fmy-func/log-events/2024$252F05$252F27$252F$255B$2524LATEST$255Dabc$3Fstart$3D1716792813043$26end$3D1716792813044"
+
+	if got != want {
+		t.Fatalf("\n got: %v;\nwant: %v", got, want)
+	}
+}
+
+func TestAlarmURL(t *testing.T) {
+	got := AlarmURL("ap-northeast-1", "api 5xx/errors")
+	want := "https://ap-northeast-1.console.aws.amazon.com/cloudwatch/home?region=ap-northeast-1#alarmsV2:alarm/api%205xx%2Ferrors"
+
+	if got != want {
+		t.Fatalf("\n got: %v;\nwant: %v", got, want)
+	}
+}
+
+func TestLogsInsightsURL(t *testing.T) {
+	start := time.Date(2024, 5, 27, 6, 48, 33, 0, time.UTC)
+	end := time.Date(2024, 5, 27, 6, 58, 33, 0, time.UTC)
+
+	got := LogsInsightsURL("ap-northeast-1", []string{"/aws/lambda/my-func"}, start, end, "fields @message")
+	want := "https://ap-northeast-1.console.aws.amazon.com/cloudwatch/home?region=ap-northeast-1#logsV2:logs-insights$3FqueryDetail$3D~(end~'2024-05-27T06*3a58*3a33.000Z~start~'2024-05-27T06*3a48*3a33.000Z~timeType~'ABSOLUTE~tz~'UTC~editorString~'fields*20*40message~source~(~'*2faws*2flambda*2fmy-func))"
+
+	if got != want {
+		t.Fatalf("\n got: %v;\nwant: %v", got, want)
+	}
+}
+
+func TestEncodeURIComponent(t *testing.T) {
+	testCases := []struct {
+		name string
+		str  string
+		want string
+	}{
+		{name: "エンコードしない文字", str: "aZ09-_.!~*'()", want: "aZ09-_.!~*'()"},
+		{name: "空白と記号", str: "a b/c?d=e&f", want: "a%20b%2Fc%3Fd%3De%26f"},
+		{name: "マルチバイト文字", str: "あ", want: "%E3%81%82"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeURIComponent(tt.str); got != tt.want {
+				t.Fatalf("\n got: %v;\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package chatwork
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// DefaultURLはChatwork APIのエンドポイントです
+const DefaultURL = "https://api.chatwork.com/v2"
+
+// MaxBodyLengthはメッセージの本文の上限です
+const MaxBodyLength = 65535
+
+// tagPatternはChatworkのメッセージ記法のタグ([info]、[To:123]など)です
+var tagPattern = regexp.MustCompile(`(?i)\[(/?(?:info|title|code|hr|qt|qtmeta|to|toall|rp|picon|piconname|preview|download|task|dtext)\b)`)
+
+// ChatworkはAPIトークンを使ってルームにメッセージを投稿します。URLが空の場合はDefaultURLに送信します
+// https://developer.chatwork.com/reference/post-rooms-room_id-messages
+type Chatwork struct {
+	URL    string
+	Token  string
+	RoomID string
+}
+
+// Notifyは通知をChatworkのメッセージ記法に変換してルームに投稿します
+func (c *Chatwork) Notify(ctx context.Context, n notifier.Notification) error {
+	base := c.URL
+	if base == "" {
+		base = DefaultURL
+	}
+	endpoint := fmt.Sprintf("%s/rooms/%s/messages", strings.TrimSuffix(base, "/"), url.PathEscape(c.RoomID))
+
+	header := http.Header{
+		"X-Chatworktoken": []string{c.Token},
+		"Content-Type":    []string{"application/x-www-form-urlencoded"},
+	}
+	form := url.Values{"body": []string{NewBody(n.Payload)}}
+	return notifier.Post(ctx, endpoint, header, []byte(form.Encode()))
+}
+
+// NewBodyはSlackのペイロードをChatworkのメッセージ記法に変換します。
+// アタッチメントは[info][title]で囲み、フィールドは「項目: 値」の行、コードブロックは[code]、ボタンはURLの行になります
+func NewBody(p slack.Payload) string {
+	var blocks []string
+	if t := Escape(notifier.Text(p.Text)); t != "" {
+		blocks = append(blocks, t)
+	}
+
+	for _, a := range p.Attachments {
+		var lines []string
+		for _, f := range a.Fields {
+			if code, ok := notifier.Code(f.Value); ok {
+				lines = append(lines, Escape(notifier.Text(f.Title))+":", "[code]"+Escape(code)+"[/code]")
+				continue
+			}
+			lines = append(lines, Escape(notifier.Text(f.Title))+": "+Escape(notifier.Text(f.Value)))
+		}
+		if a.Text != "" {
+			if code, ok := notifier.Code(a.Text); ok {
+				lines = append(lines, "[code]"+Escape(code)+"[/code]")
+			} else {
+				lines = append(lines, Escape(notifier.Text(a.Text)))
+			}
+		}
+		for _, act := range a.Actions {
+			if act.Url != "" {
+				lines = append(lines, Escape(act.Text)+": "+act.Url)
+			}
+		}
+
+		body := strings.Join(lines, "\n")
+		if a.Title != "" {
+			body = "[title]" + Escape(notifier.Text(a.Title)) + "[/title]" + body
+		}
+		blocks = append(blocks, "[info]"+body+"[/info]")
+	}
+
+	return truncate(strings.Join(blocks, "\n"))
+}
+
+// Escapeはログなどに含まれるChatworkのタグ([/info]や[To:123]など)が解釈されないように、[の後ろにゼロ幅スペースを挟みます
+func Escape(s string) string {
+	return tagPattern.ReplaceAllString(s, "[\u200b$1")
+}
+
+// truncateは本文を上限に収めます。切り詰めて閉じられなくなった[code]と[info]は末尾で閉じます
+func truncate(s string) string {
+	if len([]rune(s)) <= MaxBodyLength {
+		return s
+	}
+
+	const closing = "[/code][/info]"
+	s = string([]rune(s)[:MaxBodyLength-len(closing)-len([]rune(slack.Ellipsis))]) + slack.Ellipsis
+	if strings.Count(s, "[code]") > strings.Count(s, "[/code]") {
+		s += "[/code]"
+	}
+	if strings.Count(s, "[info]") > strings.Count(s, "[/info]") {
+		s += "[/info]"
+	}
+	return s
+}
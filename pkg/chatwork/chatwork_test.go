@@ -0,0 +1,131 @@
+package chatwork
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestNewBody(t *testing.T) {
+	testCases := []struct {
+		name    string
+		payload slack.Payload
+		want    string
+	}{
+		{
+			name: "plainモード",
+			payload: slack.Payload{
+				Attachments: []slack.Attachment{
+					{
+						Title: ":rotating_light:CloudWatchLogsにてアラートを検知しました",
+						Fields: []slack.Field{
+							{Title: "Log Group", Value: "testLogGroup"},
+							{Title: "Log Messages", Value: slack.CodeBlock("[ERROR] a < b")},
+						},
+						Actions: []slack.Action{{Text: "Open in CloudWatch", Url: "https://example.com"}},
+					},
+				},
+			},
+			want: "[info][title]🚨CloudWatchLogsにてアラートを検知しました[/title]Log Group: testLogGroup\nLog Messages:\n[code][ERROR] a < b[/code]\nOpen in CloudWatch: https://example.com[/info]",
+		},
+		{
+			name: "slowqueryモード",
+			payload: slack.Payload{
+				Attachments: []slack.Attachment{
+					{
+						Title: ":rotating_light:ロググループ /aws/rds にて閾値を超えたスロークエリーが検知されました",
+						Fields: []slack.Field{
+							{Title: "クエリ実行ユーザ", Value: "admin", Short: true},
+							{Title: "クエリ実行時間", Value: "4.27", Short: true},
+							{Title: "実行したクエリ", Value: slack.CodeBlock("SELECT 1;")},
+						},
+					},
+				},
+			},
+			want: "[info][title]🚨ロググループ /aws/rds にて閾値を超えたスロークエリーが検知されました[/title]クエリ実行ユーザ: admin\nクエリ実行時間: 4.27\n実行したクエリ:\n[code]SELECT 1;[/code][/info]",
+		},
+		{
+			name: "ログに含まれるタグ",
+			payload: slack.Payload{
+				Attachments: []slack.Attachment{
+					{Title: "t", Fields: []slack.Field{{Title: "Log Messages", Value: slack.CodeBlock("[/code][/info][To:123] [toall]")}}},
+				},
+			},
+			want: "[info][title]t[/title]Log Messages:\n[code][\u200b/code][\u200b/info][\u200bTo:123] [\u200btoall][/code][/info]",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewBody(tt.payload); got != tt.want {
+				t.Fatalf("\n got: %q;\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBodyLimit(t *testing.T) {
+	p := slack.Payload{
+		Attachments: []slack.Attachment{
+			{Title: "t", Fields: []slack.Field{{Title: "Log Messages", Value: slack.CodeBlock(strings.Repeat("x", 70000))}}},
+		},
+	}
+
+	got := NewBody(p)
+
+	if n := utf8.RuneCountInString(got); n > MaxBodyLength {
+		t.Fatalf("body too long: %d", n)
+	}
+	if !strings.HasSuffix(got, slack.Ellipsis+"[/code][/info]") {
+		t.Fatalf("unexpected suffix: %q", got[len(got)-30:])
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]投稿に成功した場合", status: http.StatusOK, isNormal: true},
+		{name: "[異常系]トークンが不正な場合", status: http.StatusUnauthorized, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, token, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				token = r.Header.Get("X-ChatWorkToken")
+				r.ParseForm()
+				body = r.PostForm.Get("body")
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			c := Chatwork{URL: server.URL, Token: "token", RoomID: "12345"}
+			err := c.Notify(context.Background(), notifier.Notification{Payload: slack.Payload{Text: "hello"}})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if path != "/rooms/12345/messages" || token != "token" || body != "hello" {
+					t.Fatalf("unexpected request: %s %s %s", path, token, body)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package deadletter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+	"github.com/tomozo6/cwl2slack/pkg/dedup"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+)
+
+// Recordは送信できなかった通知と、その原因です。
+// 元のログを調査できるように、通知の元になったCloudwatchLogsDataも保存します
+type Record struct {
+	ID           string                     `json:"id"`
+	Destination  string                     `json:"destination"`
+	Notification notifier.Notification      `json:"notification"`
+	Data         *events.CloudwatchLogsData `json:"data,omitempty"`
+	Error        string                     `json:"error"`
+	FailedAt     time.Time                  `json:"failed_at"`
+}
+
+// NewRecordはRecordのコンストラクタです。
+// IDは送信先と通知の内容から決まるため、同じ通知を何度保存しても1つのRecordになります(SQSを除く)
+func NewRecord(destination string, n notifier.Notification, data *events.CloudwatchLogsData, err error, failedAt time.Time) Record {
+	r := Record{Destination: destination, Notification: n, Data: data, FailedAt: failedAt}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	r.ID = dedup.Key(destination, n)[:32]
+	return r
+}
+
+// Messageは保存されているRecordと、削除するときに指定するハンドルです
+type Message struct {
+	Handle string
+	Record Record
+}
+
+// Sinkは送信できなかった通知を保存します
+type Sink interface {
+	Put(ctx context.Context, r Record) error
+}
+
+// Storeは保存した通知を読み出して再送(redrive)できるSinkです
+type Store interface {
+	Sink
+	// Listは保存されている通知を返します
+	List(ctx context.Context) ([]Message, error)
+	// Deleteは再送できた通知を削除します
+	Delete(ctx context.Context, handle string) error
+}
+
+// Openは保存先のURLからStoreを返します。
+//   - https://sqs.<region>.amazonaws.com/<account>/<queue>: SQSのキュー
+//   - s3://<bucket>/<prefix>: S3のバケット
+//   - file:///<dir> または /<dir>: ローカルのディレクトリ(テスト用)
+func Open(rawURL string, client *awsapi.Client) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dead-letter URL: %w", err)
+	}
+
+	switch {
+	case u.Scheme == "https" && strings.HasPrefix(u.Host, "sqs."):
+		return &SQS{Client: client, QueueURL: rawURL}, nil
+	case u.Scheme == "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid dead-letter URL: bucket is required: %s", rawURL)
+		}
+		return &S3{Client: client, Bucket: u.Host, Prefix: strings.TrimPrefix(u.Path, "/")}, nil
+	case u.Scheme == "file":
+		return &Dir{Path: u.Path}, nil
+	case u.Scheme == "" && strings.HasPrefix(rawURL, "/"):
+		return &Dir{Path: rawURL}, nil
+	default:
+		return nil, fmt.Errorf("unsupported dead-letter URL: %s", rawURL)
+	}
+}
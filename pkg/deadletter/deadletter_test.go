@@ -0,0 +1,86 @@
+package deadletter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// testClientはテスト用のサーバーに接続するClientを返します
+func testClient(endpoint string) *awsapi.Client {
+	c := awsapi.NewClient("ap-northeast-1")
+	c.Credentials = awsapi.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	c.Endpoint = endpoint
+	return c
+}
+
+// testRecordはテスト用のRecordを返します
+func testRecord(text string, failedAt time.Time) Record {
+	n := notifier.Notification{Payload: slack.Payload{Text: text}, Severity: notifier.SeverityError, EventIDs: []string{text}}
+	data := &events.CloudwatchLogsData{LogGroup: "/aws/test", LogEvents: []events.CloudwatchLogsLogEvent{{ID: text, Message: text}}}
+	return NewRecord("slack", n, data, errors.New("received non-2xx response: 503"), failedAt)
+}
+
+func TestNewRecord(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := testRecord("a", now)
+
+	if a.ID == "" || a.Error != "received non-2xx response: 503" || a.Data.LogGroup != "/aws/test" {
+		t.Fatalf("unexpected record: %+v", a)
+	}
+	// 同じ通知は同じID、異なる通知は異なるIDになる
+	if b := testRecord("a", now.Add(time.Minute)); b.ID != a.ID {
+		t.Fatalf("ids should be same: %s, %s", a.ID, b.ID)
+	}
+	if b := testRecord("b", now); b.ID == a.ID {
+		t.Fatalf("ids should be different: %s", a.ID)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	testCases := []struct {
+		name     string
+		url      string
+		isNormal bool
+		want     Store
+	}{
+		{name: "[正常系]SQS", url: "https://sqs.ap-northeast-1.amazonaws.com/123456789012/cwl2slack-dlq", isNormal: true, want: &SQS{}},
+		{name: "[正常系]S3", url: "s3://cwl2slack-dlq/failed", isNormal: true, want: &S3{}},
+		{name: "[正常系]ディレクトリ(file)", url: "file:///tmp/dlq", isNormal: true, want: &Dir{}},
+		{name: "[正常系]ディレクトリ(パス)", url: "/tmp/dlq", isNormal: true, want: &Dir{}},
+		{name: "[異常系]S3のバケットが無い場合", url: "s3:///failed", isNormal: false},
+		{name: "[異常系]未対応のURL", url: "https://example.com/dlq", isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(tt.url, testClient(""))
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if gotType, wantType := fmt.Sprintf("%T", got), fmt.Sprintf("%T", tt.want); gotType != wantType {
+					t.Fatalf("unexpected store: %s", gotType)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+
+	s, _ := Open("s3://cwl2slack-dlq/failed", testClient(""))
+	if s3 := s.(*S3); s3.Bucket != "cwl2slack-dlq" || s3.Prefix != "failed" {
+		t.Fatalf("unexpected s3: %+v", s3)
+	}
+}
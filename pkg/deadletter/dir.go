@@ -0,0 +1,72 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dirはローカルのディレクトリに1通知1ファイルのJSONで保存するStoreです。テストや手元での確認に使います
+type Dir struct {
+	Path string
+}
+
+func (d *Dir) Put(ctx context.Context, r Record) error {
+	if err := os.MkdirAll(d.Path, 0o755); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(d.Path, r.ID+".json"), b, 0o644); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+func (d *Dir) List(ctx context.Context) ([]Message, error) {
+	entries, err := os.ReadDir(d.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter directory: %w", err)
+	}
+
+	var messages []Message
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(d.Path, e.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+		var r Record
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal record %s: %w", e.Name(), err)
+		}
+		messages = append(messages, Message{Handle: path, Record: r})
+	}
+
+	// 失敗した順に再送する
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Record.FailedAt.Before(messages[j].Record.FailedAt)
+	})
+	return messages, nil
+}
+
+func (d *Dir) Delete(ctx context.Context, handle string) error {
+	if err := os.Remove(handle); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package deadletter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := &Dir{Path: filepath.Join(t.TempDir(), "dlq")}
+
+	// ディレクトリが無い場合は空
+	messages, err := d.List(ctx)
+	if err != nil || len(messages) != 0 {
+		t.Fatalf("unexpected result: %v, %v", messages, err)
+	}
+
+	for _, r := range []Record{testRecord("b", now.Add(time.Minute)), testRecord("a", now), testRecord("a", now)} {
+		if err := d.Put(ctx, r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// 同じ通知は1つにまとまり、失敗した順に並ぶ
+	messages, err = d.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Record.Notification.Payload.Text != "a" || messages[1].Record.Notification.Payload.Text != "b" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+
+	if err := d.Delete(ctx, messages[0].Handle); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	messages, _ = d.List(ctx)
+	if len(messages) != 1 || messages[0].Record.Notification.Payload.Text != "b" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+}
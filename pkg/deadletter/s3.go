@@ -0,0 +1,106 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+)
+
+// S3はS3のバケットに1通知1オブジェクトのJSONで保存するStoreです
+type S3 struct {
+	Client *awsapi.Client
+	Bucket string
+	Prefix string
+}
+
+// objectURLはパス形式のオブジェクトのURLを返します
+func (s *S3) objectURL(key string) string {
+	u := &url.URL{Path: "/" + s.Bucket + "/" + key}
+	return s.Client.EndpointURL("s3") + u.EscapedPath()
+}
+
+func (s *S3) Put(ctx context.Context, r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	key := path.Join(s.Prefix, r.ID+".json")
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	_, err = s.Client.Do(ctx, "s3", "PUT", s.objectURL(key), header, b)
+	return err
+}
+
+// listBucketResultはListObjectsV2のレスポンスです
+type listBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *S3) List(ctx context.Context) ([]Message, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": []string{"2"}}
+		if s.Prefix != "" {
+			q.Set("prefix", strings.TrimSuffix(s.Prefix, "/")+"/")
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+
+		b, err := s.Client.Do(ctx, "s3", "GET", s.objectURL("")+"?"+q.Encode(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var out listBucketResult
+		if err := xml.Unmarshal(b, &out); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		for _, c := range out.Contents {
+			if strings.HasSuffix(c.Key, ".json") {
+				keys = append(keys, c.Key)
+			}
+		}
+
+		if !out.IsTruncated {
+			break
+		}
+		token = out.NextContinuationToken
+	}
+
+	var messages []Message
+	for _, key := range keys {
+		b, err := s.Client.Do(ctx, "s3", "GET", s.objectURL(key), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var r Record
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal record %s: %w", key, err)
+		}
+		messages = append(messages, Message{Handle: key, Record: r})
+	}
+
+	// 失敗した順に再送する
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Record.FailedAt.Before(messages[j].Record.FailedAt)
+	})
+	return messages, nil
+}
+
+func (s *S3) Delete(ctx context.Context, handle string) error {
+	_, err := s.Client.Do(ctx, "s3", "DELETE", s.objectURL(handle), nil, nil)
+	return err
+}
@@ -0,0 +1,95 @@
+package deadletter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeS3はパス形式のPutObject、GetObject、DeleteObject、ListObjectsV2を再現するサーバーです
+type fakeS3 struct {
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == "PUT":
+		f.objects[key], _ = io.ReadAll(r.Body)
+	case r.Method == "GET" && r.URL.Query().Get("list-type") == "2":
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		// 1ページに1件ずつ返す
+		token := r.URL.Query().Get("continuation-token")
+		var contents string
+		next := ""
+		for i, k := range keys {
+			if token == "" && i == 0 || token == k {
+				contents = fmt.Sprintf("<Contents><Key>%s</Key></Contents>", k)
+				if i+1 < len(keys) {
+					next = keys[i+1]
+				}
+			}
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>%s<IsTruncated>%v</IsTruncated><NextContinuationToken>%s</NextContinuationToken></ListBucketResult>`, contents, next != "", next)
+	case r.Method == "GET":
+		b, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeS3{objects: map[string][]byte{"other/x.json": []byte(`{}`)}}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	s := &S3{Client: testClient(ts.URL), Bucket: "bucket", Prefix: "failed"}
+
+	for _, r := range []Record{testRecord("b", now.Add(time.Minute)), testRecord("a", now)} {
+		if err := s.Put(ctx, r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// プレフィックスの外のオブジェクトは含まず、失敗した順に並ぶ
+	messages, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Record.Notification.Payload.Text != "a" || !strings.HasPrefix(messages[0].Handle, "failed/") {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+
+	if err := s.Delete(ctx, messages[0].Handle); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.objects) != 2 {
+		t.Fatalf("object is not deleted: %v", fake.objects)
+	}
+}
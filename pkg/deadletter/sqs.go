@@ -0,0 +1,90 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+)
+
+// SQSはSQSのキューに保存するStoreです。
+// メッセージの最大サイズは256KiBのため、大きなCloudwatchLogsDataは保存に失敗します
+type SQS struct {
+	Client   *awsapi.Client
+	QueueURL string
+
+	// 再送中に他の再送処理から見えなくする時間(秒)。0の場合は300秒です
+	VisibilityTimeout int
+}
+
+// sqsMessageはReceiveMessageで受け取るメッセージです
+type sqsMessage struct {
+	MessageId     string
+	ReceiptHandle string
+	Body          string
+}
+
+func (s *SQS) Put(ctx context.Context, r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	in := map[string]any{
+		"QueueUrl":    s.QueueURL,
+		"MessageBody": string(b),
+	}
+	return s.Client.JSON(ctx, "sqs", "AmazonSQS.SendMessage", in, nil)
+}
+
+// Listはキューが空になるまでメッセージを受信します。
+// 受信したメッセージは可視性タイムアウトの間は再び受信されないため、1回の呼び出しで同じメッセージは返しません
+func (s *SQS) List(ctx context.Context) ([]Message, error) {
+	visibilityTimeout := s.VisibilityTimeout
+	if visibilityTimeout == 0 {
+		visibilityTimeout = 300
+	}
+
+	var messages []Message
+	received := map[string]bool{}
+	for {
+		in := map[string]any{
+			"QueueUrl":            s.QueueURL,
+			"MaxNumberOfMessages": 10,
+			"VisibilityTimeout":   visibilityTimeout,
+		}
+		var out struct {
+			Messages []sqsMessage
+		}
+		if err := s.Client.JSON(ctx, "sqs", "AmazonSQS.ReceiveMessage", in, &out); err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, m := range out.Messages {
+			if received[m.MessageId] {
+				continue
+			}
+			received[m.MessageId] = true
+
+			var r Record
+			if err := json.Unmarshal([]byte(m.Body), &r); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal message %s: %w", m.MessageId, err)
+			}
+			messages = append(messages, Message{Handle: m.ReceiptHandle, Record: r})
+			added++
+		}
+		if added == 0 {
+			return messages, nil
+		}
+	}
+}
+
+func (s *SQS) Delete(ctx context.Context, handle string) error {
+	in := map[string]any{
+		"QueueUrl":      s.QueueURL,
+		"ReceiptHandle": handle,
+	}
+	return s.Client.JSON(ctx, "sqs", "AmazonSQS.DeleteMessage", in, nil)
+}
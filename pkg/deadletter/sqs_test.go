@@ -0,0 +1,85 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeSQSはSQSのJSONプロトコルのSendMessage、ReceiveMessage、DeleteMessageを再現するサーバーです。
+// 受信したメッセージは削除されるまで再び受信されません
+type fakeSQS struct {
+	bodies   map[string]string
+	inflight map[string]bool
+	next     int
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	var in struct {
+		QueueUrl            string
+		MessageBody         string
+		MaxNumberOfMessages int
+		ReceiptHandle       string
+	}
+	json.Unmarshal(b, &in)
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "AmazonSQS.SendMessage":
+		f.next++
+		id := fmt.Sprintf("m%d", f.next)
+		f.bodies[id] = in.MessageBody
+		json.NewEncoder(w).Encode(map[string]string{"MessageId": id})
+	case "AmazonSQS.ReceiveMessage":
+		var messages []sqsMessage
+		for id, body := range f.bodies {
+			if !f.inflight[id] && len(messages) < in.MaxNumberOfMessages {
+				f.inflight[id] = true
+				messages = append(messages, sqsMessage{MessageId: id, ReceiptHandle: "h-" + id, Body: body})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Messages": messages})
+	case "AmazonSQS.DeleteMessage":
+		delete(f.bodies, in.ReceiptHandle[len("h-"):])
+		w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestSQS(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeSQS{bodies: map[string]string{}, inflight: map[string]bool{}}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	s := &SQS{Client: testClient(ts.URL), QueueURL: "https://sqs.ap-northeast-1.amazonaws.com/123456789012/dlq"}
+
+	// 1回の受信の上限(10件)より多く保存する
+	for i := 0; i < 12; i++ {
+		if err := s.Put(ctx, testRecord(fmt.Sprintf("message%d", i), now)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	messages, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 12 {
+		t.Fatalf("unexpected number of messages: %d", len(messages))
+	}
+
+	if err := s.Delete(ctx, messages[0].Handle); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.bodies) != 11 {
+		t.Fatalf("message is not deleted: %d", len(fake.bodies))
+	}
+}
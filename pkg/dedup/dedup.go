@@ -0,0 +1,82 @@
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/ttlstore"
+)
+
+// DefaultTTLは送信済みの記録を保持する既定の期間です。
+// CloudWatch Logsのサブスクリプションの再送は通常数時間以内に行われます
+const DefaultTTL = 24 * time.Hour
+
+// Storeは送信済みの通知のキーを期限付きで記録します
+type Store interface {
+	// Seenはキーが記録されていて、期限が切れていない場合にtrueを返します
+	Seen(ctx context.Context, key string) (bool, error)
+	// Markはキーをttlの間記録します
+	Mark(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// Keyは送信先と通知から送信済みかどうかを判定するためのキーを返します。
+// 通知の元になったログイベントのIDがある場合はIDを使います。
+// 取り込み遅延など再送時に内容が変わる項目があるため、IDが無い場合のみペイロードのハッシュ値を使います
+func Key(destination string, n notifier.Notification) string {
+	h := sha256.New()
+	h.Write([]byte(destination + "\n"))
+
+	if len(n.EventIDs) > 0 {
+		ids := slices.Clone(n.EventIDs)
+		slices.Sort(ids)
+		for _, id := range ids {
+			h.Write([]byte("id:" + id + "\n"))
+		}
+	} else {
+		b, _ := json.Marshal(n.Payload)
+		h.Write([]byte("payload:"))
+		h.Write(b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MemoryStoreはメモリにキーを記録するStoreです。
+// Lambdaの同じ実行環境で再送された場合のみ重複を防げます。テストでも使います
+type MemoryStore ttlstore.Memory[struct{}]
+
+// NewMemoryStoreはMemoryStoreのコンストラクタです
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) memory() *ttlstore.Memory[struct{}] {
+	return (*ttlstore.Memory[struct{}])(s)
+}
+
+func (s *MemoryStore) Seen(ctx context.Context, key string) (bool, error) {
+	_, ok := s.memory().Get(key)
+	return ok, nil
+}
+
+func (s *MemoryStore) Mark(ctx context.Context, key string, ttl time.Duration) error {
+	s.memory().Put(key, struct{}{}, ttl)
+	return nil
+}
+
+// DynamoDBStoreはDynamoDBのテーブルにキーを記録するStoreです。テーブルの形式はttlstore.DynamoDBと同じです
+type DynamoDBStore ttlstore.DynamoDB
+
+func (s *DynamoDBStore) Seen(ctx context.Context, key string) (bool, error) {
+	item, err := (*ttlstore.DynamoDB)(s).Get(ctx, key)
+	return item != nil, err
+}
+
+func (s *DynamoDBStore) Mark(ctx context.Context, key string, ttl time.Duration) error {
+	return (*ttlstore.DynamoDB)(s).Put(ctx, key, nil, ttl)
+}
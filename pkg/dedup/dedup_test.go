@@ -0,0 +1,162 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+	"github.com/tomozo6/cwl2slack/pkg/ttlstore"
+)
+
+func TestKey(t *testing.T) {
+	n := notifier.Notification{Payload: slack.Payload{Text: "a"}, EventIDs: []string{"2", "1"}}
+
+	testCases := []struct {
+		name  string
+		a     string
+		other notifier.Notification
+		b     string
+		same  bool
+	}{
+		{name: "IDの順序が異なる場合", a: "slack", other: notifier.Notification{Payload: slack.Payload{Text: "a"}, EventIDs: []string{"1", "2"}}, b: "slack", same: true},
+		{name: "IDが同じで内容が異なる場合", a: "slack", other: notifier.Notification{Payload: slack.Payload{Text: "b"}, EventIDs: []string{"1", "2"}}, b: "slack", same: true},
+		{name: "送信先が異なる場合", a: "slack", other: n, b: "teams", same: false},
+		{name: "IDが異なる場合", a: "slack", other: notifier.Notification{Payload: slack.Payload{Text: "a"}, EventIDs: []string{"1"}}, b: "slack", same: false},
+		{name: "IDが無い場合は内容で判定する", a: "slack", other: notifier.Notification{Payload: slack.Payload{Text: "a"}}, b: "slack", same: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key(tt.a, n) == Key(tt.b, tt.other); got != tt.same {
+				t.Fatalf("got: %v, want: %v", got, tt.same)
+			}
+		})
+	}
+
+	p := notifier.Notification{Payload: slack.Payload{Text: "a"}}
+	if Key("slack", p) != Key("slack", notifier.Notification{Payload: slack.Payload{Text: "a"}}) {
+		t.Fatalf("payload key is not stable")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if seen, _ := s.Seen(ctx, "k"); seen {
+		t.Fatalf("unexpected seen")
+	}
+	s.Mark(ctx, "k", time.Hour)
+	if seen, _ := s.Seen(ctx, "k"); !seen {
+		t.Fatalf("expected seen")
+	}
+
+	// 期限が切れた場合は送信済みとみなさない
+	now = now.Add(time.Hour)
+	if seen, _ := s.Seen(ctx, "k"); seen {
+		t.Fatalf("unexpected seen after ttl")
+	}
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := map[string]map[string]ttlstore.Attribute{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("request is not signed")
+		}
+		b, _ := io.ReadAll(r.Body)
+		var in struct {
+			TableName      string
+			Key            map[string]ttlstore.Attribute
+			Item           map[string]ttlstore.Attribute
+			ConsistentRead bool
+		}
+		json.Unmarshal(b, &in)
+		if in.TableName != "cwl2slack-dedup" {
+			t.Errorf("unexpected table: %s", in.TableName)
+		}
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.GetItem":
+			if !in.ConsistentRead {
+				t.Errorf("expected consistent read")
+			}
+			item, ok := items[in.Key["key"].S]
+			if !ok {
+				w.Write([]byte(`{}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"Item": item})
+		case "DynamoDB_20120810.PutItem":
+			items[in.Item["key"].S] = in.Item
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"UnknownOperationException"}`))
+		}
+	}))
+	defer ts.Close()
+
+	c := awsapi.NewClient("ap-northeast-1")
+	c.Credentials = awsapi.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	c.Endpoint = ts.URL
+	s := &DynamoDBStore{Client: c, Table: "cwl2slack-dedup", Now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		mark     bool
+		advance  time.Duration
+		isNormal bool
+		want     bool
+	}{
+		{name: "[正常系]記録が無い場合", isNormal: true, want: false},
+		{name: "[正常系]記録した場合", mark: true, isNormal: true, want: true},
+		{name: "[正常系]期限が切れた場合", advance: 2 * time.Hour, isNormal: true, want: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mark {
+				if err := s.Mark(ctx, "k", time.Hour); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			now = now.Add(tt.advance)
+
+			got, err := s.Seen(ctx, "k")
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Fatalf("got: %v, want: %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	// 異常系のテストケース
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ResourceNotFoundException"}`))
+	}))
+	defer es.Close()
+	c.Endpoint = es.URL
+	if _, err := s.Seen(ctx, "k"); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+}
@@ -0,0 +1,215 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// Discordのドキュメントに記載されているメッセージの上限です
+// https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	MaxContentLength     = 2000
+	MaxEmbeds            = 10
+	MaxTitleLength       = 256
+	MaxDescriptionLength = 4096
+	MaxFields            = 25
+	MaxFieldNameLength   = 256
+	MaxFieldValueLength  = 1024
+	MaxFooterLength      = 2048
+	MaxEmbedsLength      = 6000
+)
+
+// MessageはDiscordのWebhookに送信するメッセージです
+// https://discord.com/developers/docs/resources/webhook#execute-webhook
+type Message struct {
+	Username        string           `json:"username,omitempty"`
+	Content         string           `json:"content,omitempty"`
+	Embeds          []Embed          `json:"embeds,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions"`
+}
+
+type Embed struct {
+	Title       string  `json:"title,omitempty"`
+	Description string  `json:"description,omitempty"`
+	URL         string  `json:"url,omitempty"`
+	Color       int     `json:"color,omitempty"`
+	Fields      []Field `json:"fields,omitempty"`
+	Footer      *Footer `json:"footer,omitempty"`
+	Timestamp   string  `json:"timestamp,omitempty"`
+}
+
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type Footer struct {
+	Text string `json:"text"`
+}
+
+// AllowedMentionsはメッセージ中のメンションを通知するかどうかの設定です。
+// ログに含まれる@everyoneなどで通知されないように、Parseは空にします
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+type Discord struct {
+	URL      string
+	Username string
+}
+
+// Notifyは通知をEmbedに変換してDiscordに送信します
+func (d *Discord) Notify(ctx context.Context, n notifier.Notification) error {
+	m := NewMessage(n.Payload)
+	m.Username = d.Username
+	return notifier.PostJSON(ctx, d.URL, nil, m)
+}
+
+// NewMessageはSlackのペイロードをDiscordのメッセージに変換します。
+// アタッチメントはEmbedになり、コードブロックのフィールドとText、ボタンはdescriptionにまとめます。
+// 上限を超える部分は切り詰め、Embedの数が多すぎる場合は省略した件数をcontentに表示します
+func NewMessage(p slack.Payload) Message {
+	m := Message{AllowedMentions: &AllowedMentions{Parse: []string{}}}
+
+	if p.Text != "" {
+		m.Content = slack.Truncate(notifier.Text(p.Text), MaxContentLength)
+	}
+
+	total := 0
+	for i, a := range p.Attachments {
+		if i == MaxEmbeds {
+			m.Content = strings.TrimSpace(fmt.Sprintf("%s\n%s %d embeds omitted", m.Content, slack.Ellipsis, len(p.Attachments)-i))
+			break
+		}
+
+		e := newEmbed(a)
+
+		// 全てのEmbedの合計の文字数の上限を超える場合は、以降のEmbedを省略します。
+		// 最初のEmbedだけで上限を超える場合は、そのEmbedを上限に収まるように縮めます
+		n := embedLength(e)
+		if i == 0 && n > MaxEmbedsLength {
+			e = shrinkEmbed(e, MaxEmbedsLength)
+			n = embedLength(e)
+		}
+		if total+n > MaxEmbedsLength {
+			m.Content = strings.TrimSpace(fmt.Sprintf("%s\n%s %d embeds omitted", m.Content, slack.Ellipsis, len(p.Attachments)-i))
+			break
+		}
+		total += n
+		m.Embeds = append(m.Embeds, e)
+	}
+
+	return m
+}
+
+// newEmbedはアタッチメントをEmbedに変換します
+func newEmbed(a slack.Attachment) Embed {
+	e := Embed{
+		Title: slack.Truncate(notifier.Text(a.Title), MaxTitleLength),
+		URL:   a.TitleLink,
+		Color: color(a.Color),
+	}
+
+	var description []string
+	for _, f := range a.Fields {
+		if code, ok := notifier.Code(f.Value); ok {
+			description = append(description, fmt.Sprintf("**%s**\n%s", notifier.Text(f.Title), notifier.CodeBlock(code)))
+			continue
+		}
+		if len(e.Fields) == MaxFields-1 && len(a.Fields) > MaxFields {
+			e.Fields = append(e.Fields, Field{Name: slack.Ellipsis, Value: fmt.Sprintf("%d fields omitted", len(a.Fields)-len(e.Fields))})
+			break
+		}
+		e.Fields = append(e.Fields, Field{
+			Name:   slack.Truncate(notifier.Text(f.Title), MaxFieldNameLength),
+			Value:  slack.Truncate(notifier.Text(f.Value), MaxFieldValueLength),
+			Inline: f.Short,
+		})
+	}
+
+	if a.Text != "" {
+		if code, ok := notifier.Code(a.Text); ok {
+			description = append(description, notifier.CodeBlock(code))
+		} else {
+			description = append(description, notifier.Text(a.Text))
+		}
+	}
+
+	// Webhookのメッセージではボタンを使えないため、リンクにします
+	var links []string
+	for _, act := range a.Actions {
+		if act.Url != "" {
+			links = append(links, fmt.Sprintf("[%s](%s)", act.Text, act.Url))
+		}
+	}
+	if len(links) > 0 {
+		description = append(description, strings.Join(links, " | "))
+	}
+	e.Description = slack.Truncate(strings.Join(description, "\n"), MaxDescriptionLength)
+
+	if a.Footer != "" {
+		e.Footer = &Footer{Text: slack.Truncate(notifier.Text(a.Footer), MaxFooterLength)}
+	}
+	if a.Timestamp != 0 {
+		e.Timestamp = time.Unix(a.Timestamp, 0).UTC().Format(time.RFC3339)
+	}
+
+	return e
+}
+
+// shrinkEmbedはEmbedの文字数がlimitに収まるように、後ろのフィールドを省略し、descriptionを切り詰めます
+func shrinkEmbed(e Embed, limit int) Embed {
+	fields := e.Fields
+	omitted := func() Field {
+		return Field{Name: slack.Ellipsis, Value: fmt.Sprintf("%d fields omitted", len(e.Fields)-len(fields))}
+	}
+
+	s := e
+	for len(fields) > 0 {
+		fields = fields[:len(fields)-1]
+		s.Fields = append(fields[:len(fields):len(fields)], omitted())
+		if embedLength(s) <= limit {
+			return s
+		}
+	}
+
+	s.Fields = []Field{omitted()}
+	s.Description = slack.Truncate(e.Description, max(limit-embedLength(Embed{Title: s.Title, Fields: s.Fields, Footer: s.Footer}), 0))
+	return s
+}
+
+// embedLengthはEmbedの合計の文字数の上限の対象となる文字数を返します
+func embedLength(e Embed) int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+	for _, f := range e.Fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+	if e.Footer != nil {
+		n += utf8.RuneCountInString(e.Footer.Text)
+	}
+	return n
+}
+
+// colorはSlackのアタッチメントの色をEmbedの色に変換します
+func color(c string) int {
+	switch c {
+	case "danger":
+		return 0xE01E5A
+	case "warning":
+		return 0xECB22E
+	case "good":
+		return 0x2EB67D
+	}
+	if v, err := strconv.ParseInt(strings.TrimPrefix(c, "#"), 16, 32); err == nil && strings.HasPrefix(c, "#") {
+		return int(v)
+	}
+	return 0
+}
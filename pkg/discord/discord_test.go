@@ -0,0 +1,189 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestNewMessage(t *testing.T) {
+	p := slack.Payload{
+		Attachments: []slack.Attachment{
+			{
+				Title:     ":rotating_light:ロググループ &lt;test&gt; にてアラートを検知しました",
+				TitleLink: "https://example.com/group",
+				Color:     "danger",
+				Fields: []slack.Field{
+					{Title: "Log Group", Value: "testLogGroup", Short: true},
+					{Title: "Log Messages", Value: slack.CodeBlock("a < b\n```done```")},
+				},
+				Actions: []slack.Action{
+					{Type: "button", Text: "Open in CloudWatch", Url: "https://example.com"},
+				},
+				Timestamp: 1716792512,
+			},
+		},
+	}
+
+	got := NewMessage(p)
+
+	want := Message{
+		AllowedMentions: &AllowedMentions{Parse: []string{}},
+		Embeds: []Embed{
+			{
+				Title:       "🚨ロググループ <test> にてアラートを検知しました",
+				URL:         "https://example.com/group",
+				Color:       0xE01E5A,
+				Fields:      []Field{{Name: "Log Group", Value: "testLogGroup", Inline: true}},
+				Description: "**Log Messages**\n```\na < b\n`\u200b`\u200b`done`\u200b`\u200b`\n```\n[Open in CloudWatch](https://example.com)",
+				Timestamp:   "2024-05-27T06:48:32Z",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", got, want)
+	}
+}
+
+func TestNewMessageLimits(t *testing.T) {
+	var fields []slack.Field
+	var longFields []slack.Field
+	for i := 0; i < 30; i++ {
+		fields = append(fields, slack.Field{Title: "key", Value: "value"})
+		longFields = append(longFields, slack.Field{Title: "key", Value: strings.Repeat("v", 2000)})
+	}
+	var attachments []slack.Attachment
+	for i := 0; i < 12; i++ {
+		attachments = append(attachments, slack.Attachment{Title: "title", Fields: longFields[:2]})
+	}
+
+	testCases := []struct {
+		name    string
+		payload slack.Payload
+		embeds  int
+		fields  int
+		content string
+	}{
+		{
+			name:    "フィールドが多すぎる場合",
+			payload: slack.Payload{Attachments: []slack.Attachment{{Title: "title", Fields: fields}}},
+			embeds:  1,
+			fields:  MaxFields,
+		},
+		{
+			name:    "1つのEmbedが長すぎる場合",
+			payload: slack.Payload{Attachments: []slack.Attachment{{Title: "title", Fields: longFields}}},
+			embeds:  1,
+			fields:  6,
+		},
+		{
+			name:    "Embedが多すぎる場合",
+			payload: slack.Payload{Attachments: attachments},
+			embeds:  2,
+			fields:  2,
+			content: "… 10 embeds omitted",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMessage(tt.payload)
+
+			if len(got.Embeds) != tt.embeds {
+				t.Fatalf("unexpected number of embeds: %d", len(got.Embeds))
+			}
+			if len(got.Embeds[0].Fields) != tt.fields {
+				t.Fatalf("unexpected number of fields: %d", len(got.Embeds[0].Fields))
+			}
+			if got.Content != tt.content {
+				t.Fatalf("unexpected content: %q", got.Content)
+			}
+
+			total := 0
+			for _, e := range got.Embeds {
+				for _, f := range e.Fields {
+					if n := utf8.RuneCountInString(f.Value); n > MaxFieldValueLength {
+						t.Fatalf("field value too long: %d", n)
+					}
+				}
+				total += embedLength(e)
+			}
+			if total > MaxEmbedsLength {
+				t.Fatalf("embeds too long: %d", total)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]wait=falseの場合", status: http.StatusNoContent, isNormal: true},
+		{name: "[正常系]wait=trueの場合", status: http.StatusOK, isNormal: true},
+		{name: "[異常系]エラーが返された場合", status: http.StatusBadRequest, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Message
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Errorf("failed to unmarshal request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			discord := Discord{URL: server.URL, Username: "cwl2slack"}
+			err := discord.Notify(context.Background(), notifier.Notification{Payload: slack.Payload{Text: "hello @everyone"}})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Username != "cwl2slack" || got.Content != "hello @everyone" || got.AllowedMentions == nil {
+					t.Fatalf("unexpected message: %+v", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestColor(t *testing.T) {
+	testCases := []struct {
+		color string
+		want  int
+	}{
+		{color: "danger", want: 0xE01E5A},
+		{color: "#36a64f", want: 0x36a64f},
+		{color: "", want: 0},
+		{color: "blue", want: 0},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.color, func(t *testing.T) {
+			if got := color(tt.color); got != tt.want {
+				t.Fatalf("got: %x, want: %x", got, tt.want)
+			}
+		})
+	}
+}
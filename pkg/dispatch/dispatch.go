@@ -0,0 +1,329 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/deadletter"
+	"github.com/tomozo6/cwl2slack/pkg/dedup"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// Policyは送信結果から全体を成功とみなすかどうかの方針です
+type Policy string
+
+const (
+	// PolicyAllは全ての送信が成功した場合に成功とします
+	PolicyAll Policy = "all"
+	// PolicyAnyは1つでも送信が成功した場合に成功とします
+	PolicyAny Policy = "any"
+	// PolicyCriticalOnlyは重要度がcriticalの通知が全て送信できた場合に成功とします
+	PolicyCriticalOnly Policy = "critical-only"
+)
+
+// ParsePolicyは文字列からPolicyを返します。空の場合はPolicyAllです
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case "":
+		return PolicyAll, nil
+	case PolicyAll, PolicyAny, PolicyCriticalOnly:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid policy: %s", s)
+	}
+}
+
+// Destinationは送信先と、その送信先に送信する通知です
+type Destination struct {
+	Name          string
+	Notifier      notifier.Notifier
+	Notifications []notifier.Notification
+}
+
+// Resultは1つの通知を1つの送信先に送信した結果です。IndexはDestinationのNotificationsでの位置です
+type Result struct {
+	Destination  string
+	Index        int
+	Notification notifier.Notification
+	Err          error
+
+	// Skippedは送信済みの記録があり、送信しなかったことを表します
+	Skipped bool
+	// StoreErrは送信済みの記録の確認や保存に失敗した場合のエラーです。送信の成否には影響しません
+	StoreErr error
+
+	// DeadLetteredは送信に失敗した通知をデッドレターに保存したことを表します
+	DeadLettered bool
+	// DeadLetterErrはデッドレターへの保存に失敗した場合のエラーです
+	DeadLetterErr error
+}
+
+func (r Result) String() string {
+	s := fmt.Sprintf("%s[%d]", r.Destination, r.Index)
+	if title := title(r.Notification); title != "" {
+		s += fmt.Sprintf(" %q", title)
+	}
+	if r.Skipped {
+		s += ": already delivered"
+	}
+	if r.Err != nil {
+		s += ": " + r.Err.Error()
+	}
+	if r.StoreErr != nil {
+		s += ": dedup store: " + r.StoreErr.Error()
+	}
+	if r.DeadLettered {
+		s += " (dead-lettered)"
+	}
+	if r.DeadLetterErr != nil {
+		s += ": dead-letter: " + r.DeadLetterErr.Error()
+	}
+	return s
+}
+
+// Reportは全ての送信結果です
+type Report struct {
+	Results []Result
+}
+
+// Failedは失敗した送信結果を返します
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Errは方針に従って全体が失敗の場合に*Errorを返します。送信する通知が無い場合は成功です。
+// デッドレターに保存した通知は後で再送できるため、失敗に含めません
+func (r *Report) Err(p Policy) error {
+	var failed []Result
+	for _, res := range r.Failed() {
+		if !res.DeadLettered {
+			failed = append(failed, res)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	switch p {
+	case PolicyAny:
+		if len(failed) < len(r.Results) {
+			return nil
+		}
+	case PolicyCriticalOnly:
+		critical := false
+		for _, res := range failed {
+			critical = critical || res.Notification.Severity == notifier.SeverityCritical
+		}
+		if !critical {
+			return nil
+		}
+	}
+	return &Error{Failed: failed, Total: len(r.Results)}
+}
+
+// Errorは送信に失敗した通知の一覧です
+type Error struct {
+	Failed []Result
+	Total  int
+}
+
+func (e *Error) Error() string {
+	s := make([]string, len(e.Failed))
+	for i, res := range e.Failed {
+		s[i] = res.String()
+	}
+	return fmt.Sprintf("%d of %d notifications failed: %s", len(e.Failed), e.Total, strings.Join(s, "; "))
+}
+
+// Dispatchは全ての送信先に全ての通知を送信し、結果を返します。
+// 送信済みの記録は行いません
+func Dispatch(ctx context.Context, destinations []Destination) *Report {
+	return (&Dispatcher{}).Dispatch(ctx, destinations)
+}
+
+// Dispatcherは送信済みの通知を記録しながら通知を送信します
+type Dispatcher struct {
+	// 送信済みの通知を記録するストア。nilの場合は記録せずに全て送信します
+	Store dedup.Store
+	// 送信済みの記録を保持する期間。0の場合はdedup.DefaultTTLです
+	TTL time.Duration
+
+	// 送信に失敗した通知を保存するデッドレター。nilの場合は保存しません
+	DeadLetter deadletter.Sink
+	// デッドレターに通知と一緒に保存する、通知の元になったデータ
+	Data *events.CloudwatchLogsData
+
+	// 並行して送信する送信先の数。0の場合はDefaultWorkersです
+	Workers int
+}
+
+// DefaultWorkersは並行して送信する送信先の既定の数です
+const DefaultWorkers = 4
+
+// Dispatchは全ての送信先に全ての通知を送信し、結果を返します。
+// 送信先ごとに並行して送信し、同じ送信先には通知の元になったログイベントの時刻の順に1件ずつ送信します。
+// 途中で失敗しても残りの通知の送信を続けます。まとめて送信できる送信先には1回で送信し、その結果を各通知の結果とします。
+// 再送されたログイベントで送信済みの通知は送信せず、送信に成功した通知のみ送信済みとして記録します
+func (d *Dispatcher) Dispatch(ctx context.Context, destinations []Destination) *Report {
+	workers := d.Workers
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	results := make([][]Result, len(destinations))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, dest := range destinations {
+		if len(dest.Notifications) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, dest Destination) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = d.deliver(ctx, dest)
+		}(i, dest)
+	}
+	wg.Wait()
+
+	// 結果は送信先の順に並べる
+	report := &Report{}
+	for _, r := range results {
+		report.Results = append(report.Results, r...)
+	}
+	return report
+}
+
+// deliverは1つの送信先に通知を送信し、通知の順の結果を返します
+func (d *Dispatcher) deliver(ctx context.Context, dest Destination) []Result {
+	// 送信済みの通知を除き、ログイベントの時刻の順に並べる
+	results := make([]Result, len(dest.Notifications))
+	var pending []int
+	for i, n := range dest.Notifications {
+		results[i] = Result{Destination: dest.Name, Index: i, Notification: n}
+		results[i].Skipped, results[i].StoreErr = d.seen(ctx, dest.Name, n)
+		if !results[i].Skipped {
+			pending = append(pending, i)
+		}
+	}
+	sort.SliceStable(pending, func(a, b int) bool {
+		return dest.Notifications[pending[a]].Timestamp.Before(dest.Notifications[pending[b]].Timestamp)
+	})
+
+	ns := make([]notifier.Notification, len(pending))
+	for j, i := range pending {
+		ns[j] = dest.Notifications[i]
+	}
+	for j, err := range send(ctx, dest.Notifier, ns) {
+		results[pending[j]].Err = err
+	}
+
+	for _, i := range pending {
+		if results[i].Err != nil {
+			results[i].DeadLettered, results[i].DeadLetterErr = d.deadLetter(ctx, results[i])
+			continue
+		}
+		if err := d.mark(ctx, dest.Name, dest.Notifications[i]); err != nil {
+			results[i].StoreErr = err
+		}
+	}
+	return results
+}
+
+// sendは通知を送信し、通知ごとの結果を返します。
+// まとめて送信するNotifierの場合は1回で送信し、その結果を全ての通知の結果とします
+func send(ctx context.Context, n notifier.Notifier, ns []notifier.Notification) []error {
+	errs := make([]error, len(ns))
+	if len(ns) == 0 {
+		return errs
+	}
+	// Lambdaの期限を過ぎた場合などは送信しない
+	if err := ctx.Err(); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	switch n := n.(type) {
+	case notifier.BatchNotifier:
+		err := n.NotifyBatch(ctx, ns)
+		for i := range errs {
+			errs[i] = err
+		}
+	case notifier.SequenceNotifier:
+		copy(errs, n.NotifyEach(ctx, ns))
+	default:
+		for i := range ns {
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				continue
+			}
+			errs[i] = n.Notify(ctx, ns[i])
+		}
+	}
+	return errs
+}
+
+// seenは通知が送信先に送信済みかどうかを返します。確認できない場合は送信済みでないとみなします
+func (d *Dispatcher) seen(ctx context.Context, destination string, n notifier.Notification) (bool, error) {
+	if d.Store == nil {
+		return false, nil
+	}
+	seen, err := d.Store.Seen(ctx, dedup.Key(destination, n))
+	if err != nil {
+		return false, err
+	}
+	return seen, nil
+}
+
+// markは通知を送信先に送信済みとして記録します
+func (d *Dispatcher) mark(ctx context.Context, destination string, n notifier.Notification) error {
+	if d.Store == nil {
+		return nil
+	}
+	ttl := d.TTL
+	if ttl == 0 {
+		ttl = dedup.DefaultTTL
+	}
+	return d.Store.Mark(ctx, dedup.Key(destination, n), ttl)
+}
+
+// deadLetterは送信に失敗した通知をデッドレターに保存します
+func (d *Dispatcher) deadLetter(ctx context.Context, res Result) (bool, error) {
+	if d.DeadLetter == nil {
+		return false, nil
+	}
+	r := deadletter.NewRecord(res.Destination, res.Notification, d.Data, res.Err, time.Now())
+	if err := d.DeadLetter.Put(ctx, r); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// maxTitleLengthは送信結果に表示するタイトルの最大の文字数です
+const maxTitleLength = 80
+
+// titleは送信結果に表示する通知のタイトルを返します
+func title(n notifier.Notification) string {
+	for _, a := range n.Payload.Attachments {
+		if a.Title != "" {
+			return slack.Truncate(notifier.Text(a.Title), maxTitleLength)
+		}
+	}
+	return slack.Truncate(notifier.Text(n.Payload.Text), maxTitleLength)
+}
@@ -0,0 +1,390 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/tomozo6/cwl2slack/pkg/deadletter"
+	"github.com/tomozo6/cwl2slack/pkg/dedup"
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// fakeNotifierは指定した番号の通知の送信に失敗するNotifierです
+type fakeNotifier struct {
+	fail map[string]bool
+	sent []string
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n notifier.Notification) error {
+	if f.fail[n.Payload.Text] {
+		return errors.New("boom")
+	}
+	f.sent = append(f.sent, n.Payload.Text)
+	return nil
+}
+
+// fakeBatchNotifierはまとめて送信するNotifierです
+type fakeBatchNotifier struct {
+	fakeNotifier
+	batches int
+	err     error
+}
+
+func (f *fakeBatchNotifier) NotifyBatch(ctx context.Context, ns []notifier.Notification) error {
+	f.batches++
+	return f.err
+}
+
+func notifications(n int, critical ...int) []notifier.Notification {
+	ns := make([]notifier.Notification, n)
+	for i := range ns {
+		ns[i] = notifier.Notification{Payload: slack.Payload{Text: fmt.Sprintf("message%d", i+1)}, Severity: notifier.SeverityError}
+	}
+	for _, i := range critical {
+		ns[i].Severity = notifier.SeverityCritical
+	}
+	return ns
+}
+
+func TestDispatch(t *testing.T) {
+	slackNotifier := &fakeNotifier{fail: map[string]bool{"message3": true}}
+	teamsNotifier := &fakeNotifier{}
+	emailNotifier := &fakeBatchNotifier{err: errors.New("smtp down")}
+
+	report := Dispatch(context.Background(), []Destination{
+		{Name: "slack", Notifier: slackNotifier, Notifications: notifications(10)},
+		{Name: "teams", Notifier: teamsNotifier, Notifications: notifications(2)},
+		{Name: "email", Notifier: emailNotifier, Notifications: notifications(3)},
+		{Name: "empty", Notifier: &fakeNotifier{}},
+	})
+
+	// 3番目の送信に失敗しても4〜10番目は送信されます
+	if len(slackNotifier.sent) != 9 || slackNotifier.sent[8] != "message10" {
+		t.Fatalf("unexpected sent messages: %v", slackNotifier.sent)
+	}
+	if emailNotifier.batches != 1 {
+		t.Fatalf("unexpected number of batches: %d", emailNotifier.batches)
+	}
+	if len(report.Results) != 15 {
+		t.Fatalf("unexpected number of results: %d", len(report.Results))
+	}
+
+	var failed []string
+	for _, res := range report.Failed() {
+		failed = append(failed, fmt.Sprintf("%s[%d]", res.Destination, res.Index))
+	}
+	want := "slack[2] email[0] email[1] email[2]"
+	if got := strings.Join(failed, " "); got != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+}
+
+func TestReportErr(t *testing.T) {
+	ok := func(n notifier.Notification) Result { return Result{Destination: "slack", Notification: n} }
+	ng := func(n notifier.Notification) Result {
+		return Result{Destination: "slack", Index: 1, Notification: n, Err: errors.New("boom")}
+	}
+	dl := func(r Result) Result {
+		r.DeadLettered = true
+		return r
+	}
+	ns := notifications(2, 1)
+
+	testCases := []struct {
+		name     string
+		results  []Result
+		policy   Policy
+		isNormal bool
+	}{
+		{name: "[all]全て成功", results: []Result{ok(ns[0]), ok(ns[1])}, policy: PolicyAll, isNormal: true},
+		{name: "[all]1つ失敗", results: []Result{ok(ns[0]), ng(ns[0])}, policy: PolicyAll, isNormal: false},
+		{name: "[any]1つ成功", results: []Result{ok(ns[0]), ng(ns[1])}, policy: PolicyAny, isNormal: true},
+		{name: "[any]全て失敗", results: []Result{ng(ns[0]), ng(ns[1])}, policy: PolicyAny, isNormal: false},
+		{name: "[critical-only]criticalでない通知の失敗", results: []Result{ng(ns[0])}, policy: PolicyCriticalOnly, isNormal: true},
+		{name: "[critical-only]criticalの通知の失敗", results: []Result{ok(ns[0]), ng(ns[1])}, policy: PolicyCriticalOnly, isNormal: false},
+		{name: "[all]デッドレターに保存した失敗", results: []Result{ok(ns[0]), dl(ng(ns[1]))}, policy: PolicyAll, isNormal: true},
+		{name: "[all]デッドレターに保存できなかった失敗", results: []Result{dl(ng(ns[0])), ng(ns[1])}, policy: PolicyAll, isNormal: false},
+		{name: "送信する通知が無い場合", policy: PolicyAny, isNormal: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Report{Results: tt.results}).Err(tt.policy)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// 異常系のテストケース
+			} else {
+				var e *Error
+				if !errors.As(err, &e) || len(e.Failed) == 0 {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{
+		Total: 3,
+		Failed: []Result{
+			{Destination: "slack", Index: 2, Notification: notifier.Notification{Payload: slack.Payload{Attachments: []slack.Attachment{{Title: ":boom:Java &lt;Error&gt;"}}}}, Err: errors.New("received non-2xx response: 500")},
+		},
+	}
+
+	want := `1 of 3 notifications failed: slack[2] "💥Java <Error>": received non-2xx response: 500`
+	if got := err.Error(); got != want {
+		t.Fatalf("\n got: %s;\nwant: %s", got, want)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		str      string
+		isNormal bool
+		want     Policy
+	}{
+		{name: "[正常系]未指定", str: "", isNormal: true, want: PolicyAll},
+		{name: "[正常系]critical-only", str: "critical-only", isNormal: true, want: PolicyCriticalOnly},
+		{name: "[異常系]不正な値", str: "most", isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicy(tt.str)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Fatalf("got: %s, want: %s", got, tt.want)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+// failingStoreは記録の確認と保存に失敗するStoreです
+type failingStore struct{}
+
+func (failingStore) Seen(ctx context.Context, key string) (bool, error) {
+	return false, errors.New("store down")
+}
+
+func (failingStore) Mark(ctx context.Context, key string, ttl time.Duration) error {
+	return errors.New("store down")
+}
+
+func TestDispatcherSkipsDelivered(t *testing.T) {
+	d := &Dispatcher{Store: dedup.NewMemoryStore()}
+	ns := notifications(5)
+	for i := range ns {
+		ns[i].EventIDs = []string{fmt.Sprintf("id%d", i+1)}
+	}
+
+	// 1回目はmessage3の送信に失敗する
+	first := &fakeNotifier{fail: map[string]bool{"message3": true}}
+	report := d.Dispatch(context.Background(), []Destination{{Name: "slack", Notifier: first, Notifications: ns}})
+	if len(report.Failed()) != 1 || len(first.sent) != 4 {
+		t.Fatalf("unexpected first delivery: %v, sent: %v", report.Results, first.sent)
+	}
+
+	// 再送時は失敗したmessage3のみ送信する
+	retry := &fakeNotifier{}
+	report = d.Dispatch(context.Background(), []Destination{{Name: "slack", Notifier: retry, Notifications: ns}})
+	if report.Err(PolicyAll) != nil {
+		t.Fatalf("unexpected error: %v", report.Err(PolicyAll))
+	}
+	if strings.Join(retry.sent, ",") != "message3" {
+		t.Fatalf("unexpected retry: %v", retry.sent)
+	}
+	skipped := 0
+	for _, r := range report.Results {
+		if r.Skipped {
+			skipped++
+		}
+	}
+	if skipped != 4 {
+		t.Fatalf("skipped: %d", skipped)
+	}
+
+	// 別の送信先には送信済みの記録が無いので全て送信する
+	other := &fakeNotifier{}
+	d.Dispatch(context.Background(), []Destination{{Name: "teams", Notifier: other, Notifications: ns}})
+	if len(other.sent) != 5 {
+		t.Fatalf("unexpected other delivery: %v", other.sent)
+	}
+}
+
+func TestDispatcherBatch(t *testing.T) {
+	d := &Dispatcher{Store: dedup.NewMemoryStore()}
+	ns := notifications(3)
+
+	email := &fakeBatchNotifier{}
+	d.Dispatch(context.Background(), []Destination{{Name: "email", Notifier: email, Notifications: ns}})
+	d.Dispatch(context.Background(), []Destination{{Name: "email", Notifier: email, Notifications: ns}})
+	if email.batches != 1 {
+		t.Fatalf("batches: %d", email.batches)
+	}
+}
+
+func TestDispatcherStoreError(t *testing.T) {
+	d := &Dispatcher{Store: failingStore{}}
+
+	n := &fakeNotifier{}
+	report := d.Dispatch(context.Background(), []Destination{{Name: "slack", Notifier: n, Notifications: notifications(2)}})
+
+	// ストアが使えなくても送信は行い、成功とする
+	if report.Err(PolicyAll) != nil || len(n.sent) != 2 {
+		t.Fatalf("unexpected result: %v", report.Results)
+	}
+	for _, r := range report.Results {
+		if r.StoreErr == nil || !strings.Contains(r.String(), "dedup store: store down") {
+			t.Fatalf("unexpected result: %s", r)
+		}
+	}
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	dir := &deadletter.Dir{Path: t.TempDir()}
+	data := &events.CloudwatchLogsData{LogGroup: "/aws/test"}
+	d := &Dispatcher{DeadLetter: dir, Data: data}
+
+	n := &fakeNotifier{fail: map[string]bool{"message2": true}}
+	report := d.Dispatch(context.Background(), []Destination{{Name: "slack", Notifier: n, Notifications: notifications(3)}})
+
+	// デッドレターに保存した通知は失敗として扱わない
+	if err := report.Err(PolicyAll); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || !failed[0].DeadLettered {
+		t.Fatalf("unexpected failed: %v", failed)
+	}
+
+	messages, err := dir.List(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	r := messages[0].Record
+	if r.Destination != "slack" || r.Notification.Payload.Text != "message2" || r.Error != "boom" || r.Data.LogGroup != "/aws/test" {
+		t.Fatalf("unexpected record: %+v", r)
+	}
+}
+
+// fakeSequenceNotifierは通知をまとめて受け取り、通知ごとの結果を返すNotifierです
+type fakeSequenceNotifier struct {
+	fakeNotifier
+	calls int
+}
+
+func (f *fakeSequenceNotifier) NotifyEach(ctx context.Context, ns []notifier.Notification) []error {
+	f.calls++
+	errs := make([]error, len(ns))
+	for i, n := range ns {
+		errs[i] = f.Notify(ctx, n)
+	}
+	return errs
+}
+
+func TestDispatchSequence(t *testing.T) {
+	n := &fakeSequenceNotifier{fakeNotifier: fakeNotifier{fail: map[string]bool{"message2": true}}}
+	report := Dispatch(context.Background(), []Destination{{Name: "slack", Notifier: n, Notifications: notifications(3)}})
+
+	failed := report.Failed()
+	if n.calls != 1 || len(failed) != 1 || failed[0].Index != 1 {
+		t.Fatalf("unexpected result: calls: %d, failed: %v", n.calls, failed)
+	}
+}
+
+// slowNotifierは送信に時間がかかるNotifierです。並行して送信している数の最大値を記録します
+type slowNotifier struct {
+	mu       sync.Mutex
+	inflight *int32
+	max      *int32
+	sent     []string
+}
+
+func (s *slowNotifier) Notify(ctx context.Context, n notifier.Notification) error {
+	cur := atomic.AddInt32(s.inflight, 1)
+	defer atomic.AddInt32(s.inflight, -1)
+	for {
+		m := atomic.LoadInt32(s.max)
+		if cur <= m || atomic.CompareAndSwapInt32(s.max, m, cur) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n.Payload.Text)
+	return nil
+}
+
+func TestDispatcherWorkers(t *testing.T) {
+	var inflight, max int32
+	var destinations []Destination
+	var notifiers []*slowNotifier
+	for i := 0; i < 6; i++ {
+		n := &slowNotifier{inflight: &inflight, max: &max}
+		notifiers = append(notifiers, n)
+
+		// ログイベントの時刻が新しい順に並べる
+		ns := notifications(3)
+		for j := range ns {
+			ns[j].Timestamp = time.Unix(int64(100-j), 0)
+		}
+		destinations = append(destinations, Destination{Name: fmt.Sprintf("dest%d", i), Notifier: n, Notifications: ns})
+	}
+
+	report := (&Dispatcher{Workers: 2}).Dispatch(context.Background(), destinations)
+
+	if max != 2 {
+		t.Fatalf("max concurrency: %d", max)
+	}
+	// 送信先ごとにログイベントの時刻の順に送信する
+	for _, n := range notifiers {
+		if got := strings.Join(n.sent, ","); got != "message3,message2,message1" {
+			t.Fatalf("unexpected order: %s", got)
+		}
+	}
+	// 結果は送信先と通知の順に並ぶ
+	if len(report.Results) != 18 || report.Results[0].Destination != "dest0" || report.Results[17].Destination != "dest5" || report.Results[17].Index != 2 {
+		t.Fatalf("unexpected results: %v", report.Results)
+	}
+}
+
+func TestDispatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	n := &fakeNotifier{}
+	report := Dispatch(ctx, []Destination{{Name: "slack", Notifier: n, Notifications: notifications(2)}})
+
+	if len(n.sent) != 0 || len(report.Failed()) != 2 || !errors.Is(report.Failed()[0].Err, context.Canceled) {
+		t.Fatalf("unexpected result: %v", report.Results)
+	}
+}
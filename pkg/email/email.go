@@ -0,0 +1,301 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+)
+
+// EmailはSMTPサーバー経由で通知をメールで送信します。
+// STARTTLSが必須で、サーバーが対応していない場合はエラーになります。
+// ローカルのリレーなどで暗号化しない場合のみInsecureをtrueにします
+type Email struct {
+	// SMTPサーバーのアドレス(例: smtp.example.com:587)と認証情報。Usernameが空の場合は認証しません
+	Addr     string
+	Username string
+	Password string
+
+	From string
+	To   []string
+
+	// STARTTLSの設定。nilの場合はAddrのホスト名を検証します
+	TLSConfig *tls.Config
+	Insecure  bool
+}
+
+// Notifyは1件の通知をメールで送信します
+func (e *Email) Notify(ctx context.Context, n notifier.Notification) error {
+	return e.NotifyBatch(ctx, []notifier.Notification{n})
+}
+
+// NotifyBatchは複数の通知を1通のメールにまとめて送信します
+func (e *Email) NotifyBatch(ctx context.Context, ns []notifier.Notification) error {
+	if len(ns) == 0 {
+		return nil
+	}
+	if len(e.To) == 0 {
+		return fmt.Errorf("no email recipients")
+	}
+
+	msg, err := e.Message(ns, time.Now())
+	if err != nil {
+		return err
+	}
+	return e.send(ctx, msg)
+}
+
+// sendはSMTPのセッションを開始し、メッセージを送信します
+func (e *Email) send(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address: %w", err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		config := e.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: host}
+		}
+		if err := c.StartTLS(config); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	} else if !e.Insecure {
+		return fmt.Errorf("SMTP server does not support STARTTLS")
+	}
+
+	if e.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := c.Mail(e.From); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range e.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to set recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// Messageは通知をtext/plainとtext/htmlのmultipart/alternativeのメールにします
+func (e *Email) Message(ns []notifier.Notification, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	var plain []string
+	for _, n := range ns {
+		plain = append(plain, notifier.PlainText(n.Payload))
+	}
+	if err := writePart(mw, "text/plain; charset=UTF-8", []byte(strings.Join(plain, "\n\n----\n\n"))); err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	if err := htmlTemplate.Execute(&html, newSections(ns)); err != nil {
+		return nil, fmt.Errorf("failed to render HTML email: %w", err)
+	}
+	if err := writePart(mw, "text/html; charset=UTF-8", html.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	header := []struct{ key, value string }{
+		{"From", e.From},
+		{"To", strings.Join(e.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("UTF-8", subject(ns))},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	for _, h := range header {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h.key, h.value)
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// writePartはquoted-printableでエンコードしたパートを追加します
+func writePart(mw *multipart.Writer, contentType string, body []byte) error {
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	qw := quotedprintable.NewWriter(pw)
+	if _, err := qw.Write(body); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// subjectはメールの件名として最初の通知のタイトルと、他の通知の件数を返します
+func subject(ns []notifier.Notification) string {
+	s := "cwl2slack notification"
+	for _, a := range ns[0].Payload.Attachments {
+		if a.Title != "" {
+			s = notifier.Text(a.Title)
+			break
+		}
+	}
+	if len(ns) > 1 {
+		s += fmt.Sprintf(" (+%d)", len(ns)-1)
+	}
+	return "[cwl2slack] " + s
+}
+
+// sectionはHTMLのメールに表示する1つのアタッチメントです
+type section struct {
+	Title    string
+	Color    string
+	Severity string
+	Fields   []field
+	Codes    []field
+	Text     string
+	Links    []link
+}
+
+type field struct {
+	Title string
+	Value string
+}
+
+type link struct {
+	Text string
+	URL  string
+}
+
+// newSectionsは通知をHTMLのメールに表示する内容に変換します
+func newSections(ns []notifier.Notification) []section {
+	var sections []section
+	for _, n := range ns {
+		for _, a := range n.Payload.Attachments {
+			s := section{Title: notifier.Text(a.Title), Color: color(a.Color), Severity: string(n.Severity)}
+			for _, f := range a.Fields {
+				if code, ok := notifier.Code(f.Value); ok {
+					s.Codes = append(s.Codes, field{Title: notifier.Text(f.Title), Value: code})
+					continue
+				}
+				s.Fields = append(s.Fields, field{Title: notifier.Text(f.Title), Value: notifier.Text(f.Value)})
+			}
+			if code, ok := notifier.Code(a.Text); ok {
+				s.Codes = append(s.Codes, field{Value: code})
+			} else {
+				s.Text = notifier.Text(a.Text)
+			}
+			for _, act := range a.Actions {
+				if act.Url != "" {
+					s.Links = append(s.Links, link{Text: act.Text, URL: act.Url})
+				}
+			}
+			sections = append(sections, s)
+		}
+		if len(n.Payload.Attachments) == 0 {
+			sections = append(sections, section{Text: notifier.Text(n.Payload.Text), Severity: string(n.Severity), Color: color("")})
+		}
+	}
+	return sections
+}
+
+// colorはSlackのアタッチメントの色をHTMLの色に変換します
+func color(c string) string {
+	switch c {
+	case "danger":
+		return "#E01E5A"
+	case "warning":
+		return "#ECB22E"
+	case "good":
+		return "#2EB67D"
+	}
+	if strings.HasPrefix(c, "#") {
+		return c
+	}
+	return "#DDDDDD"
+}
+
+// htmlTemplateはHTMLのメールの本文です。メールクライアントで表示が崩れないように、スタイルはインラインで指定します
+var htmlTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; font-size: 14px; color: #1D1C1D;">
+{{- range .}}
+<div style="border-left: 4px solid {{.Color}}; padding: 4px 12px; margin: 12px 0;">
+{{- if .Title}}
+<h2 style="font-size: 16px; margin: 4px 0 8px;">{{.Title}}</h2>
+{{- end}}
+{{- if .Severity}}
+<p style="margin: 0 0 8px; color: #616061;">Severity: {{.Severity}}</p>
+{{- end}}
+{{- if .Fields}}
+<table style="border-collapse: collapse; margin-bottom: 8px;">
+{{- range .Fields}}
+<tr><th style="text-align: left; vertical-align: top; padding: 4px 12px 4px 0; white-space: nowrap;">{{.Title}}</th><td style="padding: 4px 0;">{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- range .Codes}}
+{{- if .Title}}
+<p style="margin: 8px 0 4px; font-weight: bold;">{{.Title}}</p>
+{{- end}}
+<pre style="font-family: monospace; font-size: 12px; background: #F8F8F8; border: 1px solid #DDDDDD; padding: 8px; white-space: pre-wrap; word-break: break-all;">{{.Value}}</pre>
+{{- end}}
+{{- if .Text}}
+<p style="white-space: pre-wrap;">{{.Text}}</p>
+{{- end}}
+{{- if .Links}}
+<p>{{range $i, $l := .Links}}{{if $i}} | {{end}}<a href="{{$l.URL}}">{{$l.Text}}</a>{{end}}</p>
+{{- end}}
+</div>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,279 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// fakeSMTPServerはテスト用の最小限のSMTPサーバーです。1つのセッションで受け取った内容を記録します
+type fakeSMTPServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	tls      bool
+	auth     string
+	from     string
+	to       []string
+	data     string
+	received chan struct{}
+}
+
+// newFakeSMTPServerはfakeSMTPServerを起動します。tlsConfigがnilの場合はSTARTTLSに対応しません
+func newFakeSMTPServer(t *testing.T, tlsConfig *tls.Config) *fakeSMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeSMTPServer{ln: ln, tlsConfig: tlsConfig, received: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	defer close(s.received)
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+
+		s.mu.Lock()
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			if s.tlsConfig != nil && !s.tls {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250-STARTTLS")
+			} else {
+				tp.PrintfLine("250-localhost")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				s.mu.Unlock()
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			s.tls = true
+		case "AUTH":
+			b, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			s.auth = string(b)
+			tp.PrintfLine("235 Authentication successful")
+		case "MAIL":
+			s.from = arg
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.to = append(s.to, arg)
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			b, _ := tp.ReadDotBytes()
+			s.data = string(b)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			s.mu.Unlock()
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+		s.mu.Unlock()
+	}
+}
+
+var testNotifications = []notifier.Notification{
+	{
+		Payload: slack.Payload{
+			Attachments: []slack.Attachment{
+				{
+					Title: ":rotating_light:ロググループ &lt;security&gt; にてアラートを検知しました",
+					Color: "danger",
+					Fields: []slack.Field{
+						{Title: "Log Group", Value: "/aws/security"},
+						{Title: "Log Messages", Value: slack.CodeBlock("<script>alert(1)</script>")},
+					},
+					Actions: []slack.Action{{Text: "Open in CloudWatch", Url: "https://example.com/log"}},
+				},
+			},
+		},
+		Severity: notifier.SeverityCritical,
+	},
+	{
+		Payload: slack.Payload{
+			Attachments: []slack.Attachment{{Title: "second alert", Fields: []slack.Field{{Title: "User", Value: "admin"}}}},
+		},
+	},
+}
+
+// readMessageはメールを解析し、件名とtext/plain、text/htmlのパートを返します
+func readMessage(t *testing.T, data string) (subject string, parts map[string]string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("failed to decode subject: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type: %s", msg.Header.Get("Content-Type"))
+	}
+
+	parts = map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		b, _ := io.ReadAll(p)
+		contentType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts[contentType] = string(b)
+	}
+	return subject, parts
+}
+
+func TestMessage(t *testing.T) {
+	e := Email{From: "cwl2slack@example.com", To: []string{"a@example.com", "b@example.com"}}
+
+	msg, err := e.Message(testNotifications, time.Date(2024, 5, 27, 6, 53, 33, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	subject, parts := readMessage(t, string(msg))
+	if subject != "[cwl2slack] 🚨ロググループ <security> にてアラートを検知しました (+1)" {
+		t.Fatalf("unexpected subject: %s", subject)
+	}
+
+	plain := parts["text/plain"]
+	for _, want := range []string{"Log Group: /aws/security", "<script>alert(1)</script>", "second alert", "User: admin"} {
+		if !strings.Contains(plain, want) {
+			t.Fatalf("text/plain does not contain %q:\n%s", want, plain)
+		}
+	}
+
+	html := parts["text/html"]
+	for _, want := range []string{
+		"<th style=\"text-align: left; vertical-align: top; padding: 4px 12px 4px 0; white-space: nowrap;\">Log Group</th>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;</pre>",
+		"<a href=\"https://example.com/log\">Open in CloudWatch</a>",
+		"border-left: 4px solid #E01E5A",
+		"second alert",
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("text/html does not contain %q:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Fatalf("text/html contains unescaped log text:\n%s", html)
+	}
+}
+
+func TestNotifyBatch(t *testing.T) {
+	// httptestの証明書をSMTPサーバーのSTARTTLSに使います
+	ts := httptest.NewTLSServer(nil)
+	defer ts.Close()
+	serverTLS := &tls.Config{Certificates: ts.TLS.Certificates}
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	testCases := []struct {
+		name      string
+		serverTLS *tls.Config
+		insecure  bool
+		username  string
+		isNormal  bool
+	}{
+		{name: "[正常系]STARTTLSと認証", serverTLS: serverTLS, username: "user", isNormal: true},
+		{name: "[正常系]STARTTLSに対応していないサーバーにInsecureで送信", insecure: true, isNormal: true},
+		{name: "[異常系]STARTTLSに対応していないサーバー", isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeSMTPServer(t, tt.serverTLS)
+
+			e := Email{
+				Addr:      s.ln.Addr().String(),
+				Username:  tt.username,
+				Password:  "password",
+				From:      "cwl2slack@example.com",
+				To:        []string{"a@example.com", "b@example.com"},
+				TLSConfig: &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"},
+				Insecure:  tt.insecure,
+			}
+			err := e.NotifyBatch(context.Background(), testNotifications)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				<-s.received
+				s.mu.Lock()
+				defer s.mu.Unlock()
+
+				if tt.serverTLS != nil && !s.tls {
+					t.Fatalf("STARTTLS was not used")
+				}
+				if tt.username != "" && s.auth != "\x00user\x00password" {
+					t.Fatalf("unexpected auth: %q", s.auth)
+				}
+				if s.from != "FROM:<cwl2slack@example.com>" || len(s.to) != 2 || s.to[1] != "TO:<b@example.com>" {
+					t.Fatalf("unexpected envelope: %s %v", s.from, s.to)
+				}
+				subject, _ := readMessage(t, s.data)
+				if !strings.HasSuffix(subject, "(+1)") {
+					t.Fatalf("notifications were not batched: %s", subject)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyBatchNoRecipients(t *testing.T) {
+	e := Email{Addr: "127.0.0.1:25", From: "cwl2slack@example.com"}
+	if err := e.NotifyBatch(context.Background(), testNotifications); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+}
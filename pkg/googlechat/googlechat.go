@@ -0,0 +1,188 @@
+package googlechat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// Google Chatのメッセージの上限です
+// https://developers.google.com/workspace/chat/format-messages
+const (
+	MaxMessageBytes    = 32000
+	MaxWidgets         = 100
+	MaxParagraphLength = 4000
+	MaxButtons         = 25
+	minParagraphLength = 100
+)
+
+// MessageはGoogle ChatのIncoming Webhookに送信するメッセージです
+// https://developers.google.com/workspace/chat/api/reference/rest/v1/spaces.messages
+type Message struct {
+	Text    string   `json:"text,omitempty"`
+	CardsV2 []CardV2 `json:"cardsV2,omitempty"`
+}
+
+type CardV2 struct {
+	CardID string `json:"cardId"`
+	Card   Card   `json:"card"`
+}
+
+// Cardはカードの本体です
+// https://developers.google.com/workspace/chat/api/reference/rest/v1/cards
+type Card struct {
+	Header   *CardHeader `json:"header,omitempty"`
+	Sections []Section   `json:"sections"`
+}
+
+type CardHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type Section struct {
+	Header  string   `json:"header,omitempty"`
+	Widgets []Widget `json:"widgets"`
+}
+
+// Widgetはカードの要素です。いずれか1つの項目のみを設定します
+type Widget struct {
+	DecoratedText *DecoratedText `json:"decoratedText,omitempty"`
+	TextParagraph *TextParagraph `json:"textParagraph,omitempty"`
+	ButtonList    *ButtonList    `json:"buttonList,omitempty"`
+}
+
+type DecoratedText struct {
+	TopLabel string `json:"topLabel,omitempty"`
+	Text     string `json:"text"`
+	WrapText bool   `json:"wrapText,omitempty"`
+}
+
+type TextParagraph struct {
+	Text string `json:"text"`
+}
+
+type ButtonList struct {
+	Buttons []Button `json:"buttons"`
+}
+
+type Button struct {
+	Text    string  `json:"text"`
+	OnClick OnClick `json:"onClick"`
+}
+
+type OnClick struct {
+	OpenLink OpenLink `json:"openLink"`
+}
+
+type OpenLink struct {
+	URL string `json:"url"`
+}
+
+type GoogleChat struct {
+	URL string
+}
+
+// Notifyは通知をカードに変換してGoogle Chatに送信します
+func (g *GoogleChat) Notify(ctx context.Context, n notifier.Notification) error {
+	return notifier.PostJSON(ctx, g.URL, nil, NewMessage(n.Payload))
+}
+
+// NewMessageはSlackのペイロードをGoogle Chatのメッセージに変換します。
+// アタッチメントはカードになり、フィールドはdecoratedText、コードブロックは等幅のtextParagraph、
+// ボタンはbuttonListになります。メッセージが上限を超える場合はコードブロックを短く切り詰めます
+func NewMessage(p slack.Payload) Message {
+	m := newMessage(p, MaxParagraphLength)
+	for limit := MaxParagraphLength / 2; size(m) > MaxMessageBytes && limit >= minParagraphLength; limit /= 2 {
+		m = newMessage(p, limit)
+	}
+	return m
+}
+
+func newMessage(p slack.Payload, paragraphLimit int) Message {
+	m := Message{Text: notifier.Text(p.Text)}
+
+	for i, a := range p.Attachments {
+		card := Card{}
+		if a.Title != "" {
+			card.Header = &CardHeader{Title: notifier.Text(a.Title), Subtitle: notifier.Text(a.Footer)}
+		}
+
+		var widgets []Widget
+		for _, f := range a.Fields {
+			if code, ok := notifier.Code(f.Value); ok {
+				widgets = append(widgets,
+					Widget{DecoratedText: &DecoratedText{Text: "<b>" + html.EscapeString(notifier.Text(f.Title)) + "</b>"}},
+					codeParagraph(code, paragraphLimit),
+				)
+				continue
+			}
+			widgets = append(widgets, Widget{DecoratedText: &DecoratedText{
+				TopLabel: notifier.Text(f.Title),
+				Text:     paragraph(notifier.Text(f.Value), paragraphLimit),
+				WrapText: true,
+			}})
+		}
+
+		if a.Text != "" {
+			if code, ok := notifier.Code(a.Text); ok {
+				widgets = append(widgets, codeParagraph(code, paragraphLimit))
+			} else {
+				widgets = append(widgets, Widget{TextParagraph: &TextParagraph{Text: paragraph(notifier.Text(a.Text), paragraphLimit)}})
+			}
+		}
+
+		var buttons []Button
+		for _, act := range a.Actions {
+			if act.Url != "" && len(buttons) < MaxButtons {
+				buttons = append(buttons, Button{Text: act.Text, OnClick: OnClick{OpenLink: OpenLink{URL: act.Url}}})
+			}
+		}
+		if a.TitleLink != "" && len(buttons) == 0 {
+			buttons = append(buttons, Button{Text: "Open", OnClick: OnClick{OpenLink: OpenLink{URL: a.TitleLink}}})
+		}
+
+		// ボタンを残せるように、ウィジェットが多すぎる場合はボタン以外の最後のウィジェットを省略した件数の表示にします
+		limit := MaxWidgets
+		if len(buttons) > 0 {
+			limit--
+		}
+		if len(widgets) > limit {
+			omitted := len(widgets) - (limit - 1)
+			widgets = append(widgets[:limit-1], Widget{TextParagraph: &TextParagraph{Text: fmt.Sprintf("%s %d widgets omitted", slack.Ellipsis, omitted)}})
+		}
+		if len(buttons) > 0 {
+			widgets = append(widgets, Widget{ButtonList: &ButtonList{Buttons: buttons}})
+		}
+
+		card.Sections = []Section{{Widgets: widgets}}
+		m.CardsV2 = append(m.CardsV2, CardV2{CardID: fmt.Sprintf("attachment-%d", i), Card: card})
+	}
+
+	return m
+}
+
+// paragraphはテキストを切り詰めてHTMLとしてエスケープし、改行を<br>にします
+func paragraph(s string, limit int) string {
+	s = html.EscapeString(slack.Truncate(s, limit))
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// codeParagraphはコードを等幅フォントで表示するtextParagraphを返します
+func codeParagraph(code string, limit int) Widget {
+	return Widget{TextParagraph: &TextParagraph{Text: "<code>" + paragraph(code, limit) + "</code>"}}
+}
+
+// sizeはメッセージをJSONにした際のバイト数を返します
+func size(m Message) int {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
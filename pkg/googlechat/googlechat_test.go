@@ -0,0 +1,141 @@
+package googlechat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestNewMessage(t *testing.T) {
+	p := slack.Payload{
+		Attachments: []slack.Attachment{
+			{
+				Title: ":rotating_light:ロググループ &lt;test&gt; にてアラートを検知しました",
+				Color: "danger",
+				Fields: []slack.Field{
+					{Title: "Log Group", Value: "testLogGroup"},
+					{Title: "Log Messages", Value: slack.CodeBlock("a < b\nline2")},
+				},
+				Actions: []slack.Action{
+					{Type: "button", Text: "Open in CloudWatch", Url: "https://example.com"},
+				},
+			},
+		},
+	}
+
+	got := NewMessage(p).CardsV2[0].Card
+
+	want := Card{
+		Header: &CardHeader{Title: "🚨ロググループ <test> にてアラートを検知しました"},
+		Sections: []Section{
+			{
+				Widgets: []Widget{
+					{DecoratedText: &DecoratedText{TopLabel: "Log Group", Text: "testLogGroup", WrapText: true}},
+					{DecoratedText: &DecoratedText{Text: "<b>Log Messages</b>"}},
+					{TextParagraph: &TextParagraph{Text: "<code>a &lt; b<br>line2</code>"}},
+					{ButtonList: &ButtonList{Buttons: []Button{{Text: "Open in CloudWatch", OnClick: OnClick{OpenLink: OpenLink{URL: "https://example.com"}}}}}},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Fatalf("\n got: %s;\nwant: %s", gotJSON, wantJSON)
+	}
+}
+
+func TestNewMessageLimits(t *testing.T) {
+	var fields []slack.Field
+	for i := 0; i < 120; i++ {
+		fields = append(fields, slack.Field{Title: "key", Value: "value"})
+	}
+	var codes []slack.Field
+	for i := 0; i < 20; i++ {
+		codes = append(codes, slack.Field{Title: "Log Messages", Value: slack.CodeBlock(strings.Repeat("x", 3000))})
+	}
+
+	testCases := []struct {
+		name    string
+		payload slack.Payload
+		widgets int
+	}{
+		{
+			name: "ウィジェットが多すぎる場合",
+			payload: slack.Payload{Attachments: []slack.Attachment{
+				{Fields: fields, Actions: []slack.Action{{Text: "Open", Url: "https://example.com"}}},
+			}},
+			widgets: MaxWidgets,
+		},
+		{
+			name:    "メッセージが大きすぎる場合",
+			payload: slack.Payload{Attachments: []slack.Attachment{{Fields: codes}}},
+			widgets: 40,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMessage(tt.payload)
+
+			if n := size(got); n > MaxMessageBytes {
+				t.Fatalf("message too large: %d bytes", n)
+			}
+			widgets := got.CardsV2[0].Card.Sections[0].Widgets
+			if len(widgets) != tt.widgets {
+				t.Fatalf("unexpected number of widgets: %d", len(widgets))
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]送信に成功した場合", status: http.StatusOK, isNormal: true},
+		{name: "[異常系]エラーが返された場合", status: http.StatusBadRequest, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Message
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Errorf("failed to unmarshal request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			chat := GoogleChat{URL: server.URL}
+			err := chat.Notify(context.Background(), notifier.Notification{Payload: slack.Payload{Text: "hello"}})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Text != "hello" {
+					t.Fatalf("unexpected message: %+v", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
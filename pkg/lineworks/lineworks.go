@@ -0,0 +1,186 @@
+package lineworks
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// LINE WORKS API 2.0のエンドポイントです
+const (
+	DefaultURL     = "https://www.worksapis.com/v1.0"
+	DefaultAuthURL = "https://auth.worksmobile.com/oauth2/v2.0/token"
+)
+
+// MaxTextLengthはテキストメッセージの上限です
+// https://developers.worksmobile.com/jp/docs/bot-channel-message-send
+const MaxTextLength = 2000
+
+// Messageはボットが送信するメッセージです
+type Message struct {
+	Content Content `json:"content"`
+}
+
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// LineWorksはボットからトークルームにメッセージを送信します。
+// AccessTokenが空の場合は、Service Account認証(JWT)でアクセストークンを発行します
+type LineWorks struct {
+	URL       string
+	AuthURL   string
+	BotID     string
+	ChannelID string
+
+	AccessToken string
+
+	// Service Account認証に使うDeveloper Consoleのアプリの情報と秘密鍵(PEM)
+	ClientID       string
+	ClientSecret   string
+	ServiceAccount string
+	PrivateKey     string
+
+	mu sync.Mutex
+}
+
+// Notifyは通知をテキストメッセージに変換してトークルームに送信します。
+// 成功した場合、LINE WORKSは201を返します
+func (l *LineWorks) Notify(ctx context.Context, n notifier.Notification) error {
+	token, err := l.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	base := l.URL
+	if base == "" {
+		base = DefaultURL
+	}
+	endpoint := fmt.Sprintf("%s/bots/%s/channels/%s/messages", strings.TrimSuffix(base, "/"), url.PathEscape(l.BotID), url.PathEscape(l.ChannelID))
+
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	return notifier.PostJSON(ctx, endpoint, header, NewMessage(n.Payload))
+}
+
+// NewMessageはSlackのペイロードをテキストメッセージに変換します。
+// LINE WORKSのテキストメッセージには書式が無いため、「項目: 値」の行に並べます
+func NewMessage(p slack.Payload) Message {
+	return Message{Content: Content{Type: "text", Text: slack.Truncate(notifier.PlainText(p), MaxTextLength)}}
+}
+
+// tokenはアクセストークンを返します。発行したアクセストークンは同じLineWorksで使い回します
+func (l *LineWorks) token(ctx context.Context) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.AccessToken != "" {
+		return l.AccessToken, nil
+	}
+
+	assertion, err := l.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	authURL := l.AuthURL
+	if authURL == "" {
+		authURL = DefaultAuthURL
+	}
+	form := url.Values{
+		"assertion":     {assertion},
+		"grant_type":    {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"client_id":     {l.ClientID},
+		"client_secret": {l.ClientSecret},
+		"scope":         {"bot"},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", authURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token: %w", err)
+	}
+	defer res.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request access token: %w", &notifier.StatusError{StatusCode: res.StatusCode, Body: string(b)})
+	}
+
+	var t struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(b, &t); err != nil || t.AccessToken == "" {
+		return "", fmt.Errorf("invalid access token response: %s", b)
+	}
+
+	l.AccessToken = t.AccessToken
+	return l.AccessToken, nil
+}
+
+// assertionはService Account認証に使う、秘密鍵でRS256の署名をしたJWTを返します
+func (l *LineWorks) assertion(now time.Time) (string, error) {
+	key, err := parsePrivateKey(l.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": l.ClientID,
+		"sub": l.ServiceAccount,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	})
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// parsePrivateKeyはPKCS#8またはPKCS#1のPEM形式のRSA秘密鍵を読み込みます
+func parsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key: PEM not found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid private key: not RSA")
+	}
+	return key, nil
+}
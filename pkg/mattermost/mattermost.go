@@ -0,0 +1,135 @@
+package mattermost
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// Mattermostのメッセージの上限です
+// https://developers.mattermost.com/integrate/reference/message-attachments/
+const (
+	MaxTextLength       = 16383
+	MaxAttachments      = 100
+	MaxFieldValueLength = 2000
+)
+
+// PayloadはMattermostのIncoming Webhookに送信するメッセージです。
+// Slackと互換性のあるアタッチメントを使えますが、Webhookではボタンを使えません
+type Payload struct {
+	Channel     string       `json:"channel,omitempty"`
+	Username    string       `json:"username,omitempty"`
+	IconEmoji   string       `json:"icon_emoji,omitempty"`
+	Text        string       `json:"text,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+type Attachment struct {
+	Fallback  string  `json:"fallback"`
+	Color     string  `json:"color,omitempty"`
+	PreText   string  `json:"pretext,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	TitleLink string  `json:"title_link,omitempty"`
+	Text      string  `json:"text,omitempty"`
+	Fields    []Field `json:"fields,omitempty"`
+	Footer    string  `json:"footer,omitempty"`
+	Timestamp int64   `json:"ts,omitempty"`
+}
+
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type Mattermost struct {
+	URL      string
+	Channel  string
+	Username string
+}
+
+// Notifyは通知をMattermostのアタッチメントに変換して送信します
+func (m *Mattermost) Notify(ctx context.Context, n notifier.Notification) error {
+	p := NewPayload(n.Payload)
+	p.Channel = m.Channel
+	if m.Username != "" {
+		p.Username = m.Username
+	}
+	return notifier.PostJSON(ctx, m.URL, nil, p)
+}
+
+// NewPayloadはSlackのペイロードをMattermostのペイロードに変換します。
+// MattermostはSlackのエスケープ(&lt;など)を解釈しないため元のテキストに戻し、
+// ボタンはアタッチメントのテキストの末尾にMarkdownのリンクとして追加します。
+// Block Kitのみのペイロードは表示できないため、Textのみを送信します
+func NewPayload(p slack.Payload) Payload {
+	m := Payload{
+		Username:  p.Username,
+		IconEmoji: p.IconEmoji,
+		Text:      slack.Truncate(slack.Unescape(p.Text), MaxTextLength),
+	}
+
+	attachments := p.Attachments
+	if len(attachments) > MaxAttachments {
+		attachments = attachments[:MaxAttachments-1]
+	}
+	for _, a := range attachments {
+		m.Attachments = append(m.Attachments, newAttachment(a))
+	}
+	if omitted := len(p.Attachments) - len(attachments); omitted > 0 {
+		m.Attachments = append(m.Attachments, Attachment{Text: fmt.Sprintf("%s %d attachments omitted", slack.Ellipsis, omitted)})
+	}
+
+	return m
+}
+
+func newAttachment(a slack.Attachment) Attachment {
+	m := Attachment{
+		Fallback:  slack.Unescape(a.Fallback),
+		Color:     a.Color,
+		PreText:   slack.Unescape(a.PreText),
+		Title:     slack.Unescape(a.Title),
+		TitleLink: a.TitleLink,
+		Footer:    slack.Unescape(a.Footer),
+		Timestamp: a.Timestamp,
+	}
+	if m.Fallback == "" {
+		m.Fallback = m.Title
+	}
+
+	for _, f := range a.Fields {
+		m.Fields = append(m.Fields, Field{
+			Title: slack.Unescape(f.Title),
+			Value: slack.Truncate(text(f.Value), MaxFieldValueLength),
+			Short: f.Short,
+		})
+	}
+
+	var lines []string
+	if a.Text != "" {
+		lines = append(lines, text(a.Text))
+	}
+	var links []string
+	for _, act := range a.Actions {
+		if act.Url != "" {
+			links = append(links, fmt.Sprintf("[%s](%s)", act.Text, act.Url))
+		}
+	}
+	if len(links) > 0 {
+		lines = append(lines, strings.Join(links, " | "))
+	}
+	m.Text = slack.Truncate(strings.Join(lines, "\n"), MaxTextLength)
+
+	return m
+}
+
+// textはSlack向けのテキストをMattermostのMarkdownに変換します。絵文字コードはMattermostでも使えるためそのままにします
+func text(s string) string {
+	if code, ok := notifier.Code(s); ok {
+		return notifier.CodeBlock(code)
+	}
+	return slack.Unescape(s)
+}
@@ -0,0 +1,129 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestNewPayload(t *testing.T) {
+	p := slack.Payload{
+		Attachments: []slack.Attachment{
+			{
+				Title:     ":rotating_light:ロググループ &lt;test&gt; にてアラートを検知しました",
+				TitleLink: "https://example.com/group",
+				Color:     "danger",
+				Fields: []slack.Field{
+					{Title: "Log Group", Value: "testLogGroup", Short: true},
+					{Title: "Log Messages", Value: slack.CodeBlock("a < b")},
+				},
+				Actions: []slack.Action{
+					{Type: "button", Text: "Open in CloudWatch", Url: "https://example.com"},
+				},
+			},
+		},
+	}
+
+	got := NewPayload(p)
+
+	want := Payload{
+		Attachments: []Attachment{
+			{
+				Fallback:  ":rotating_light:ロググループ <test> にてアラートを検知しました",
+				Color:     "danger",
+				Title:     ":rotating_light:ロググループ <test> にてアラートを検知しました",
+				TitleLink: "https://example.com/group",
+				Text:      "[Open in CloudWatch](https://example.com)",
+				Fields: []Field{
+					{Title: "Log Group", Value: "testLogGroup", Short: true},
+					{Title: "Log Messages", Value: "```\na < b\n```"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", got, want)
+	}
+}
+
+func TestNewPayloadLimits(t *testing.T) {
+	attachments := make([]slack.Attachment, 120)
+	for i := range attachments {
+		attachments[i] = slack.Attachment{
+			Text:   strings.Repeat("x", 20000),
+			Fields: []slack.Field{{Title: "Log Messages", Value: slack.CodeBlock(strings.Repeat("y", 3000))}},
+		}
+	}
+
+	got := NewPayload(slack.Payload{Text: strings.Repeat("z", 20000), Attachments: attachments})
+
+	if n := utf8.RuneCountInString(got.Text); n > MaxTextLength {
+		t.Fatalf("text too long: %d", n)
+	}
+	if len(got.Attachments) != MaxAttachments {
+		t.Fatalf("unexpected number of attachments: %d", len(got.Attachments))
+	}
+	if last := got.Attachments[MaxAttachments-1].Text; last != "… 21 attachments omitted" {
+		t.Fatalf("unexpected last attachment: %q", last)
+	}
+
+	a := got.Attachments[0]
+	if n := utf8.RuneCountInString(a.Text); n > MaxTextLength {
+		t.Fatalf("attachment text too long: %d", n)
+	}
+	if v := a.Fields[0].Value; utf8.RuneCountInString(v) > MaxFieldValueLength || !strings.HasSuffix(v, "```") {
+		t.Fatalf("unexpected field value: %q", v[len(v)-10:])
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]送信に成功した場合", status: http.StatusOK, isNormal: true},
+		{name: "[異常系]エラーが返された場合", status: http.StatusBadRequest, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Payload
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Errorf("failed to unmarshal request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			mattermost := Mattermost{URL: server.URL, Channel: "alerts"}
+			err := mattermost.Notify(context.Background(), notifier.Notification{Payload: slack.Payload{Text: "a &amp; b"}})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Channel != "alerts" || got.Text != "a & b" {
+					t.Fatalf("unexpected payload: %+v", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package mute
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/ttlstore"
+)
+
+// Countは通知しなかったログイベントの件数と、そのロググループとモードです
+type Count struct {
+	Events    int
+	LogGroups []string
+	Modes     []string
+}
+
+// Storeは期間の終了後に件数を通知するために、通知しなかったログイベントの件数をキーごとに記録します
+type Store interface {
+	// Addはキーの件数にcを加えます。記録はttlの間保持します
+	Add(ctx context.Context, key string, c Count, ttl time.Duration) error
+	// Takeはキーの件数を返し、記録を削除します。複数のLambdaから同時に呼び出されても、件数は1回だけ返します
+	Take(ctx context.Context, key string) (Count, error)
+}
+
+// mergeはaにbを加えます。ロググループとモードは重複を除いて名前の順に並べます
+func merge(a Count, b Count) Count {
+	a.Events += b.Events
+	for _, g := range b.LogGroups {
+		if !slices.Contains(a.LogGroups, g) {
+			a.LogGroups = append(a.LogGroups, g)
+		}
+	}
+	for _, m := range b.Modes {
+		if !slices.Contains(a.Modes, m) {
+			a.Modes = append(a.Modes, m)
+		}
+	}
+	slices.Sort(a.LogGroups)
+	slices.Sort(a.Modes)
+	return a
+}
+
+// MemoryStoreはメモリに件数を記録するStoreです。
+// Lambdaの同じ実行環境が呼び出された場合のみ件数を通知できます。テストでも使います
+type MemoryStore ttlstore.Memory[Count]
+
+// NewMemoryStoreはMemoryStoreのコンストラクタです
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) memory() *ttlstore.Memory[Count] {
+	return (*ttlstore.Memory[Count])(s)
+}
+
+func (s *MemoryStore) Add(ctx context.Context, key string, c Count, ttl time.Duration) error {
+	s.memory().Update(key, ttl, func(cur Count) Count { return merge(cur, c) })
+	return nil
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string) (Count, error) {
+	c, _ := s.memory().Take(key)
+	return c, nil
+}
+
+// DynamoDBStoreはDynamoDBのテーブルに件数を記録するStoreです。
+// テーブルの形式はttlstore.DynamoDBと同じで、件数を"events"、ロググループとモードを文字列セットの"log_groups"と"modes"に記録します
+type DynamoDBStore ttlstore.DynamoDB
+
+// Addは件数を加算し、ロググループとモードを文字列セットに追加します
+func (s *DynamoDBStore) Add(ctx context.Context, key string, c Count, ttl time.Duration) error {
+	item := ttlstore.Item{
+		"events":     {N: strconv.Itoa(c.Events)},
+		"log_groups": {SS: c.LogGroups},
+		"modes":      {SS: c.Modes},
+	}
+	return (*ttlstore.DynamoDB)(s).Add(ctx, key, item, ttl)
+}
+
+func (s *DynamoDBStore) Take(ctx context.Context, key string) (Count, error) {
+	item, err := (*ttlstore.DynamoDB)(s).Take(ctx, key)
+	if err != nil || item == nil {
+		return Count{}, err
+	}
+	events, _ := strconv.Atoi(item["events"].N)
+	return merge(Count{Events: events}, Count{LogGroups: item["log_groups"].SS, Modes: item["modes"].SS}), nil
+}
@@ -0,0 +1,138 @@
+package mute
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+	"github.com/tomozo6/cwl2slack/pkg/ttlstore"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.Now = func() time.Time { return now }
+
+	s.Add(ctx, "k", Count{Events: 2, LogGroups: []string{"/b"}, Modes: []string{"plain"}}, time.Hour)
+	s.Add(ctx, "k", Count{Events: 3, LogGroups: []string{"/a", "/b"}, Modes: []string{"plain"}}, time.Hour)
+
+	got, _ := s.Take(ctx, "k")
+	if got.Events != 5 || strings.Join(got.LogGroups, ",") != "/a,/b" || strings.Join(got.Modes, ",") != "plain" {
+		t.Fatalf("unexpected count: %+v", got)
+	}
+	// 2回目は件数を返さない
+	if got, _ := s.Take(ctx, "k"); got.Events != 0 {
+		t.Fatalf("unexpected count: %+v", got)
+	}
+
+	// 期限が切れた件数は返さない
+	s.Add(ctx, "k", Count{Events: 1}, time.Hour)
+	now = now.Add(time.Hour)
+	if got, _ := s.Take(ctx, "k"); got.Events != 0 {
+		t.Fatalf("unexpected count: %+v", got)
+	}
+}
+
+// fakeDynamoDBはUpdateItemのADDとSET、DeleteItemのReturnValuesを再現するサーバーです
+type fakeDynamoDB struct {
+	items map[string]map[string]ttlstore.Attribute
+}
+
+func (f *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	var in struct {
+		Key                       map[string]ttlstore.Attribute
+		UpdateExpression          string
+		ExpressionAttributeNames  map[string]string
+		ExpressionAttributeValues map[string]ttlstore.Attribute
+		ReturnValues              string
+	}
+	json.Unmarshal(b, &in)
+	key := in.Key["key"].S
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "DynamoDB_20120810.UpdateItem":
+		item, ok := f.items[key]
+		if !ok {
+			item = map[string]ttlstore.Attribute{"key": {S: key}}
+		}
+		adds, sets, _ := strings.Cut(strings.TrimPrefix(in.UpdateExpression, "ADD "), " SET ")
+		for _, a := range strings.Split(adds, ", ") {
+			name, value, _ := strings.Cut(a, " ")
+			attr, v := in.ExpressionAttributeNames[name], in.ExpressionAttributeValues[value]
+			if v.N != "" {
+				cur, _ := strconv.Atoi(item[attr].N)
+				n, _ := strconv.Atoi(v.N)
+				item[attr] = ttlstore.Attribute{N: strconv.Itoa(cur + n)}
+			} else {
+				ss := item[attr].SS
+				for _, s := range v.SS {
+					if !slices.Contains(ss, s) {
+						ss = append(ss, s)
+					}
+				}
+				item[attr] = ttlstore.Attribute{SS: ss}
+			}
+		}
+		name, value, _ := strings.Cut(sets, " = ")
+		item[in.ExpressionAttributeNames[name]] = in.ExpressionAttributeValues[value]
+		f.items[key] = item
+		w.Write([]byte(`{}`))
+	case "DynamoDB_20120810.DeleteItem":
+		item, ok := f.items[key]
+		delete(f.items, key)
+		if !ok || in.ReturnValues != "ALL_OLD" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Attributes": item})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(&fakeDynamoDB{items: map[string]map[string]ttlstore.Attribute{}})
+	defer ts.Close()
+
+	c := awsapi.NewClient("ap-northeast-1")
+	c.Credentials = awsapi.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	c.Endpoint = ts.URL
+	s := &DynamoDBStore{Client: c, Table: "cwl2slack-mute", Now: func() time.Time { return now }}
+
+	if err := s.Add(ctx, "k", Count{Events: 2, LogGroups: []string{"/b"}, Modes: []string{"plain"}}, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Add(ctx, "k", Count{Events: 1, LogGroups: []string{"/a"}}, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := s.Take(ctx, "k")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Events != 3 || strings.Join(got.LogGroups, ",") != "/a,/b" || strings.Join(got.Modes, ",") != "plain" {
+		t.Fatalf("unexpected count: %+v", got)
+	}
+	if got, _ := s.Take(ctx, "k"); got.Events != 0 {
+		t.Fatalf("unexpected count: %+v", got)
+	}
+
+	// 期限が切れた件数は返さない
+	s.Add(ctx, "k", Count{Events: 1}, time.Hour)
+	now = now.Add(2 * time.Hour)
+	if got, _ := s.Take(ctx, "k"); got.Events != 0 {
+		t.Fatalf("unexpected count: %+v", got)
+	}
+}
@@ -2,6 +2,7 @@ package myutil
 
 import (
 	"strconv"
+	"strings"
 )
 
 // strconvParseFloatは文字列をfloat64に変換します。
@@ -20,3 +21,15 @@ func StrconvParseFloat(str string, bitSize int) (float64, error) {
 
 	return f, nil
 }
+
+// StringsSplitは文字列をsepで分割し、前後の空白を取り除いた空でない要素の配列を返します。
+// strings.Splitと違い、空文字列の場合はnilを返します。
+func StringsSplit(str string, sep string) []string {
+	var s []string
+	for _, e := range strings.Split(str, sep) {
+		if e = strings.TrimSpace(e); e != "" {
+			s = append(s, e)
+		}
+	}
+	return s
+}
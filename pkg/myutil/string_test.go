@@ -1,6 +1,7 @@
 package myutil
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -58,3 +59,36 @@ func TestStrconvParseFloat(t *testing.T) {
 		})
 	}
 }
+
+func TestStringsSplit(t *testing.T) {
+	testCases := []struct {
+		name string
+		str  string
+		want []string
+	}{
+		{
+			name: "文字列\"\"の場合",
+			str:  "",
+			want: nil,
+		},
+		{
+			name: "カンマ区切りの場合",
+			str:  "com.example., org.acme.",
+			want: []string{"com.example.", "org.acme."},
+		},
+		{
+			name: "空の要素を含む場合",
+			str:  "a,,b,",
+			want: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StringsSplit(tt.str, ",")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unexpected result: %v", got)
+			}
+		})
+	}
+}
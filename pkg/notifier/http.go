@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PostJSONはbodyをJSONにしてurlにPOSTします。
+// 2xx以外のステータスコードが返された場合は*StatusErrorを返します
+func PostJSON(ctx context.Context, url string, header http.Header, body any) error {
+	payloadBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	return Post(ctx, url, header, payloadBytes)
+}
+
+// Postはbodyをそのままurlに送信します。署名などでbodyのバイト列を変えられない場合や、JSON以外の形式で送信する場合に使います。
+// headerでContent-Typeを指定しない場合はapplication/jsonになります
+func Post(ctx context.Context, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+	// http.Header{"authorization": ...}のように正規化されていない名前で指定したヘッダーも、
+	// Addで正規化した名前にして追加し、Getで参照できるようにします
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return &StatusError{StatusCode: res.StatusCode, Body: string(b)}
+	}
+
+	return nil
+}
+
+// StatusErrorは通知先が2xx以外のステータスコードを返したことを表します
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received non-2xx response: %d %s", e.StatusCode, e.Body)
+}
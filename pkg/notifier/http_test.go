@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPostJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]200", status: http.StatusOK, isNormal: true},
+		{name: "[正常系]202", status: http.StatusAccepted, isNormal: true},
+		{name: "[異常系]エラーが返された場合", status: http.StatusInternalServerError, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var body, auth, contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				body = string(b)
+				auth = r.Header.Get("Authorization")
+				contentType = r.Header.Get("Content-Type")
+				w.WriteHeader(tt.status)
+				w.Write([]byte("error detail"))
+			}))
+			defer server.Close()
+
+			// 正規化されていない名前で指定したヘッダーも、正規化した名前で送信されることを確認します
+			header := http.Header{"authorization": []string{"Bearer token"}}
+			err := PostJSON(context.Background(), server.URL, header, map[string]string{"text": "hello"})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if body != `{"text":"hello"}` || auth != "Bearer token" || contentType != "application/json" {
+					t.Fatalf("unexpected request: %s %s %s", body, auth, contentType)
+				}
+				// 異常系のテストケース
+			} else {
+				var se *StatusError
+				if !errors.As(err, &se) || se.StatusCode != tt.status || se.Body != "error detail" {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPostContentType(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Values("Content-Type")
+	}))
+	defer server.Close()
+
+	// 正規化されていない名前で指定したContent-Typeが既定値で上書きされないことを確認します
+	header := http.Header{"content-type": []string{"application/x-www-form-urlencoded"}}
+	if err := Post(context.Background(), server.URL, header, []byte("a=b")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0] != "application/x-www-form-urlencoded" {
+		t.Fatalf("unexpected content type: %v", got)
+	}
+}
@@ -0,0 +1,237 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+	"github.com/tomozo6/cwl2slack/pkg/thread"
+)
+
+// Notificationは通知先に依存しない通知内容です。
+// 表示内容はSlackのペイロードの形式で保持し、各通知先の形式に変換して送信します
+type Notification struct {
+	Payload slack.Payload `json:"payload"`
+
+	// 通知の重要度
+	Severity Severity `json:"severity"`
+
+	// 同じ原因の通知を識別するための値。インシデント管理ツールの重複排除に使います
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// 通知元(ロググループなど)と、ログを解析した内容
+	Source  string         `json:"source,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+
+	// 通知の元になったログイベントのID。再送時に送信済みの通知を判定するために使います
+	EventIDs []string `json:"event_ids,omitempty"`
+
+	// 通知の元になったログイベントのうち最も古い時刻
+	Timestamp time.Time `json:"timestamp,omitempty"`
+
+	// 同じスレッドにまとめる通知のキー(例: アラームのARN)。スレッドに返信できる通知先のみ使います。
+	// StartThreadがtrueの通知は新しいスレッドの親になり、それ以外は親があればそのスレッドに返信します
+	ThreadKey   string `json:"thread_key,omitempty"`
+	StartThread bool   `json:"start_thread,omitempty"`
+}
+
+// Severityは通知の重要度です。値はPagerDuty Events API v2のseverityと同じです
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityError    Severity = "error"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// ParseSeverityは文字列から重要度を返します。大文字と小文字は区別しません
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(s)); sev {
+	case SeverityCritical, SeverityError, SeverityWarning, SeverityInfo:
+		return sev, nil
+	default:
+		return "", fmt.Errorf("invalid severity: %s", s)
+	}
+}
+
+// Levelは重要度の高さを返します。criticalが4、infoが1で、不明な重要度は0です
+func (s Severity) Level() int {
+	switch s {
+	case SeverityCritical:
+		return 4
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityInfo:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// MaxSeverityは重要度のうち最も高いものを返します
+func MaxSeverity(severities ...Severity) Severity {
+	var max Severity
+	for _, s := range severities {
+		if s.Level() > max.Level() {
+			max = s
+		}
+	}
+	return max
+}
+
+// Notifierは通知を通知先に送信します
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// BatchNotifierは1回の実行の通知をまとめて送信できるNotifierです(例: メール)
+type BatchNotifier interface {
+	Notifier
+	NotifyBatch(ctx context.Context, ns []Notification) error
+}
+
+// SequenceNotifierは複数の通知を順番に送信し、通知ごとの結果を返すNotifierです(例: 送信間隔を制限する場合)
+type SequenceNotifier interface {
+	Notifier
+	NotifyEach(ctx context.Context, ns []Notification) []error
+}
+
+// Slackはslack.SlackをNotifierとして使うためのアダプターです。
+// Blocksがtrueの場合はアタッチメントをBlock Kitに変換して送信します
+type Slack struct {
+	slack.Slack
+	Blocks bool
+}
+
+func (s *Slack) Notify(ctx context.Context, n Notification) error {
+	p := n.Payload
+	if s.Blocks {
+		p = slack.PayloadToBlocks(p)
+	}
+	return s.SendNotificationContext(ctx, p)
+}
+
+// SlackAPIはslack.WebAPIをNotifierとして使うためのアダプターです。
+// Threadsが設定されている場合、ThreadKeyが同じ通知をStartThreadの通知のスレッドにまとめます。
+// 親が見つからない場合やスレッドの記録に失敗した場合も、通知はチャンネルに送信して成功とします
+type SlackAPI struct {
+	slack.WebAPI
+	Blocks bool
+
+	Threads thread.Store
+	// スレッドの親メッセージを記録する期間。0の場合はthread.DefaultTTLです
+	TTL time.Duration
+}
+
+func (s *SlackAPI) Notify(ctx context.Context, n Notification) error {
+	p := n.Payload
+	if s.Blocks {
+		p = slack.PayloadToBlocks(p)
+	}
+
+	var key, threadTS string
+	if s.Threads != nil && n.ThreadKey != "" {
+		key = s.Channel + "\n" + n.ThreadKey
+		if !n.StartThread {
+			threadTS, _ = s.Threads.Get(ctx, key)
+		}
+	}
+
+	ts, err := s.PostMessage(ctx, p, threadTS)
+	if err != nil {
+		return err
+	}
+	if key != "" && n.StartThread {
+		ttl := s.TTL
+		if ttl == 0 {
+			ttl = thread.DefaultTTL
+		}
+		_ = s.Threads.Put(ctx, key, ts, ttl)
+	}
+	return nil
+}
+
+// Slackの絵文字コードのうち、通知で使用しているものをUnicodeの絵文字に変換するためのReplacerです
+var emojis = strings.NewReplacer(
+	":rotating_light:", "🚨",
+	":robot_face:", "🤖",
+	":turtle:", "🐢",
+	":boom:", "💥",
+	":warning:", "⚠️",
+	":white_check_mark:", "✅",
+	":information_source:", "ℹ️",
+	":fire:", "🔥",
+	":mute:", "🔇",
+)
+
+var emojiCodePattern = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+
+// TextはSlack向けにエスケープされたテキストを他の通知先で表示するためのテキストに変換します。
+// 絵文字コードはUnicodeの絵文字に変換し、変換できないものは取り除きます。メンションは@hereのようなテキストにします
+func Text(s string) string {
+	s = emojis.Replace(slack.Unescape(slack.StripMentions(s)))
+	return strings.TrimSpace(emojiCodePattern.ReplaceAllString(s, ""))
+}
+
+// CodeはSlack向けのコードブロック(```)であれば、その中身を元のテキストに戻してtrueを返します
+func Code(s string) (string, bool) {
+	if len(s) < 6 || !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") {
+		return "", false
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "```"), "```")
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "\n"), "\n")
+	return slack.Unescape(s), true
+}
+
+// CodeBlockはテキストをMarkdownのコードブロックにします。
+// テキスト中の```でコードブロックが閉じられないように、バッククォートの間にゼロ幅スペースを挟みます
+func CodeBlock(code string) string {
+	for strings.Contains(code, "``") {
+		code = strings.ReplaceAll(code, "``", "`\u200b`")
+	}
+	return "```\n" + code + "\n```"
+}
+
+// PlainTextはペイロードを書式の無いテキストにします。
+// アタッチメントごとにタイトル、「項目: 値」の行、コードブロックとText、リンクの順に並べます
+func PlainText(p slack.Payload) string {
+	var blocks []string
+	if t := Text(p.Text); t != "" {
+		blocks = append(blocks, t)
+	}
+
+	for _, a := range p.Attachments {
+		var lines []string
+		if a.Title != "" {
+			lines = append(lines, Text(a.Title))
+		}
+		for _, f := range a.Fields {
+			if code, ok := Code(f.Value); ok {
+				lines = append(lines, Text(f.Title)+":", code)
+				continue
+			}
+			lines = append(lines, Text(f.Title)+": "+Text(f.Value))
+		}
+		if a.Text != "" {
+			if code, ok := Code(a.Text); ok {
+				lines = append(lines, code)
+			} else {
+				lines = append(lines, Text(a.Text))
+			}
+		}
+		for _, act := range a.Actions {
+			if act.Url != "" {
+				lines = append(lines, act.Text+": "+act.Url)
+			}
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
@@ -0,0 +1,198 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+	"github.com/tomozo6/cwl2slack/pkg/thread"
+)
+
+func TestText(t *testing.T) {
+	testCases := []struct {
+		name string
+		str  string
+		want string
+	}{
+		{name: "エスケープされたテキスト", str: "a &amp; b &lt;!channel&gt;", want: "a & b <!channel>"},
+		{name: "絵文字コード", str: ":rotating_light:アラート", want: "🚨アラート"},
+		{name: "変換できない絵文字コード", str: ":unknown_emoji: hello", want: "hello"},
+		{name: "メンション", str: "<!here> <@U0123> :fire:障害", want: "@here @U0123 🔥障害"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.str); got != tt.want {
+				t.Fatalf("\n got: %q;\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		str      string
+		want     Severity
+		isNormal bool
+	}{
+		// 正常系のテストケース
+		{name: "[正常系]小文字", str: "warning", want: SeverityWarning, isNormal: true},
+		{name: "[正常系]大文字", str: "CRITICAL", want: SeverityCritical, isNormal: true},
+		// 異常系のテストケース
+		{name: "[異常系]不明な重要度", str: "urgent", isNormal: false},
+		{name: "[異常系]空文字", str: "", isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverity(tt.str)
+			if tt.isNormal {
+				if err != nil || got != tt.want {
+					t.Fatalf("got: %s, %v, want: %s", got, err, tt.want)
+				}
+			} else if err == nil {
+				t.Fatalf("expected error, but got nil")
+			}
+		})
+	}
+}
+
+func TestMaxSeverity(t *testing.T) {
+	testCases := []struct {
+		name       string
+		severities []Severity
+		want       Severity
+	}{
+		{name: "最も高い重要度", severities: []Severity{SeverityWarning, SeverityCritical, SeverityError}, want: SeverityCritical},
+		{name: "不明な重要度は無視する", severities: []Severity{"", SeverityInfo, "unknown"}, want: SeverityInfo},
+		{name: "空の場合", severities: nil, want: ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxSeverity(tt.severities...); got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		name   string
+		str    string
+		want   string
+		wantOK bool
+	}{
+		{name: "コードブロック", str: slack.CodeBlock("a < b\n```"), want: "a < b\n```", wantOK: true},
+		{name: "コードブロックでない場合", str: "plain", want: "", wantOK: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Code(tt.str)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("\n got: %q, %v;\nwant: %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSlackNotify(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	var n Notifier = &Slack{Slack: slack.Slack{URL: server.URL}}
+	if err := n.Notify(context.Background(), Notification{Payload: slack.Payload{Text: "hello"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("notification was not sent")
+	}
+}
+
+func TestSlackAPINotify(t *testing.T) {
+	// 送信したメッセージと返信先のスレッドを記録する
+	var threads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			ThreadTS string `json:"thread_ts"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		threads = append(threads, in.ThreadTS)
+		fmt.Fprintf(w, `{"ok":true,"ts":"%d.000100"}`, len(threads))
+	}))
+	defer server.Close()
+
+	var n Notifier = &SlackAPI{
+		WebAPI:  slack.WebAPI{Token: "xoxb-test", Channel: "C0123", URL: server.URL},
+		Threads: thread.NewMemoryStore(),
+	}
+	notifications := []Notification{
+		// 親が無い場合はチャンネルに送信する
+		{Payload: slack.Payload{Text: "OK"}, ThreadKey: "alarm-a"},
+		{Payload: slack.Payload{Text: "ALARM"}, ThreadKey: "alarm-a", StartThread: true},
+		{Payload: slack.Payload{Text: "OK"}, ThreadKey: "alarm-a"},
+		{Payload: slack.Payload{Text: "ALARM"}, ThreadKey: "alarm-b", StartThread: true},
+		{Payload: slack.Payload{Text: "no thread"}},
+	}
+	for _, e := range notifications {
+		if err := n.Notify(context.Background(), e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := []string{"", "", "2.000100", "", ""}
+	if strings.Join(threads, ",") != strings.Join(want, ",") {
+		t.Fatalf("got: %v, want: %v", threads, want)
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	testCases := []struct {
+		name string
+		code string
+		want string
+	}{
+		{name: "バッククォートを含まない場合", code: "a < b", want: "```\na < b\n```"},
+		{name: "```を含む場合", code: "```done```", want: "```\n`\u200b`\u200b`done`\u200b`\u200b`\n```"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeBlock(tt.code); got != tt.want {
+				t.Fatalf("got: %q, want: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	p := slack.Payload{
+		Text: "summary",
+		Attachments: []slack.Attachment{
+			{
+				Title: ":rotating_light:ロググループ &lt;test&gt;",
+				Fields: []slack.Field{
+					{Title: "Log Group", Value: "testLogGroup"},
+					{Title: "Log Messages", Value: slack.CodeBlock("a < b")},
+				},
+				Actions: []slack.Action{{Text: "Open in CloudWatch", Url: "https://example.com"}},
+			},
+		},
+	}
+
+	want := "summary\n\n🚨ロググループ <test>\nLog Group: testLogGroup\nLog Messages:\na < b\nOpen in CloudWatch: https://example.com"
+	if got := PlainText(p); got != want {
+		t.Fatalf("\n got: %q;\nwant: %q", got, want)
+	}
+}
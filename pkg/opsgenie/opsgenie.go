@@ -0,0 +1,159 @@
+package opsgenie
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// DefaultURLはOpsgenieのAlert APIのエンドポイントです。EUのアカウントではhttps://api.eu.opsgenie.com/v2/alertsを使います
+const DefaultURL = "https://api.opsgenie.com/v2/alerts"
+
+// Opsgenieのドキュメントに記載されているアラートの上限です
+// https://docs.opsgenie.com/docs/alert-api#create-alert
+const (
+	MaxMessageLength     = 130
+	MaxDescriptionLength = 15000
+	MaxTags              = 20
+	MaxTagLength         = 50
+	MaxDetailsLength     = 8000
+)
+
+// AlertはAlert APIに送信するアラートです
+type Alert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+}
+
+// OpsgenieはAPIキーを使ってアラートを作成します。URLが空の場合はDefaultURLに送信します
+type Opsgenie struct {
+	URL    string
+	APIKey string
+}
+
+// Notifyは通知をアラートに変換してOpsgenieに送信します。
+// 成功した場合、Opsgenieは202を返します
+func (o *Opsgenie) Notify(ctx context.Context, n notifier.Notification) error {
+	url := o.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	header := http.Header{"Authorization": []string{"GenieKey " + o.APIKey}}
+	return notifier.PostJSON(ctx, url, header, NewAlert(n))
+}
+
+// NewAlertは通知をアラートに変換します。
+// aliasはフィンガープリント、priorityは重要度、tagsはロググループの各階層になり、
+// detailsにはログを解析した内容を値がJSONの文字列として入れます
+func NewAlert(n notifier.Notification) Alert {
+	return Alert{
+		Message:     slack.Truncate(message(n.Payload), MaxMessageLength),
+		Alias:       n.Fingerprint,
+		Description: slack.Truncate(notifier.PlainText(n.Payload), MaxDescriptionLength),
+		Tags:        tags(n.Source, n.Severity),
+		Details:     details(n.Details),
+		Entity:      n.Source,
+		Source:      "cwl2slack",
+		Priority:    priority(n.Severity),
+	}
+}
+
+// messageはアラートの件名として、最初のアタッチメントのタイトルかTextを返します
+func message(p slack.Payload) string {
+	for _, a := range p.Attachments {
+		if a.Title != "" {
+			return notifier.Text(a.Title)
+		}
+	}
+	if s := notifier.Text(p.Text); s != "" {
+		return s
+	}
+	return "cwl2slack notification"
+}
+
+// priorityは重要度をOpsgenieの優先度に変換します。未設定の場合はP3にします
+func priority(s notifier.Severity) string {
+	switch s {
+	case notifier.SeverityCritical:
+		return "P1"
+	case notifier.SeverityError:
+		return "P2"
+	case notifier.SeverityWarning:
+		return "P3"
+	case notifier.SeverityInfo:
+		return "P5"
+	default:
+		return "P3"
+	}
+}
+
+// tagsはロググループの各階層と重要度をタグにします(例: /aws/rds/cluster/prod → aws, rds, cluster, prod)
+func tags(logGroup string, s notifier.Severity) []string {
+	var tags []string
+	add := func(tag string) {
+		if tag == "" || len(tags) >= MaxTags {
+			return
+		}
+		tag = slack.Truncate(tag, MaxTagLength)
+		for _, t := range tags {
+			if t == tag {
+				return
+			}
+		}
+		tags = append(tags, tag)
+	}
+
+	add("cwl2slack")
+	if s != "" {
+		add(string(s))
+	}
+	for _, p := range strings.Split(logGroup, "/") {
+		add(p)
+	}
+	return tags
+}
+
+// detailsはログを解析した内容をOpsgenieのdetails(文字列の値のみ)に変換します。
+// 文字列以外の値はJSONにし、合計がMaxDetailsLengthを超える場合は値を切り詰めます
+func details(d map[string]any) map[string]string {
+	if len(d) == 0 {
+		return nil
+	}
+
+	m := make(map[string]string, len(d))
+	total := 0
+	for k, v := range d {
+		s, ok := v.(string)
+		if !ok {
+			b, err := json.Marshal(v)
+			if err != nil {
+				s = fmt.Sprint(v)
+			} else {
+				s = string(b)
+			}
+		}
+		m[k] = s
+		total += utf8.RuneCountInString(k) + utf8.RuneCountInString(s)
+	}
+
+	// 上限を超える場合は、各値を均等に切り詰めます
+	if total > MaxDetailsLength {
+		limit := MaxDetailsLength / len(m)
+		for k, s := range m {
+			m[k] = slack.Truncate(s, max(limit-utf8.RuneCountInString(k), 1))
+		}
+	}
+	return m
+}
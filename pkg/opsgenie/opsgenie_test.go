@@ -0,0 +1,144 @@
+package opsgenie
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestNewAlert(t *testing.T) {
+	n := notifier.Notification{
+		Payload: slack.Payload{
+			Attachments: []slack.Attachment{
+				{
+					Title:  ":rotating_light:ロググループ &lt;test&gt; にてアラートを検知しました",
+					Fields: []slack.Field{{Title: "Log Group", Value: "/aws/rds/cluster/prod"}},
+				},
+			},
+		},
+		Severity:    notifier.SeverityCritical,
+		Fingerprint: "abc123",
+		Source:      "/aws/rds/cluster/prod",
+		Details:     map[string]any{"log_group": "/aws/rds/cluster/prod", "slow_query": map[string]any{"QueryTime": 4.27}},
+	}
+
+	got := NewAlert(n)
+
+	want := Alert{
+		Message:     "🚨ロググループ <test> にてアラートを検知しました",
+		Alias:       "abc123",
+		Description: "🚨ロググループ <test> にてアラートを検知しました\nLog Group: /aws/rds/cluster/prod",
+		Tags:        []string{"cwl2slack", "critical", "aws", "rds", "cluster", "prod"},
+		Details:     map[string]string{"log_group": "/aws/rds/cluster/prod", "slow_query": `{"QueryTime":4.27}`},
+		Entity:      "/aws/rds/cluster/prod",
+		Source:      "cwl2slack",
+		Priority:    "P1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", got, want)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	testCases := []struct {
+		severity notifier.Severity
+		want     string
+	}{
+		{severity: notifier.SeverityCritical, want: "P1"},
+		{severity: notifier.SeverityError, want: "P2"},
+		{severity: notifier.SeverityWarning, want: "P3"},
+		{severity: notifier.SeverityInfo, want: "P5"},
+		{severity: "", want: "P3"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(string(tt.severity), func(t *testing.T) {
+			if got := priority(tt.severity); got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	n := notifier.Notification{
+		Payload: slack.Payload{Text: strings.Repeat("m", 200)},
+		Source:  "/" + strings.Repeat("g", 60) + strings.Repeat("/x", 30),
+		Details: map[string]any{"a": strings.Repeat("a", 6000), "b": strings.Repeat("b", 6000)},
+	}
+
+	got := NewAlert(n)
+
+	if l := utf8.RuneCountInString(got.Message); l > MaxMessageLength {
+		t.Fatalf("message too long: %d", l)
+	}
+	if len(got.Tags) != 3 {
+		t.Fatalf("unexpected tags: %v", got.Tags)
+	}
+	for _, tag := range got.Tags {
+		if l := utf8.RuneCountInString(tag); l > MaxTagLength {
+			t.Fatalf("tag too long: %d", l)
+		}
+	}
+	total := 0
+	for k, v := range got.Details {
+		total += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	if total > MaxDetailsLength {
+		t.Fatalf("details too long: %d", total)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]受け付けられた場合", status: http.StatusAccepted, isNormal: true},
+		{name: "[異常系]APIキーが不正な場合", status: http.StatusUnauthorized, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Alert
+			var auth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Errorf("failed to unmarshal request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			o := Opsgenie{URL: server.URL, APIKey: "api-key"}
+			err := o.Notify(context.Background(), notifier.Notification{Payload: slack.Payload{Text: "hello"}, Fingerprint: "abc"})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if auth != "GenieKey api-key" || got.Alias != "abc" || got.Message != "hello" {
+					t.Fatalf("unexpected alert: %s %+v", auth, got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
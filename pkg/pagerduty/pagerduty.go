@@ -0,0 +1,126 @@
+package pagerduty
+
+import (
+	"context"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// DefaultURLはPagerDuty Events API v2のエンドポイントです
+const DefaultURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyのドキュメントに記載されているイベントの上限です
+// https://developer.pagerduty.com/docs/events-api-v2/trigger-events/
+const MaxSummaryLength = 1024
+
+// EventはEvents API v2に送信するイベントです
+type Event struct {
+	RoutingKey  string  `json:"routing_key"`
+	EventAction string  `json:"event_action"`
+	DedupKey    string  `json:"dedup_key,omitempty"`
+	Payload     Payload `json:"payload"`
+	Client      string  `json:"client,omitempty"`
+	ClientURL   string  `json:"client_url,omitempty"`
+	Links       []Link  `json:"links,omitempty"`
+}
+
+type Payload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp,omitempty"`
+	Component     string         `json:"component,omitempty"`
+	Group         string         `json:"group,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text,omitempty"`
+}
+
+// PagerDutyはEvents API v2のインテグレーションにイベントを送信します。URLが空の場合はDefaultURLに送信します
+type PagerDuty struct {
+	URL        string
+	RoutingKey string
+}
+
+// Notifyは通知をtriggerイベントに変換してPagerDutyに送信します。
+// 成功した場合、PagerDutyは202を返します
+func (p *PagerDuty) Notify(ctx context.Context, n notifier.Notification) error {
+	url := p.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	return notifier.PostJSON(ctx, url, nil, NewEvent(p.RoutingKey, n))
+}
+
+// NewEventは通知をtriggerイベントに変換します。
+// dedup_keyはフィンガープリント、custom_detailsはログを解析した内容、linksはアタッチメントのリンクとボタンになります
+func NewEvent(routingKey string, n notifier.Notification) Event {
+	e := Event{
+		RoutingKey:  routingKey,
+		EventAction: "trigger",
+		DedupKey:    n.Fingerprint,
+		Client:      "cwl2slack",
+		Payload: Payload{
+			Summary:       slack.Truncate(summary(n.Payload), MaxSummaryLength),
+			Source:        n.Source,
+			Severity:      severity(n.Severity),
+			CustomDetails: n.Details,
+		},
+	}
+	if e.Payload.Source == "" {
+		e.Payload.Source = "cwl2slack"
+	}
+
+	seen := map[string]bool{}
+	addLink := func(href string, text string) {
+		if href == "" || seen[href] {
+			return
+		}
+		seen[href] = true
+		e.Links = append(e.Links, Link{Href: href, Text: text})
+	}
+	for _, a := range n.Payload.Attachments {
+		for _, act := range a.Actions {
+			addLink(act.Url, act.Text)
+		}
+		addLink(a.TitleLink, notifier.Text(a.Title))
+
+		if e.Payload.Timestamp == "" && a.Timestamp != 0 {
+			e.Payload.Timestamp = time.Unix(a.Timestamp, 0).UTC().Format(time.RFC3339)
+		}
+	}
+	if len(e.Links) > 0 {
+		e.ClientURL = e.Links[0].Href
+	}
+
+	return e
+}
+
+// summaryはインシデントの件名として、最初のアタッチメントのタイトルかTextを返します
+func summary(p slack.Payload) string {
+	for _, a := range p.Attachments {
+		if a.Title != "" {
+			return notifier.Text(a.Title)
+		}
+	}
+	if s := notifier.Text(p.Text); s != "" {
+		return s
+	}
+	return "cwl2slack notification"
+}
+
+// severityは重要度をPagerDutyのseverityに変換します。未設定の場合はerrorにします
+func severity(s notifier.Severity) string {
+	switch s {
+	case notifier.SeverityCritical, notifier.SeverityError, notifier.SeverityWarning, notifier.SeverityInfo:
+		return string(s)
+	default:
+		return string(notifier.SeverityError)
+	}
+}
@@ -0,0 +1,124 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestNewEvent(t *testing.T) {
+	n := notifier.Notification{
+		Payload: slack.Payload{
+			Attachments: []slack.Attachment{
+				{
+					Title:     ":rotating_light:ロググループ &lt;test&gt; にて閾値を超えたスロークエリーが検知されました",
+					TitleLink: "https://example.com/log",
+					Timestamp: 1716792813,
+					Actions: []slack.Action{
+						{Type: "button", Text: "Open in CloudWatch", Url: "https://example.com/log"},
+						{Type: "button", Text: "Logs Insights", Url: "https://example.com/insights"},
+					},
+				},
+			},
+		},
+		Severity:    notifier.SeverityCritical,
+		Fingerprint: "abc123",
+		Source:      "/aws/rds/cluster/test/slowquery",
+		Details:     map[string]any{"slow_query": map[string]any{"QueryTime": 4.27}},
+	}
+
+	got := NewEvent("routing-key", n)
+
+	want := Event{
+		RoutingKey:  "routing-key",
+		EventAction: "trigger",
+		DedupKey:    "abc123",
+		Client:      "cwl2slack",
+		ClientURL:   "https://example.com/log",
+		Payload: Payload{
+			Summary:       "🚨ロググループ <test> にて閾値を超えたスロークエリーが検知されました",
+			Source:        "/aws/rds/cluster/test/slowquery",
+			Severity:      "critical",
+			Timestamp:     "2024-05-27T06:53:33Z",
+			CustomDetails: map[string]any{"slow_query": map[string]any{"QueryTime": 4.27}},
+		},
+		Links: []Link{
+			{Href: "https://example.com/log", Text: "Open in CloudWatch"},
+			{Href: "https://example.com/insights", Text: "Logs Insights"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", got, want)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	testCases := []struct {
+		severity notifier.Severity
+		want     string
+	}{
+		{severity: notifier.SeverityCritical, want: "critical"},
+		{severity: notifier.SeverityWarning, want: "warning"},
+		{severity: "", want: "error"},
+		{severity: "unknown", want: "error"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(string(tt.severity), func(t *testing.T) {
+			if got := severity(tt.severity); got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]受け付けられた場合", status: http.StatusAccepted, isNormal: true},
+		{name: "[異常系]ルーティングキーが不正な場合", status: http.StatusBadRequest, isNormal: false},
+		{name: "[異常系]レート制限された場合", status: http.StatusTooManyRequests, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Event
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Errorf("failed to unmarshal request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			pd := PagerDuty{URL: server.URL, RoutingKey: "routing-key"}
+			err := pd.Notify(context.Background(), notifier.Notification{Payload: slack.Payload{Text: "hello"}, Fingerprint: "abc"})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.RoutingKey != "routing-key" || got.DedupKey != "abc" || got.Payload.Summary != "hello" || got.Payload.Source != "cwl2slack" {
+					t.Fatalf("unexpected event: %+v", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
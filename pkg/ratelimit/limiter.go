@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiterはトークンバケットで送信間隔を制限します。
+// 1秒あたりrate個のトークンが補充され、最大burst個まで貯まります。作成時はバケットが満杯です
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// 現在時刻を返す関数と、指定した時間待つ関数です。nilの場合は実際の時刻を使います。テストで使います
+	Now   func() time.Time
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewLimiterはLimiterのコンストラクタです。burstが1未満の場合は1です
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (l *Limiter) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+func (l *Limiter) sleep(ctx context.Context, d time.Duration) error {
+	if l.Sleep != nil {
+		return l.Sleep(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refillは前回からの経過時間分のトークンを補充します。呼び出し元でロックします
+func (l *Limiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+}
+
+// delayはn個のトークンが貯まるまでの時間を返します。呼び出し元でロックします
+func (l *Limiter) delay(n int) time.Duration {
+	l.refill(l.now())
+	missing := float64(n) - l.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / l.rate * float64(time.Second))
+}
+
+// Delayはn個のトークンが貯まるまでの時間を返します。トークンは消費しません
+func (l *Limiter) Delay(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.delay(n)
+}
+
+// reserveはトークンが1個ある場合は消費して0を返し、無い場合はトークンが貯まるまでの時間を返します。
+// 複数のgoroutineが同じトークンを消費しないように、確認と消費を1回のロックで行います
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := l.delay(1)
+	if d == 0 {
+		l.tokens--
+	}
+	return d
+}
+
+// Waitはトークンが1個貯まるまで待ってから消費します。待つ間にctxが終了した場合はエラーを返します
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve()
+		if d == 0 {
+			return nil
+		}
+		if err := l.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClockはSleepで進む時計です
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && c.now.Add(d).After(deadline) {
+		return context.DeadlineExceeded
+	}
+	c.now = c.now.Add(d)
+	c.slept += d
+	return nil
+}
+
+// newTestLimiterはfakeClockを使うLimiterを返します
+func newTestLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	c := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(rate, burst)
+	l.Now = c.Now
+	l.Sleep = c.Sleep
+	return l, c
+}
+
+func TestLimiterWait(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rate      float64
+		burst     int
+		count     int
+		wantSlept time.Duration
+	}{
+		{name: "バースト以内は待たない", rate: 1, burst: 3, count: 3, wantSlept: 0},
+		{name: "1秒に1件", rate: 1, burst: 1, count: 5, wantSlept: 4 * time.Second},
+		{name: "バーストを超えた分を待つ", rate: 2, burst: 2, count: 6, wantSlept: 2 * time.Second},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newTestLimiter(tt.rate, tt.burst)
+			for i := 0; i < tt.count; i++ {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if c.slept != tt.wantSlept {
+				t.Fatalf("slept: %s, want: %s", c.slept, tt.wantSlept)
+			}
+		})
+	}
+}
+
+func TestLimiterDelay(t *testing.T) {
+	l, c := newTestLimiter(1, 2)
+	l.Wait(context.Background())
+	l.Wait(context.Background())
+
+	if d := l.Delay(2); d != 2*time.Second {
+		t.Fatalf("delay: %s", d)
+	}
+
+	// 時間が経つとトークンが補充される。バーストを超えては貯まらない
+	c.now = c.now.Add(10 * time.Second)
+	if d := l.Delay(2); d != 0 {
+		t.Fatalf("delay: %s", d)
+	}
+	if d := l.Delay(3); d != time.Second {
+		t.Fatalf("delay: %s", d)
+	}
+}
+
+func TestLimiterWaitDeadline(t *testing.T) {
+	l, c := newTestLimiter(1, 1)
+	ctx, cancel := context.WithDeadline(context.Background(), c.now.Add(500*time.Millisecond))
+	defer cancel()
+
+	if err := l.Wait(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := l.Wait(ctx); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+}
+
+func TestLimiterWaitConcurrent(t *testing.T) {
+	// トークンは補充されないため、バーストの数だけ送信できる
+	l := NewLimiter(0.001, 5)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// 同時に呼び出すように、全てのgoroutineを起動してから開始する
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	var passed atomic.Int32
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if l.Wait(ctx) == nil {
+				passed.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := passed.Load(); got != 5 {
+		t.Fatalf("passed: %d, want: 5", got)
+	}
+}
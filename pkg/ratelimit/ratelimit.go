@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// DefaultMarginは送信にかかる時間として、期限までに残しておく時間です
+const DefaultMargin = 2 * time.Second
+
+// maxSummaryTitlesはまとめた通知に表示するタイトルの最大数と、タイトルの最大の文字数です
+const (
+	maxSummaryTitles      = 20
+	maxSummaryTitleLength = 150
+)
+
+// Notifierは送信間隔を制限するNotifierです。
+// 期限(Lambdaの残り時間)までに全ての通知を送信できない場合は、残りの通知を1つの通知にまとめて送信します
+type Notifier struct {
+	Notifier notifier.Notifier
+	Limiter  *Limiter
+
+	// 送信にかかる時間として、期限までに残しておく時間。0の場合はDefaultMarginです
+	Margin time.Duration
+}
+
+// Newは送信間隔を制限するNotifierを返します。rateが0以下の場合はそのまま返します
+func New(n notifier.Notifier, rate float64, burst int) notifier.Notifier {
+	if rate <= 0 {
+		return n
+	}
+	return Wrap(n, NewLimiter(rate, burst))
+}
+
+// Wrapはlで送信間隔を制限するNotifierを返します。複数のNotifierで同じLimiterを共有できます。
+// まとめて送信するNotifierは1回しか送信しないため、そのまま返します
+func Wrap(n notifier.Notifier, l *Limiter) notifier.Notifier {
+	if _, ok := n.(notifier.BatchNotifier); ok {
+		return n
+	}
+	return &Notifier{Notifier: n, Limiter: l}
+}
+
+func (r *Notifier) Notify(ctx context.Context, n notifier.Notification) error {
+	if err := r.Limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limit: %w", err)
+	}
+	return r.Notifier.Notify(ctx, n)
+}
+
+// NotifyEachは通知を順番に送信し、通知ごとの結果を返します。
+// この通知の次の通知を期限までに送信できない場合は、この通知以降を1つの通知にまとめて送信し、その結果をまとめた通知の結果とします
+func (r *Notifier) NotifyEach(ctx context.Context, ns []notifier.Notification) []error {
+	errs := make([]error, len(ns))
+	for i := range ns {
+		if len(ns)-i > 1 && !r.fits(ctx, 2) {
+			err := r.Notify(ctx, Summarize(ns[i:]))
+			for j := i; j < len(ns); j++ {
+				errs[j] = err
+			}
+			break
+		}
+		errs[i] = r.Notify(ctx, ns[i])
+	}
+	return errs
+}
+
+// fitsはn個の通知を期限までに送信できる場合にtrueを返します
+func (r *Notifier) fits(ctx context.Context, n int) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+	margin := r.Margin
+	if margin == 0 {
+		margin = DefaultMargin
+	}
+	return !r.Limiter.now().Add(r.Limiter.Delay(n) + margin).After(deadline)
+}
+
+// summaryColorsはまとめた通知の、最も高い重要度ごとのアタッチメントの色です
+var summaryColors = map[notifier.Severity]string{
+	notifier.SeverityCritical: "danger",
+	notifier.SeverityError:    "danger",
+	notifier.SeverityWarning:  "warning",
+	notifier.SeverityInfo:     "#439FE0",
+}
+
+// Summarizeは複数の通知を、件数とタイトルの一覧の1つの通知にまとめます。重要度はまとめた通知の中で最も高いものです
+func Summarize(ns []notifier.Notification) notifier.Notification {
+	severity := notifier.SeverityInfo
+	var ids, titles []string
+	for _, n := range ns {
+		severity = notifier.MaxSeverity(severity, n.Severity)
+		ids = append(ids, n.EventIDs...)
+		if len(titles) < maxSummaryTitles {
+			titles = append(titles, "• "+slack.Truncate(title(n), maxSummaryTitleLength))
+		}
+	}
+	if len(ns) > maxSummaryTitles {
+		titles = append(titles, fmt.Sprintf("ほか%d件", len(ns)-maxSummaryTitles))
+	}
+
+	color, ok := summaryColors[severity]
+	if !ok {
+		color = "warning"
+	}
+
+	text := fmt.Sprintf("送信間隔の制限により、%d件の通知をまとめて送信します", len(ns))
+	return notifier.Notification{
+		Payload: slack.Payload{
+			Attachments: []slack.Attachment{
+				{
+					Fallback:   text,
+					Color:      color,
+					Title:      text,
+					Text:       strings.Join(titles, "\n"),
+					MarkdownIn: []string{"text"},
+				},
+			},
+		},
+		Severity: severity,
+		Source:   ns[0].Source,
+		Details:  map[string]any{"summarized": len(ns)},
+		EventIDs: ids,
+	}
+}
+
+// titleは通知のタイトルを返します。タイトルが無い場合は本文を使います
+func title(n notifier.Notification) string {
+	for _, a := range n.Payload.Attachments {
+		if a.Title != "" {
+			return a.Title
+		}
+	}
+	if n.Payload.Text != "" {
+		return n.Payload.Text
+	}
+	return "(no title)"
+}
@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// recordNotifierは送信した通知を記録するNotifierです
+type recordNotifier struct {
+	sent []notifier.Notification
+	err  error
+}
+
+func (r *recordNotifier) Notify(ctx context.Context, n notifier.Notification) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+// batchNotifierはまとめて送信するNotifierです
+type batchNotifier struct {
+	recordNotifier
+}
+
+func (b *batchNotifier) NotifyBatch(ctx context.Context, ns []notifier.Notification) error {
+	return nil
+}
+
+func testNotifications(n int) []notifier.Notification {
+	ns := make([]notifier.Notification, n)
+	for i := range ns {
+		ns[i] = notifier.Notification{
+			Payload:  slack.Payload{Attachments: []slack.Attachment{{Title: fmt.Sprintf("slow query %d", i+1)}}},
+			Severity: notifier.SeverityWarning,
+			EventIDs: []string{fmt.Sprintf("id%d", i+1)},
+		}
+	}
+	return ns
+}
+
+func TestNotifyEach(t *testing.T) {
+	testCases := []struct {
+		name          string
+		count         int
+		remaining     time.Duration
+		wantSent      int
+		wantSummaries int
+	}{
+		{name: "期限が無い場合は全て送信する", count: 5, wantSent: 5},
+		{name: "期限までに全て送信できる場合", count: 5, remaining: time.Minute, wantSent: 5},
+		{name: "期限までに送信できない通知をまとめる", count: 50, remaining: 12 * time.Second, wantSent: 11, wantSummaries: 1},
+		{name: "1件も送信できない場合は全てまとめる", count: 3, remaining: time.Second, wantSent: 1, wantSummaries: 1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newTestLimiter(1, 1)
+			rec := &recordNotifier{}
+			r := &Notifier{Notifier: rec, Limiter: l}
+
+			ctx := context.Background()
+			deadline := c.now.Add(tt.remaining)
+			if tt.remaining > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, deadline)
+				defer cancel()
+			}
+
+			errs := r.NotifyEach(ctx, testNotifications(tt.count))
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("notification %d: unexpected error: %v", i, err)
+				}
+			}
+			if len(rec.sent) != tt.wantSent {
+				t.Fatalf("sent: %d, want: %d", len(rec.sent), tt.wantSent)
+			}
+
+			summaries := 0
+			for _, n := range rec.sent {
+				if _, ok := n.Details["summarized"]; ok {
+					summaries++
+				}
+			}
+			if summaries != tt.wantSummaries {
+				t.Fatalf("summaries: %d, want: %d", summaries, tt.wantSummaries)
+			}
+			// まとめた通知も期限までに送信する
+			if tt.remaining > 0 && c.now.After(deadline) {
+				t.Fatalf("sent after deadline")
+			}
+		})
+	}
+}
+
+func TestNotifyEachError(t *testing.T) {
+	l, _ := newTestLimiter(1, 1)
+	r := &Notifier{Notifier: &recordNotifier{err: errors.New("boom")}, Limiter: l}
+
+	errs := r.NotifyEach(context.Background(), testNotifications(2))
+	if len(errs) != 2 || errs[0] == nil || errs[1] == nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	ns := testNotifications(25)
+	ns[3].Severity = notifier.SeverityCritical
+
+	got := Summarize(ns)
+
+	if got.Severity != notifier.SeverityCritical || got.Payload.Attachments[0].Color != "danger" {
+		t.Fatalf("unexpected severity: %s", got.Severity)
+	}
+	if len(got.EventIDs) != 25 {
+		t.Fatalf("unexpected event ids: %v", got.EventIDs)
+	}
+	a := got.Payload.Attachments[0]
+	if !strings.Contains(a.Title, "25件") || !strings.Contains(a.Text, "• slow query 1\n") || !strings.HasSuffix(a.Text, "ほか5件") {
+		t.Fatalf("unexpected attachment: %+v", a)
+	}
+}
+
+func TestSummarizeColor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		severity notifier.Severity
+		want     string
+	}{
+		{name: "critical", severity: notifier.SeverityCritical, want: "danger"},
+		{name: "error", severity: notifier.SeverityError, want: "danger"},
+		{name: "warningのみ", severity: notifier.SeverityWarning, want: "warning"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			// 最も高い重要度の色にする
+			ns := testNotifications(3)
+			ns[1].Severity = tt.severity
+			if got := Summarize(ns).Payload.Attachments[0].Color; got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	rec := &recordNotifier{}
+	if _, ok := New(rec, 1, 1).(*Notifier); !ok {
+		t.Fatalf("expected rate limited notifier")
+	}
+	if New(rec, 0, 1) != notifier.Notifier(rec) {
+		t.Fatalf("expected original notifier")
+	}
+	batch := &batchNotifier{}
+	if New(batch, 1, 1) != notifier.Notifier(batch) {
+		t.Fatalf("batch notifier should not be rate limited")
+	}
+}
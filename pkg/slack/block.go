@@ -0,0 +1,337 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sectionブロックに含められるフィールドの最大数です
+const MaxSectionFields = 10
+
+// BlockはBlock Kitのブロックです
+// https://api.slack.com/reference/block-kit/blocks
+type Block interface {
+	BlockType() string
+}
+
+// TextObjectはブロックの中で使うテキストです。Typeはplain_textまたはmrkdwnです
+type TextObject struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// PlainTextはplain_textのTextObjectを返します
+func PlainText(text string) *TextObject {
+	return &TextObject{Type: "plain_text", Text: text, Emoji: true}
+}
+
+// MrkdwnはmrkdwnのTextObjectを返します。textは必要に応じてEscapeしたものを渡します
+func Mrkdwn(text string) *TextObject {
+	return &TextObject{Type: "mrkdwn", Text: text}
+}
+
+type HeaderBlock struct {
+	Text    *TextObject `json:"text"`
+	BlockID string      `json:"block_id,omitempty"`
+}
+
+type SectionBlock struct {
+	Text    *TextObject   `json:"text,omitempty"`
+	Fields  []*TextObject `json:"fields,omitempty"`
+	BlockID string        `json:"block_id,omitempty"`
+}
+
+type ContextBlock struct {
+	Elements []*TextObject `json:"elements"`
+	BlockID  string        `json:"block_id,omitempty"`
+}
+
+type DividerBlock struct {
+	BlockID string `json:"block_id,omitempty"`
+}
+
+type ActionsBlock struct {
+	Elements []*ButtonElement `json:"elements"`
+	BlockID  string           `json:"block_id,omitempty"`
+}
+
+// ButtonElementはactionsブロックで使うボタンです。Styleはprimaryまたはdangerです
+type ButtonElement struct {
+	Text     *TextObject `json:"text"`
+	URL      string      `json:"url,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	Style    string      `json:"style,omitempty"`
+	ActionID string      `json:"action_id,omitempty"`
+}
+
+type RichTextBlock struct {
+	Elements []RichTextElement `json:"elements"`
+	BlockID  string            `json:"block_id,omitempty"`
+}
+
+// RichTextElementはrich_textブロックの要素です。
+// Typeはrich_text_section、rich_text_preformatted、rich_text_quoteのいずれかです
+type RichTextElement struct {
+	Type     string         `json:"type"`
+	Elements []RichTextItem `json:"elements"`
+}
+
+// RichTextItemはrich_textの要素の中のテキストです。
+// rich_textのテキストはmrkdwnとして解釈されないため、エスケープする必要はありません
+type RichTextItem struct {
+	Type  string         `json:"type"`
+	Text  string         `json:"text"`
+	Style *RichTextStyle `json:"style,omitempty"`
+}
+
+type RichTextStyle struct {
+	Bold   bool `json:"bold,omitempty"`
+	Italic bool `json:"italic,omitempty"`
+	Code   bool `json:"code,omitempty"`
+}
+
+func (HeaderBlock) BlockType() string   { return "header" }
+func (SectionBlock) BlockType() string  { return "section" }
+func (ContextBlock) BlockType() string  { return "context" }
+func (DividerBlock) BlockType() string  { return "divider" }
+func (ActionsBlock) BlockType() string  { return "actions" }
+func (RichTextBlock) BlockType() string { return "rich_text" }
+
+// 各ブロックはJSONにする際にtypeを先頭に付けます
+
+func (b HeaderBlock) MarshalJSON() ([]byte, error) {
+	type alias HeaderBlock
+	return marshalBlock(b.BlockType(), alias(b))
+}
+
+func (b SectionBlock) MarshalJSON() ([]byte, error) {
+	type alias SectionBlock
+	return marshalBlock(b.BlockType(), alias(b))
+}
+
+func (b ContextBlock) MarshalJSON() ([]byte, error) {
+	type alias ContextBlock
+	return marshalBlock(b.BlockType(), alias(b))
+}
+
+func (b DividerBlock) MarshalJSON() ([]byte, error) {
+	type alias DividerBlock
+	return marshalBlock(b.BlockType(), alias(b))
+}
+
+func (b ActionsBlock) MarshalJSON() ([]byte, error) {
+	type alias ActionsBlock
+	return marshalBlock(b.BlockType(), alias(b))
+}
+
+func (b RichTextBlock) MarshalJSON() ([]byte, error) {
+	type alias RichTextBlock
+	return marshalBlock(b.BlockType(), alias(b))
+}
+
+func (b ButtonElement) MarshalJSON() ([]byte, error) {
+	type alias ButtonElement
+	return marshalBlock("button", alias(b))
+}
+
+// marshalBlockはvをJSONにして、先頭に"type"を追加します
+func marshalBlock(blockType string, v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := json.Marshal(blockType)
+	if err != nil {
+		return nil, err
+	}
+
+	s := `{"type":` + string(t)
+	if len(b) > 2 {
+		s += ","
+	}
+	return []byte(s + string(b[1:])), nil
+}
+
+// BlockBuilderはブロックの配列を組み立てるビルダーです
+//
+//	blocks := slack.NewBlockBuilder().
+//		Header("タイトル").
+//		Section(slack.Mrkdwn("本文")).
+//		Divider().
+//		Blocks()
+type BlockBuilder struct {
+	blocks []Block
+}
+
+// NewBlockBuilderはBlockBuilderのコンストラクタです
+func NewBlockBuilder() *BlockBuilder {
+	return &BlockBuilder{}
+}
+
+// Headerはheaderブロックを追加します。Slackの上限を超えるテキストは切り詰めます
+func (b *BlockBuilder) Header(text string) *BlockBuilder {
+	return b.Add(HeaderBlock{Text: PlainText(Truncate(text, MaxHeaderLength))})
+}
+
+// Sectionはテキストのsectionブロックを追加します
+func (b *BlockBuilder) Section(text *TextObject) *BlockBuilder {
+	return b.Add(SectionBlock{Text: text})
+}
+
+// Fieldsはフィールドを2列で表示するsectionブロックを追加します。
+// sectionブロックのフィールドは10個までのため、超える場合は複数のブロックに分けます
+func (b *BlockBuilder) Fields(fields ...*TextObject) *BlockBuilder {
+	for len(fields) > 0 {
+		n := min(len(fields), MaxSectionFields)
+		b.Add(SectionBlock{Fields: fields[:n]})
+		fields = fields[n:]
+	}
+	return b
+}
+
+// Contextはcontextブロックを追加します
+func (b *BlockBuilder) Context(elements ...*TextObject) *BlockBuilder {
+	return b.Add(ContextBlock{Elements: elements})
+}
+
+// Dividerはdividerブロックを追加します
+func (b *BlockBuilder) Divider() *BlockBuilder {
+	return b.Add(DividerBlock{})
+}
+
+// Preformattedは整形済みテキスト(コードブロック)のrich_textブロックを追加します
+func (b *BlockBuilder) Preformatted(text string) *BlockBuilder {
+	return b.Add(RichTextBlock{
+		Elements: []RichTextElement{
+			{
+				Type:     "rich_text_preformatted",
+				Elements: []RichTextItem{{Type: "text", Text: text}},
+			},
+		},
+	})
+}
+
+// Buttonsはボタンのactionsブロックを追加します
+func (b *BlockBuilder) Buttons(buttons ...*ButtonElement) *BlockBuilder {
+	if len(buttons) == 0 {
+		return b
+	}
+	return b.Add(ActionsBlock{Elements: buttons})
+}
+
+// Addは任意のブロックを追加します
+func (b *BlockBuilder) Add(block Block) *BlockBuilder {
+	b.blocks = append(b.blocks, block)
+	return b
+}
+
+// Blocksは組み立てたブロックの配列を返します
+func (b *BlockBuilder) Blocks() []Block {
+	return b.blocks
+}
+
+// LinkButtonはURLを開くボタンを返します
+func LinkButton(text string, url string, style string) *ButtonElement {
+	return &ButtonElement{Text: PlainText(text), URL: url, Style: style}
+}
+
+// DateTextはSlackが閲覧者のタイムゾーンで表示する日時の書式を返します。
+// 日時を表示できないクライアントではfallbackが表示されます
+func DateText(t time.Time, fallback string) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} {time_secs}|%s>", t.Unix(), Escape(fallback))
+}
+
+// AttachmentToBlocksはアタッチメントを同じ内容のブロックに変換します。
+// タイトルはheader、Short=trueのフィールドは2列のsection、それ以外のフィールドとTextはsection、
+// ボタンとTitleLinkはactions、フッターと時刻はcontextになります。Colorはブロックでは表現できないため使用しません
+func AttachmentToBlocks(a Attachment) []Block {
+	b := NewBlockBuilder()
+
+	if a.Title != "" {
+		// アタッチメントのタイトルはエスケープ済みのため、plain_textのheaderでは元に戻します
+		b.Header(headerText(Unescape(a.Title)))
+	}
+	if a.PreText != "" {
+		b.Section(Mrkdwn(a.PreText))
+	}
+
+	// 連続するShortのフィールドをまとめて2列で表示します
+	var short []*TextObject
+	flush := func() {
+		b.Fields(short...)
+		short = nil
+	}
+	for _, f := range a.Fields {
+		text := Mrkdwn(fmt.Sprintf("*%s*\n%s", Escape(f.Title), f.Value))
+		if f.Short {
+			short = append(short, text)
+			continue
+		}
+		flush()
+		b.Section(text)
+	}
+	flush()
+
+	if a.Text != "" {
+		b.Section(Mrkdwn(a.Text))
+	}
+
+	// headerはリンクにできないため、TitleLinkは同じURLのボタンが無い場合にボタンにします
+	var buttons []*ButtonElement
+	titleLinked := a.TitleLink == ""
+	for _, act := range a.Actions {
+		if act.Url == "" {
+			continue
+		}
+		titleLinked = titleLinked || act.Url == a.TitleLink
+		buttons = append(buttons, LinkButton(act.Text, act.Url, act.Style))
+	}
+	if !titleLinked {
+		buttons = append([]*ButtonElement{LinkButton("Open", a.TitleLink, "")}, buttons...)
+	}
+	b.Buttons(buttons...)
+
+	var context []string
+	if a.Footer != "" {
+		context = append(context, a.Footer)
+	}
+	if a.Timestamp != 0 {
+		t := time.Unix(a.Timestamp, 0).UTC()
+		context = append(context, DateText(t, t.Format("2006-01-02 15:04:05 MST")))
+	}
+	if len(context) > 0 {
+		b.Context(Mrkdwn(strings.Join(context, " | ")))
+	}
+
+	return b.Blocks()
+}
+
+// PayloadToBlocksはアタッチメント形式のペイロードをBlock Kit形式に変換します。
+// 通知のプレビューに表示されるように、Textが空の場合は最初のアタッチメントのタイトルを設定します
+func PayloadToBlocks(p Payload) Payload {
+	b := NewBlockBuilder()
+	for i, a := range p.Attachments {
+		if i > 0 {
+			b.Divider()
+		}
+		for _, block := range AttachmentToBlocks(a) {
+			b.Add(block)
+		}
+		if p.Text == "" {
+			p.Text = a.Title
+		}
+	}
+
+	p.Blocks = b.Blocks()
+	p.Attachments = nil
+	return p
+}
+
+// headerTextはheaderブロックに表示できるように、タイトルの改行を空白に置き換えます
+func headerText(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
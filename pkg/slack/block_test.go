@@ -0,0 +1,138 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGoldenはvをJSONにしてtestdata配下のゴールデンファイルと比較します。
+// -updateを指定した場合はゴールデンファイルを更新します
+func assertGolden(t *testing.T, name string, v any) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestBlockBuilder(t *testing.T) {
+	blocks := NewBlockBuilder().
+		Header("CloudWatch Logs").
+		Section(Mrkdwn("*Log Group*\n/aws/lambda/my-func")).
+		Fields(Mrkdwn("*User*\nadmin"), Mrkdwn("*Query Time*\n4.27")).
+		Divider().
+		Preformatted("SELECT * FROM `task`;").
+		Context(Mrkdwn("post by cwl2slack")).
+		Buttons(LinkButton("Open in CloudWatch", "https://example.com", "primary")).
+		Blocks()
+
+	assertGolden(t, "blocks.golden", Payload{Text: "CloudWatch Logs", Blocks: blocks})
+}
+
+func TestBlockBuilderFields(t *testing.T) {
+	fields := make([]*TextObject, 13)
+	for i := range fields {
+		fields[i] = Mrkdwn("field")
+	}
+
+	blocks := NewBlockBuilder().Fields(fields...).Blocks()
+
+	// フィールドが10個を超える場合はsectionブロックが分かれます
+	if len(blocks) != 2 {
+		t.Fatalf("unexpected number of blocks: %d", len(blocks))
+	}
+	if n := len(blocks[0].(SectionBlock).Fields); n != MaxSectionFields {
+		t.Fatalf("unexpected number of fields: %d", n)
+	}
+}
+
+func TestAttachmentToBlocksLongTitle(t *testing.T) {
+	a := Attachment{Title: strings.Repeat("長いタイトル\n", 30)}
+
+	blocks := AttachmentToBlocks(a)
+
+	text := blocks[0].(HeaderBlock).Text.Text
+	if n := utf8.RuneCountInString(text); n != MaxHeaderLength {
+		t.Fatalf("unexpected header length: %d", n)
+	}
+	if !strings.HasSuffix(text, Ellipsis) || strings.Contains(text, "\n") {
+		t.Fatalf("unexpected header text: %q", text)
+	}
+	p := Payload{Blocks: blocks}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAttachmentToBlocks(t *testing.T) {
+	a := Attachment{
+		Title:     ":rotating_light:ロググループ &lt;test&gt; にてアラートを検知しました",
+		TitleLink: "https://example.com/log",
+		Color:     "danger",
+		Footer:    "post by cwl2slack",
+		Timestamp: time.Date(2024, 5, 27, 6, 53, 33, 0, time.UTC).Unix(),
+		Fields: []Field{
+			{Title: "Log Group", Value: "testLogGroup", Short: false},
+			{Title: "User", Value: "admin", Short: true},
+			{Title: "Query Time", Value: "4.27", Short: true},
+			{Title: "Log Messages", Value: CodeBlock("message1\nmessage2"), Short: false},
+		},
+		Actions: []Action{
+			{Type: "button", Text: "Open in CloudWatch", Url: "https://example.com/log", Style: "primary"},
+		},
+	}
+
+	assertGolden(t, "attachment_blocks.golden", AttachmentToBlocks(a))
+}
+
+func TestPayloadToBlocks(t *testing.T) {
+	p := Payload{
+		Channel: "#alerts",
+		Attachments: []Attachment{
+			{Title: "first &amp; title", Text: "text1"},
+			{Title: "second", Text: "text2"},
+		},
+	}
+
+	got := PayloadToBlocks(p)
+
+	if got.Attachments != nil {
+		t.Fatalf("attachments should be empty: %+v", got.Attachments)
+	}
+	if got.Text != "first &amp; title" || got.Channel != "#alerts" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+	// header, section, divider, header, section
+	if len(got.Blocks) != 5 || got.Blocks[2].BlockType() != "divider" {
+		t.Fatalf("unexpected blocks: %+v", got.Blocks)
+	}
+	if len(p.Attachments) != 2 {
+		t.Fatalf("original payload was modified: %+v", p)
+	}
+}
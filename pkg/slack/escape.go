@@ -0,0 +1,49 @@
+package slack
+
+import (
+	"strings"
+)
+
+// Slackのメッセージで制御文字として扱われる文字をエスケープするためのReplacerです
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Escapeしたテキストを元に戻すためのReplacerです
+var unescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+// EscapeはSlackのmrkdwnの制御文字(&, <, >)をエスケープします。
+// ログメッセージなどの信頼できないテキストをペイロードに含める場合は必ずこの関数を通します。
+// これにより<!channel>や<@U123>のようなメンション、<http://example.com|link>のようなリンクが無効になります
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// EscapeCodeBlockはコードブロック(```)の中に含めるテキストをエスケープします。
+// Escapeに加えて、テキスト中の```でコードブロックが途中で閉じられないように
+// バッククォートの間にゼロ幅スペースを挟みます
+func EscapeCodeBlock(s string) string {
+	s = Escape(s)
+	for strings.Contains(s, "``") {
+		s = strings.ReplaceAll(s, "``", "`\u200b`")
+	}
+	return s
+}
+
+// CodeBlockはテキストをエスケープしてコードブロックで囲みます
+func CodeBlock(s string) string {
+	return "```\n" + EscapeCodeBlock(s) + "\n```"
+}
+
+// InlineCodeはテキストをエスケープしてインラインコード(`)で囲みます。
+// インラインコードの中ではバッククォートをエスケープできないため、似た文字(ˋ)に置き換えます
+func InlineCode(s string) string {
+	return "`" + strings.ReplaceAll(Escape(s), "`", "ˋ") + "`"
+}
+
+// UnescapeはEscapeまたはEscapeCodeBlockしたテキストを元に戻します。
+// plain_textや他の通知先のようにmrkdwnとして解釈されない場所にエスケープ済みのテキストを表示する場合に使います
+func Unescape(s string) string {
+	for strings.Contains(s, "`\u200b`") {
+		s = strings.ReplaceAll(s, "`\u200b`", "``")
+	}
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,111 @@
+package slack
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	testCases := []struct {
+		name string
+		str  string
+		want string
+	}{
+		{name: "制御文字を含まない場合", str: "[ERROR] failed", want: "[ERROR] failed"},
+		{name: "&<>を含む場合", str: "a & b < c > d", want: "a &amp; b &lt; c &gt; d"},
+		{name: "チャンネルメンション", str: "<!channel> hello", want: "&lt;!channel&gt; hello"},
+		{name: "ユーザーメンション", str: "<@U123>", want: "&lt;@U123&gt;"},
+		{name: "リンク", str: "<https://evil.example.com|click>", want: "&lt;https://evil.example.com|click&gt;"},
+		{name: "エスケープ済みの文字列", str: "&lt;", want: "&amp;lt;"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Escape(tt.str); got != tt.want {
+				t.Fatalf("\n got: %v;\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	testCases := []struct {
+		name string
+		str  string
+		want string
+	}{
+		{name: "通常のテキスト", str: "SELECT 1;", want: "```\nSELECT 1;\n```"},
+		{name: "```を含む場合", str: "a```b", want: "```\na`\u200b`\u200b`b\n```"},
+		{name: "バッククォートが連続する場合", str: "``````", want: "```\n`\u200b`\u200b`\u200b`\u200b`\u200b`\n```"},
+		{name: "単独のバッククォート", str: "`id`", want: "```\n`id`\n```"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CodeBlock(tt.str)
+			if got != tt.want {
+				t.Fatalf("\n got: %q;\nwant: %q", got, tt.want)
+			}
+			// コードブロックの内側に```が含まれないこと
+			if strings.Contains(got[3:len(got)-3], "```") {
+				t.Fatalf("code block can be closed early: %q", got)
+			}
+		})
+	}
+}
+
+func TestInlineCode(t *testing.T) {
+	if got, want := InlineCode("a`b<c>"), "`aˋb&lt;c&gt;`"; got != want {
+		t.Fatalf("\n got: %v;\nwant: %v", got, want)
+	}
+}
+
+// 細工されたログメッセージがエスケープ後のペイロードでメンションやリンクにならないことを確認します
+func TestEscapeCraftedLogLines(t *testing.T) {
+	crafted := []string{
+		"<!channel> database is down",
+		"<!here|here> please check",
+		"<!everyone>",
+		"<@U0123456> you are on call",
+		"<!subteam^S0123|@oncall>",
+		"```\n<!channel>\n```",
+		"<https://evil.example.com|https://status.example.com>",
+	}
+
+	for _, c := range crafted {
+		p := Payload{
+			Attachments: []Attachment{
+				{
+					Title:  Escape(c),
+					Text:   CodeBlock(c),
+					Fields: []Field{{Title: "Log Messages", Value: CodeBlock(c)}, {Title: "Frame", Value: InlineCode(c)}},
+				},
+			},
+		}
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// json.Marshalは<>を\u003cのようにエスケープするため、デコードした値で確認します
+		var decoded Payload
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		a := decoded.Attachments[0]
+		for _, v := range []string{a.Title, a.Text, a.Fields[0].Value, a.Fields[1].Value} {
+			if strings.ContainsAny(v, "<>") {
+				t.Fatalf("unescaped control character in %q", v)
+			}
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	for _, s := range []string{"a & b <!channel>", "``````", "a```b`c", "&lt;"} {
+		if got := Unescape(EscapeCodeBlock(s)); got != s {
+			t.Fatalf("\n got: %q;\nwant: %q", got, s)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package slack
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mentionIDPatternはユーザーIDとユーザーグループIDの形式です
+var mentionIDPattern = regexp.MustCompile(`^[UWS][A-Z0-9]+$`)
+
+// Mentionは設定ファイルなどに書かれたメンションの指定をSlackのメンションの書式に変換します。
+//   - here, channel, everyone: <!here>などの特殊なメンション
+//   - Sで始まるID: <!subteam^S123>のユーザーグループ
+//   - UまたはWで始まるID: <@U123>のユーザー
+//   - <!subteam^S123|name>のようなSlackの書式: そのまま
+//
+// それ以外は信頼できないテキストとしてエスケープし、メンションとして扱いません
+func Mention(s string) string {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "@")
+	switch {
+	case s == "":
+		return ""
+	case s == "here" || s == "channel" || s == "everyone":
+		return "<!" + s + ">"
+	case mentionPattern.FindString(s) == s:
+		return s
+	case mentionIDPattern.MatchString(s) && s[0] == 'S':
+		return "<!subteam^" + s + ">"
+	case mentionIDPattern.MatchString(s):
+		return "<@" + s + ">"
+	default:
+		return Escape(s)
+	}
+}
+
+// IsMentionはMentionがsをメンションに変換できるかどうかを返します。
+// @teamのようなハンドルはIDが分からないためメンションにできず、falseです
+func IsMention(s string) bool {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "@")
+	if s == "" {
+		return false
+	}
+	return s == "here" || s == "channel" || s == "everyone" ||
+		mentionPattern.FindString(s) == s || mentionIDPattern.MatchString(s)
+}
+
+// mentionPatternはSlackのメンションの書式です
+var mentionPattern = regexp.MustCompile(`<(!subteam\^|!|@)([A-Za-z0-9]+)(\|[^>]*)?>`)
+
+// StripMentionsはSlackのメンションを@hereや@U123のような表示用のテキストに置き換えます。
+// メンションの書式を解釈しない他の通知先に表示する場合に使います
+func StripMentions(s string) string {
+	return mentionPattern.ReplaceAllString(s, "@$2")
+}
@@ -0,0 +1,59 @@
+package slack
+
+import "testing"
+
+func TestMention(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "here", in: "here", want: "<!here>"},
+		{name: "@channel", in: "@channel", want: "<!channel>"},
+		{name: "ユーザーグループ", in: "S0123ABCD", want: "<!subteam^S0123ABCD>"},
+		{name: "ユーザー", in: "U0123ABCD", want: "<@U0123ABCD>"},
+		{name: "Slackの書式", in: "<!subteam^S0123ABCD|oncall>", want: "<!subteam^S0123ABCD|oncall>"},
+		{name: "空", in: "", want: ""},
+		{name: "その他はエスケープする", in: "<b>ops</b> team", want: "&lt;b&gt;ops&lt;/b&gt; team"},
+		{name: "メンションでない書式はエスケープする", in: "<http://example.com|link>", want: "&lt;http://example.com|link&gt;"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mention(tt.in); got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsMention(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want bool
+	}{
+		{name: "here", in: "@here", want: true},
+		{name: "ユーザーグループ", in: "S0123ABCD", want: true},
+		{name: "ユーザー", in: "@U0123ABCD", want: true},
+		{name: "Slackの書式", in: "<!subteam^S0123ABCD|oncall>", want: true},
+		{name: "ハンドル", in: "@payments-oncall", want: false},
+		{name: "空", in: "", want: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsMention(tt.in); got != tt.want {
+				t.Fatalf("got: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripMentions(t *testing.T) {
+	got := StripMentions("<!here> <!subteam^S0123|oncall> <@U0123> &lt;!channel&gt;")
+	want := "@here @S0123 @U0123 &lt;!channel&gt;"
+	if got != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package slack
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -50,6 +51,7 @@ type Payload struct {
 	Text        string       `json:"text,omitempty"`
 	LinkNames   string       `json:"link_names,omitempty"`
 	Attachments []Attachment `json:"attachments,omitempty"`
+	Blocks      []Block      `json:"blocks,omitempty"`
 	UnfurlLinks bool         `json:"unfurl_links,omitempty"`
 	UnfurlMedia bool         `json:"unfurl_media,omitempty"`
 	Markdown    bool         `json:"mrkdwn,omitempty"`
@@ -58,21 +60,37 @@ type Payload struct {
 type Slack struct {
 	URL     string
 	Channel string
+
+	// trueの場合、Slackの上限を超えるペイロードを切り詰めずにエラーにします
+	Strict bool
+
+	// 上限を超えるペイロードを切り詰めた場合に、修正した箇所を渡して呼び出す関数です。nilの場合は何もしません
+	Fixed func(*ValidationError)
 }
 
 // func SendSlackNotification(slackURL string, slackChannel string, slackPayload Payload) error {
 func (s *Slack) SendNotification(p Payload) error {
+	return s.SendNotificationContext(context.Background(), p)
+}
+
+// SendNotificationContextはコンテキストを指定してSlackに通知します
+func (s *Slack) SendNotificationContext(ctx context.Context, p Payload) error {
 	// チャンネルの上書き
 	if s.Channel != "" {
 		p.Channel = s.Channel
 	}
 
+	// Slackの上限を超えるペイロードは送信前に切り詰めます
+	if err := p.prepare(s.Strict, s.Fixed); err != nil {
+		return err
+	}
+
 	payloadBytes, err := json.Marshal(p)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Slack Payload: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", s.URL, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return fmt.Errorf("failed to create new HTTP request: %w", err)
 	}
@@ -0,0 +1,236 @@
+package slack
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Slackのドキュメントに記載されているメッセージの上限です
+// https://api.slack.com/reference/block-kit/blocks
+// https://api.slack.com/reference/messaging/attachments
+const (
+	MaxTextLength         = 40000
+	MaxBlocks             = 50
+	MaxAttachments        = 100
+	MaxAttachmentFields   = 10
+	MaxFieldValueLength   = 2000
+	MaxHeaderLength       = 150
+	MaxSectionTextLength  = 3000
+	MaxSectionFieldLength = 2000
+	MaxContextElements    = 10
+	MaxActionsElements    = 25
+	MaxButtonTextLength   = 75
+)
+
+// Ellipsisは上限を超えたテキストを切り詰めた際に末尾に付ける目印です
+const Ellipsis = "…"
+
+// ViolationはペイロードがSlackの上限を超えている箇所です
+type Violation struct {
+	Path   string // 例: blocks[3].text.text
+	Rule   string // 例: max_length
+	Limit  int
+	Actual int
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s %d exceeded (got %d)", v.Path, v.Rule, v.Limit, v.Actual)
+}
+
+// ValidationErrorはValidateで見つかった違反の一覧です
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return "invalid Slack payload: " + strings.Join(s, "; ")
+}
+
+// ValidateはペイロードがSlackの上限を超えていないかを確認します。
+// 上限を超えている場合は*ValidationErrorを返します
+func (p *Payload) Validate() error {
+	v := &validator{}
+	v.payload(p)
+
+	if len(v.violations) > 0 {
+		return &ValidationError{Violations: v.violations}
+	}
+	return nil
+}
+
+// Fixはペイロードを上限に収まるように修正し、修正した箇所を返します。
+// テキストは末尾をEllipsisにして切り詰め、要素の数が多すぎる場合は最後の要素を省略した件数の表示に置き換えます
+func (p *Payload) Fix() []Violation {
+	v := &validator{fix: true}
+	v.payload(p)
+
+	return v.violations
+}
+
+// prepareは送信前にペイロードを確認します。上限を超えている場合、strictであれば*ValidationErrorを返し、
+// そうでなければ通知が失われないように切り詰めて、修正した箇所をfixedに渡します
+func (p *Payload) prepare(strict bool, fixed func(*ValidationError)) error {
+	if strict {
+		return p.Validate()
+	}
+	if violations := p.Fix(); len(violations) > 0 && fixed != nil {
+		fixed(&ValidationError{Violations: violations})
+	}
+	return nil
+}
+
+// validatorはペイロードを走査して違反を集めます。fixがtrueの場合は違反を修正します
+type validator struct {
+	fix        bool
+	violations []Violation
+}
+
+func (v *validator) add(path string, rule string, limit int, actual int) {
+	v.violations = append(v.violations, Violation{Path: path, Rule: rule, Limit: limit, Actual: actual})
+}
+
+// textはテキストの長さを確認します
+func (v *validator) text(path string, s *string, limit int) {
+	if n := utf8.RuneCountInString(*s); n > limit {
+		v.add(path, "max_length", limit, n)
+		if v.fix {
+			*s = Truncate(*s, limit)
+		}
+	}
+}
+
+// countは要素の数を確認し、上限を超えていれば修正後の要素の数を返します。
+// 修正する場合は上限-1個の要素を残し、最後の1つを省略した件数の表示にします
+func (v *validator) count(path string, n int, limit int) (int, bool) {
+	if n <= limit {
+		return n, false
+	}
+	v.add(path, "max_items", limit, n)
+	return limit - 1, v.fix
+}
+
+func (v *validator) payload(p *Payload) {
+	// 呼び出し元のペイロードと配列を共有しないように、修正する場合は複製してから修正します
+	if v.fix {
+		p.Attachments = slices.Clone(p.Attachments)
+		p.Blocks = slices.Clone(p.Blocks)
+	}
+
+	v.text("text", &p.Text, MaxTextLength)
+
+	if n, fix := v.count("attachments", len(p.Attachments), MaxAttachments); fix {
+		omitted := len(p.Attachments) - n
+		p.Attachments = append(p.Attachments[:n], Attachment{Text: fmt.Sprintf("%s %d attachments omitted", Ellipsis, omitted)})
+	}
+	for i := range p.Attachments {
+		v.attachment(fmt.Sprintf("attachments[%d]", i), &p.Attachments[i])
+	}
+
+	if n, fix := v.count("blocks", len(p.Blocks), MaxBlocks); fix {
+		omitted := len(p.Blocks) - n
+		p.Blocks = append(p.Blocks[:n], ContextBlock{Elements: []*TextObject{Mrkdwn(fmt.Sprintf("%s %d blocks omitted", Ellipsis, omitted))}})
+	}
+	for i := range p.Blocks {
+		b := v.block(fmt.Sprintf("blocks[%d]", i), p.Blocks[i])
+		if v.fix {
+			p.Blocks[i] = b
+		}
+	}
+}
+
+func (v *validator) attachment(path string, a *Attachment) {
+	if v.fix {
+		a.Fields = slices.Clone(a.Fields)
+	}
+
+	v.text(path+".text", &a.Text, MaxTextLength)
+
+	if n, fix := v.count(path+".fields", len(a.Fields), MaxAttachmentFields); fix {
+		omitted := len(a.Fields) - n
+		a.Fields = append(a.Fields[:n], Field{Title: Ellipsis, Value: fmt.Sprintf("%d fields omitted", omitted)})
+	}
+	for i := range a.Fields {
+		v.text(fmt.Sprintf("%s.fields[%d].value", path, i), &a.Fields[i].Value, MaxFieldValueLength)
+	}
+}
+
+// blockはブロックを確認します。ブロックは値として保持されているため、修正したブロックを返します
+func (v *validator) block(path string, b Block) Block {
+	switch b := b.(type) {
+	case HeaderBlock:
+		if b.Text != nil {
+			t := *b.Text
+			v.text(path+".text.text", &t.Text, MaxHeaderLength)
+			b.Text = &t
+		}
+		return b
+	case SectionBlock:
+		if b.Text != nil {
+			t := *b.Text
+			v.text(path+".text.text", &t.Text, MaxSectionTextLength)
+			b.Text = &t
+		}
+		fields := b.Fields
+		if n, fix := v.count(path+".fields", len(fields), MaxSectionFields); fix {
+			fields = append(fields[:n:n], Mrkdwn(fmt.Sprintf("%s %d fields omitted", Ellipsis, len(b.Fields)-n)))
+		}
+		b.Fields = make([]*TextObject, len(fields))
+		for i, f := range fields {
+			t := *f
+			v.text(fmt.Sprintf("%s.fields[%d].text", path, i), &t.Text, MaxSectionFieldLength)
+			b.Fields[i] = &t
+		}
+		return b
+	case ContextBlock:
+		if n, fix := v.count(path+".elements", len(b.Elements), MaxContextElements); fix {
+			b.Elements = append(b.Elements[:n:n], Mrkdwn(fmt.Sprintf("%s %d elements omitted", Ellipsis, len(b.Elements)-n)))
+		}
+		return b
+	case ActionsBlock:
+		if n, fix := v.count(path+".elements", len(b.Elements), MaxActionsElements); fix {
+			b.Elements = b.Elements[: n+1 : n+1]
+		}
+		elements := make([]*ButtonElement, len(b.Elements))
+		for i, e := range b.Elements {
+			e := *e
+			if e.Text != nil {
+				t := *e.Text
+				v.text(fmt.Sprintf("%s.elements[%d].text.text", path, i), &t.Text, MaxButtonTextLength)
+				e.Text = &t
+			}
+			elements[i] = &e
+		}
+		b.Elements = elements
+		return b
+	default:
+		return b
+	}
+}
+
+// Truncateはテキストがlimit文字に収まるように末尾をEllipsisにして切り詰めます。
+// 切り詰めによってコードブロック(```)が閉じられなくなる場合は、末尾に```を補います
+func Truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+
+	// コードブロックを含む場合は閉じるための```の分も空けておきます
+	const fence = "\n```"
+	n := limit - utf8.RuneCountInString(Ellipsis)
+	if strings.Contains(s, "```") {
+		n -= utf8.RuneCountInString(fence)
+	}
+	n = max(n, 0)
+
+	t := string([]rune(s)[:n])
+	if strings.Count(t, "```")%2 == 1 {
+		return t + Ellipsis + fence
+	}
+	return t + Ellipsis
+}
@@ -0,0 +1,212 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestValidate(t *testing.T) {
+	manyFields := make([]Field, 12)
+	manyBlocks := make([]Block, 51)
+	for i := range manyBlocks {
+		manyBlocks[i] = DividerBlock{}
+	}
+
+	testCases := []struct {
+		name     string
+		payload  Payload
+		isNormal bool
+		want     []Violation
+	}{
+		{
+			name: "[正常系]上限を超えていない場合",
+			payload: Payload{
+				Text:        "hello",
+				Attachments: []Attachment{{Fields: []Field{{Title: "a", Value: "b"}}}},
+				Blocks:      NewBlockBuilder().Header("title").Blocks(),
+			},
+			isNormal: true,
+		},
+		{
+			name:     "[異常系]アタッチメントのフィールドが多すぎる場合",
+			payload:  Payload{Attachments: []Attachment{{Fields: manyFields}}},
+			isNormal: false,
+			want:     []Violation{{Path: "attachments[0].fields", Rule: "max_items", Limit: 10, Actual: 12}},
+		},
+		{
+			name:     "[異常系]ブロックが多すぎる場合",
+			payload:  Payload{Blocks: manyBlocks},
+			isNormal: false,
+			want:     []Violation{{Path: "blocks", Rule: "max_items", Limit: 50, Actual: 51}},
+		},
+		{
+			name:     "[異常系]ヘッダーが長すぎる場合",
+			payload:  Payload{Blocks: []Block{HeaderBlock{Text: PlainText(strings.Repeat("あ", 151))}}},
+			isNormal: false,
+			want:     []Violation{{Path: "blocks[0].text.text", Rule: "max_length", Limit: 150, Actual: 151}},
+		},
+		{
+			name: "[異常系]テキストが長すぎる場合",
+			payload: Payload{
+				Text:   strings.Repeat("a", 40001),
+				Blocks: NewBlockBuilder().Section(Mrkdwn(strings.Repeat("a", 3001))).Blocks(),
+			},
+			isNormal: false,
+			want: []Violation{
+				{Path: "text", Rule: "max_length", Limit: 40000, Actual: 40001},
+				{Path: "blocks[0].text.text", Rule: "max_length", Limit: 3000, Actual: 3001},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.payload.Validate()
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// 異常系のテストケース
+			} else {
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("expected ValidationError, but got %v", err)
+				}
+				if !reflect.DeepEqual(verr.Violations, tt.want) {
+					t.Fatalf("\n got: %+v;\nwant: %+v", verr.Violations, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFix(t *testing.T) {
+	fields := make([]Field, 12)
+	fields[0].Value = CodeBlock(strings.Repeat("x", 2500))
+	p := Payload{
+		Attachments: []Attachment{{Fields: fields}},
+		Blocks:      []Block{HeaderBlock{Text: PlainText(strings.Repeat("h", 200))}},
+	}
+
+	violations := p.Fix()
+	if len(violations) != 3 {
+		t.Fatalf("unexpected violations: %+v", violations)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("payload is still invalid: %v", err)
+	}
+
+	// 要素の数が多すぎる場合は最後の要素が省略した件数の表示になります
+	got := p.Attachments[0].Fields
+	if len(got) != MaxAttachmentFields || got[9].Value != "3 fields omitted" {
+		t.Fatalf("unexpected fields: %+v", got[9])
+	}
+
+	// コードブロックは閉じられたまま切り詰められます
+	v := got[0].Value
+	if utf8.RuneCountInString(v) != MaxFieldValueLength || !strings.HasSuffix(v, Ellipsis+"\n```") {
+		t.Fatalf("unexpected truncated value: %q", v[len(v)-10:])
+	}
+
+	// 元のフィールドは変更されません
+	if len(fields[0].Value) != 2508 {
+		t.Fatalf("original fields were modified")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		str   string
+		limit int
+		want  string
+	}{
+		{name: "上限以内の場合", str: "abc", limit: 3, want: "abc"},
+		{name: "上限を超える場合", str: "abcdef", limit: 4, want: "abc…"},
+		{name: "マルチバイト文字", str: "あいうえお", limit: 3, want: "あい…"},
+		{name: "コードブロック", str: "```\nabcdefghij\n```", limit: 12, want: "```\nabc…\n```"},
+		{name: "コードブロックの後ろで切り詰める場合", str: "```\nab\n```\ncdefghijklmn", limit: 16, want: "```\nab\n```\n…"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.str, tt.limit); got != tt.want {
+				t.Fatalf("\n got: %q;\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendNotificationValidation(t *testing.T) {
+	// 送信されたheaderブロックのテキスト
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p struct {
+			Blocks []struct {
+				Text struct {
+					Text string `json:"text"`
+				} `json:"text"`
+			} `json:"blocks"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		got = append(got, p.Blocks[0].Text.Text)
+	}))
+	defer server.Close()
+
+	p := Payload{Blocks: []Block{HeaderBlock{Text: PlainText(strings.Repeat("h", 200))}}}
+
+	// 切り詰めた箇所
+	var fixed []*ValidationError
+	onFixed := func(err *ValidationError) { fixed = append(fixed, err) }
+
+	testCases := []struct {
+		name     string
+		slack    Slack
+		isNormal bool
+	}{
+		{name: "[正常系]上限を超えるペイロードは切り詰めて送信します", slack: Slack{URL: server.URL, Fixed: onFixed}, isNormal: true},
+		{name: "[異常系]Strictの場合は送信せずにエラーになります", slack: Slack{URL: server.URL, Strict: true, Fixed: onFixed}, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fixed = nil, nil
+			err := tt.slack.SendNotification(p)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(got) != 1 {
+					t.Fatalf("payload was not sent")
+				}
+				if n := utf8.RuneCountInString(got[0]); n != MaxHeaderLength {
+					t.Fatalf("unexpected header length: %d", n)
+				}
+				if len(fixed) != 1 || fixed[0].Violations[0].Path != "blocks[0].text.text" {
+					t.Fatalf("unexpected fixed: %v", fixed)
+				}
+				// 異常系のテストケース
+			} else {
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("expected ValidationError, but got %v", err)
+				}
+				if len(got) != 0 || len(fixed) != 0 {
+					t.Fatalf("invalid payload was sent")
+				}
+			}
+		})
+	}
+}
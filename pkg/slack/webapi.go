@@ -0,0 +1,84 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// DefaultWebAPIURLはSlack Web APIのchat.postMessageのURLです
+const DefaultWebAPIURL = "https://slack.com/api/chat.postMessage"
+
+// WebAPIはボットトークンを使ってSlack Web APIのchat.postMessageでメッセージを送信します。
+// Incoming Webhookと異なり、送信したメッセージのタイムスタンプを取得してスレッドに返信できます
+type WebAPI struct {
+	Token   string
+	Channel string
+
+	// 送信先のURL。空の場合はDefaultWebAPIURLです
+	URL string
+
+	// trueの場合、Slackの上限を超えるペイロードを切り詰めずにエラーにします
+	Strict bool
+
+	// 上限を超えるペイロードを切り詰めた場合に、修正した箇所を渡して呼び出す関数です。nilの場合は何もしません
+	Fixed func(*ValidationError)
+}
+
+// PostMessageはペイロードをチャンネルに送信し、送信したメッセージのタイムスタンプを返します。
+// threadTSを指定した場合はそのメッセージのスレッドに返信します
+func (s *WebAPI) PostMessage(ctx context.Context, p Payload, threadTS string) (string, error) {
+	if s.Channel != "" {
+		p.Channel = s.Channel
+	}
+	if err := p.prepare(s.Strict, s.Fixed); err != nil {
+		return "", err
+	}
+
+	body := struct {
+		Payload
+		ThreadTS string `json:"thread_ts,omitempty"`
+	}{p, threadTS}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Slack Payload: %w", err)
+	}
+
+	url := s.URL
+	if url == "" {
+		url = DefaultWebAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
+	if err != nil {
+		return "", fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("received non-200 response: %d", res.StatusCode)
+	}
+
+	// Web APIはエラーの場合もステータスコード200でokをfalseにして返します
+	var out struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !out.OK {
+		return "", fmt.Errorf("slack api error: %s", out.Error)
+	}
+	return out.TS, nil
+}
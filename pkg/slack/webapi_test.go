@@ -0,0 +1,74 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebAPIPostMessage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+			return
+		}
+		var in map[string]any
+		json.NewDecoder(r.Body).Decode(&in)
+		if in["channel"] != "C0123" {
+			w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+			return
+		}
+		// スレッドへの返信の場合は親のタイムスタンプを含めて返す
+		if threadTS, ok := in["thread_ts"].(string); ok {
+			w.Write([]byte(`{"ok":true,"ts":"` + threadTS + `.reply"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"ts":"1716792813.000100"}`))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name     string
+		token    string
+		text     string
+		strict   bool
+		threadTS string
+		want     string
+		isNormal bool
+	}{
+		{name: "[正常系]メッセージの送信", token: "xoxb-test", want: "1716792813.000100", isNormal: true},
+		{name: "[正常系]スレッドへの返信", token: "xoxb-test", threadTS: "1716792813.000100", want: "1716792813.000100.reply", isNormal: true},
+		{name: "[正常系]上限を超えるテキストは切り詰めて送信", token: "xoxb-test", text: strings.Repeat("a", MaxTextLength+1), want: "1716792813.000100", isNormal: true},
+		{name: "[異常系]トークンが不正", token: "xoxb-invalid", isNormal: false},
+		{name: "[異常系]Strictで上限を超える場合", token: "xoxb-test", text: strings.Repeat("a", MaxTextLength+1), strict: true, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			text := tt.text
+			if text == "" {
+				text = "test"
+			}
+			s := &WebAPI{Token: tt.token, Channel: "C0123", URL: ts.URL, Strict: tt.strict}
+			got, err := s.PostMessage(context.Background(), Payload{Text: text}, tt.threadTS)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Fatalf("got: %s, want: %s", got, tt.want)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package teams
+
+import (
+	"context"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+// MessageはTeamsのIncoming WebhookやWorkflowsに送信するメッセージです
+type Message struct {
+	Type        string       `json:"type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+type Attachment struct {
+	ContentType string       `json:"contentType"`
+	ContentURL  *string      `json:"contentUrl"`
+	Content     AdaptiveCard `json:"content"`
+}
+
+// AdaptiveCardはAdaptive Cardの本体です
+// https://adaptivecards.io/explorer/
+type AdaptiveCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []Element      `json:"body"`
+	Actions []Action       `json:"actions,omitempty"`
+	MSTeams map[string]any `json:"msteams,omitempty"`
+}
+
+// ElementはAdaptive Cardの要素です。TextBlockとFactSetで使う項目のみを定義しています
+type Element struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Weight   string `json:"weight,omitempty"`
+	Color    string `json:"color,omitempty"`
+	FontType string `json:"fontType,omitempty"`
+	Wrap     bool   `json:"wrap,omitempty"`
+	Spacing  string `json:"spacing,omitempty"`
+	Facts    []Fact `json:"facts,omitempty"`
+}
+
+type Fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type Action struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type Teams struct {
+	URL string
+}
+
+// Notifyは通知をAdaptive Cardに変換してTeamsに送信します。
+// Incoming Webhookは200、Workflowsは202を返します
+func (t *Teams) Notify(ctx context.Context, n notifier.Notification) error {
+	return notifier.PostJSON(ctx, t.URL, nil, NewMessage(n.Payload))
+}
+
+// NewMessageはSlackのペイロードをAdaptive Cardのメッセージに変換します。
+// アタッチメントのタイトルは見出し、フィールドはFactSet、コードブロックのフィールドとTextは等幅のTextBlock、
+// ボタンはAction.OpenUrlになります
+func NewMessage(p slack.Payload) Message {
+	card := AdaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		MSTeams: map[string]any{"width": "Full"},
+	}
+
+	if p.Text != "" && len(p.Attachments) == 0 {
+		card.Body = append(card.Body, Element{Type: "TextBlock", Text: notifier.Text(p.Text), Wrap: true})
+	}
+
+	for _, a := range p.Attachments {
+		if a.Title != "" {
+			card.Body = append(card.Body, Element{
+				Type:   "TextBlock",
+				Text:   notifier.Text(a.Title),
+				Size:   "Large",
+				Weight: "Bolder",
+				Color:  color(a.Color),
+				Wrap:   true,
+			})
+		}
+
+		// コードブロック以外のフィールドはまとめてFactSetにします
+		var facts []Fact
+		var codes []Element
+		for _, f := range a.Fields {
+			if code, ok := notifier.Code(f.Value); ok {
+				codes = append(codes,
+					Element{Type: "TextBlock", Text: notifier.Text(f.Title), Weight: "Bolder", Spacing: "Medium"},
+					codeBlock(code),
+				)
+				continue
+			}
+			facts = append(facts, Fact{Title: notifier.Text(f.Title), Value: notifier.Text(f.Value)})
+		}
+		if len(facts) > 0 {
+			card.Body = append(card.Body, Element{Type: "FactSet", Facts: facts})
+		}
+		card.Body = append(card.Body, codes...)
+
+		if a.Text != "" {
+			if code, ok := notifier.Code(a.Text); ok {
+				card.Body = append(card.Body, codeBlock(code))
+			} else {
+				card.Body = append(card.Body, Element{Type: "TextBlock", Text: notifier.Text(a.Text), Wrap: true})
+			}
+		}
+
+		for _, act := range a.Actions {
+			if act.Url != "" {
+				card.Actions = append(card.Actions, Action{Type: "Action.OpenUrl", Title: act.Text, URL: act.Url})
+			}
+		}
+		if a.TitleLink != "" && len(a.Actions) == 0 {
+			card.Actions = append(card.Actions, Action{Type: "Action.OpenUrl", Title: "Open", URL: a.TitleLink})
+		}
+	}
+
+	return Message{
+		Type: "message",
+		Attachments: []Attachment{
+			{
+				ContentType: "application/vnd.microsoft.card.adaptive",
+				Content:     card,
+			},
+		},
+	}
+}
+
+// codeBlockは等幅フォントのTextBlockを返します
+func codeBlock(code string) Element {
+	return Element{Type: "TextBlock", Text: code, FontType: "Monospace", Wrap: true}
+}
+
+// colorはSlackのアタッチメントの色をAdaptive Cardの色に変換します
+func color(c string) string {
+	switch c {
+	case "danger":
+		return "Attention"
+	case "warning":
+		return "Warning"
+	case "good":
+		return "Good"
+	default:
+		return "Default"
+	}
+}
@@ -0,0 +1,93 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+func TestNewMessage(t *testing.T) {
+	p := slack.Payload{
+		Attachments: []slack.Attachment{
+			{
+				Title: ":rotating_light:ロググループ &lt;test&gt; にてアラートを検知しました",
+				Color: "danger",
+				Fields: []slack.Field{
+					{Title: "Log Group", Value: "testLogGroup"},
+					{Title: "Log Messages", Value: slack.CodeBlock("a < b\n```done```")},
+				},
+				Actions: []slack.Action{
+					{Type: "button", Text: "Open in CloudWatch", Url: "https://example.com"},
+				},
+			},
+		},
+	}
+
+	card := NewMessage(p).Attachments[0].Content
+
+	wantBody := []Element{
+		{Type: "TextBlock", Text: "🚨ロググループ <test> にてアラートを検知しました", Size: "Large", Weight: "Bolder", Color: "Attention", Wrap: true},
+		{Type: "FactSet", Facts: []Fact{{Title: "Log Group", Value: "testLogGroup"}}},
+		{Type: "TextBlock", Text: "Log Messages", Weight: "Bolder", Spacing: "Medium"},
+		{Type: "TextBlock", Text: "a < b\n```done```", FontType: "Monospace", Wrap: true},
+	}
+	if !reflect.DeepEqual(card.Body, wantBody) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", card.Body, wantBody)
+	}
+
+	wantActions := []Action{{Type: "Action.OpenUrl", Title: "Open in CloudWatch", URL: "https://example.com"}}
+	if !reflect.DeepEqual(card.Actions, wantActions) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", card.Actions, wantActions)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]Incoming Webhook", status: http.StatusOK, isNormal: true},
+		{name: "[正常系]Workflows", status: http.StatusAccepted, isNormal: true},
+		{name: "[異常系]エラーが返された場合", status: http.StatusBadRequest, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Message
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Errorf("failed to unmarshal request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			teams := Teams{URL: server.URL}
+			err := teams.Notify(context.Background(), notifier.Notification{Payload: slack.Payload{Text: "hello"}})
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Type != "message" || got.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+					t.Fatalf("unexpected message: %+v", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package thread
+
+import (
+	"context"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/ttlstore"
+)
+
+// DefaultTTLはスレッドの親メッセージを記録する既定の期間です
+const DefaultTTL = 7 * 24 * time.Hour
+
+// Storeはスレッドのキーと、親メッセージのタイムスタンプを期限付きで記録します
+type Store interface {
+	// Getはキーに記録されている親メッセージのタイムスタンプを返します。記録が無い場合は空文字です
+	Get(ctx context.Context, key string) (string, error)
+	// Putは親メッセージのタイムスタンプをttlの間記録します。記録がある場合は上書きします
+	Put(ctx context.Context, key string, ts string, ttl time.Duration) error
+}
+
+// MemoryStoreはメモリに記録するStoreです。
+// Lambdaの同じ実行環境で処理された場合のみスレッドに返信できます。テストでも使います
+type MemoryStore ttlstore.Memory[string]
+
+// NewMemoryStoreはMemoryStoreのコンストラクタです
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) memory() *ttlstore.Memory[string] {
+	return (*ttlstore.Memory[string])(s)
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	ts, _ := s.memory().Get(key)
+	return ts, nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, ts string, ttl time.Duration) error {
+	s.memory().Put(key, ts, ttl)
+	return nil
+}
+
+// DynamoDBStoreはDynamoDBのテーブルに記録するStoreです。
+// テーブルの形式はttlstore.DynamoDBと同じで、親メッセージのタイムスタンプを"ts"に記録します
+type DynamoDBStore ttlstore.DynamoDB
+
+func (s *DynamoDBStore) Get(ctx context.Context, key string) (string, error) {
+	item, err := (*ttlstore.DynamoDB)(s).Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return item["ts"].S, nil
+}
+
+func (s *DynamoDBStore) Put(ctx context.Context, key string, ts string, ttl time.Duration) error {
+	return (*ttlstore.DynamoDB)(s).Put(ctx, key, ttlstore.Item{"ts": {S: ts}}, ttl)
+}
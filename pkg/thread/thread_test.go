@@ -0,0 +1,112 @@
+package thread
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+	"github.com/tomozo6/cwl2slack/pkg/ttlstore"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if ts, _ := s.Get(ctx, "k"); ts != "" {
+		t.Fatalf("unexpected ts before put: %s", ts)
+	}
+
+	s.Put(ctx, "k", "1.1", time.Hour)
+	s.Put(ctx, "k", "2.2", time.Hour)
+	// 上書きした親メッセージを返す
+	if ts, _ := s.Get(ctx, "k"); ts != "2.2" {
+		t.Fatalf("unexpected ts: %s", ts)
+	}
+
+	// 期限が切れた場合は記録が無いものとする
+	now = now.Add(2 * time.Hour)
+	if ts, _ := s.Get(ctx, "k"); ts != "" {
+		t.Fatalf("unexpected ts after ttl: %s", ts)
+	}
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := map[string]map[string]ttlstore.Attribute{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("request is not signed")
+		}
+		b, _ := io.ReadAll(r.Body)
+		var in struct {
+			TableName string
+			Key       map[string]ttlstore.Attribute
+			Item      map[string]ttlstore.Attribute
+		}
+		json.Unmarshal(b, &in)
+		if in.TableName != "cwl2slack-threads" {
+			t.Errorf("unexpected table: %s", in.TableName)
+		}
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.GetItem":
+			item, ok := items[in.Key["key"].S]
+			if !ok {
+				w.Write([]byte(`{}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"Item": item})
+		case "DynamoDB_20120810.PutItem":
+			items[in.Item["key"].S] = in.Item
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"UnknownOperationException"}`))
+		}
+	}))
+	defer ts.Close()
+
+	c := awsapi.NewClient("ap-northeast-1")
+	c.Credentials = awsapi.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	c.Endpoint = ts.URL
+	s := &DynamoDBStore{Client: c, Table: "cwl2slack-threads", Now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	testCases := []struct {
+		name    string
+		put     string
+		advance time.Duration
+		want    string
+	}{
+		{name: "[正常系]記録が無い場合", want: ""},
+		{name: "[正常系]記録した場合", put: "1716792813.000100", want: "1716792813.000100"},
+		{name: "[正常系]期限が切れた場合", advance: 2 * time.Hour, want: ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.put != "" {
+				if err := s.Put(ctx, "k", tt.put, time.Hour); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			now = now.Add(tt.advance)
+
+			got, err := s.Get(ctx, "k")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package ttlstore
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+)
+
+// Memoryはメモリにキーごとの値を期限付きで記録します。ゼロ値で使えます。
+// Lambdaの同じ実行環境でのみ記録を共有できます。テストでも使います
+type Memory[V any] struct {
+	mu      sync.Mutex
+	entries map[string]entry[V]
+
+	// 現在時刻を返す関数です。nilの場合はtime.Nowを使います
+	Now func() time.Time
+}
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func (m *Memory[V]) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+// lookupは期限が切れていない値を返します。期限が切れた値は削除します。呼び出し元でロックします
+func (m *Memory[V]) lookup(key string) (V, bool) {
+	e, ok := m.entries[key]
+	if ok && !m.now().Before(e.expires) {
+		delete(m.entries, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, ok
+}
+
+// Getはキーに記録されている値を返します。記録が無いか期限が切れている場合はfalseです
+func (m *Memory[V]) Get(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lookup(key)
+}
+
+// Putは値をttlの間記録します。記録がある場合は上書きします
+func (m *Memory[V]) Put(key string, v V, ttl time.Duration) {
+	m.Update(key, ttl, func(V) V { return v })
+}
+
+// Updateは記録されている値をfで更新し、ttlの間記録します。記録が無い場合はゼロ値を渡します
+func (m *Memory[V]) Update(key string, ttl time.Duration, f func(V) V) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = map[string]entry[V]{}
+	}
+	v, _ := m.lookup(key)
+	m.entries[key] = entry[V]{value: f(v), expires: m.now().Add(ttl)}
+}
+
+// Takeはキーに記録されている値を返し、記録を削除します
+func (m *Memory[V]) Take(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v, ok := m.lookup(key)
+	delete(m.entries, key)
+	return v, ok
+}
+
+// AttributeはDynamoDBの属性の値です
+type Attribute struct {
+	S  string   `json:"S,omitempty"`
+	N  string   `json:"N,omitempty"`
+	SS []string `json:"SS,omitempty"`
+}
+
+// Itemはキーと期限以外の属性です
+type Item map[string]Attribute
+
+// DynamoDBはDynamoDBのテーブルにキーごとの属性を期限付きで記録します。
+// テーブルのパーティションキーは文字列の"key"で、"expires_at"(Unix秒)をTTLの属性に設定します。
+// DynamoDBのTTLによる削除は遅れることがあるため、読み込み時にも期限を確認します
+type DynamoDB struct {
+	Client *awsapi.Client
+	Table  string
+
+	// 現在時刻を返す関数です。nilの場合はtime.Nowを使います
+	Now func() time.Time
+}
+
+func (d *DynamoDB) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+func (d *DynamoDB) expiresAt(ttl time.Duration) Attribute {
+	return Attribute{N: strconv.FormatInt(d.now().Add(ttl).Unix(), 10)}
+}
+
+// expiredは属性の期限が切れているかどうかを返します。期限が読めない場合は切れていないとみなします
+func (d *DynamoDB) expired(item map[string]Attribute) bool {
+	expires, err := strconv.ParseInt(item["expires_at"].N, 10, 64)
+	return err == nil && d.now().Unix() >= expires
+}
+
+// Getはキーに記録されている属性を返します。記録が無いか期限が切れている場合はnilです
+func (d *DynamoDB) Get(ctx context.Context, key string) (Item, error) {
+	in := map[string]any{
+		"TableName":      d.Table,
+		"Key":            map[string]Attribute{"key": {S: key}},
+		"ConsistentRead": true,
+	}
+	var out struct {
+		Item map[string]Attribute `json:"Item"`
+	}
+	if err := d.Client.JSON(ctx, "dynamodb", "DynamoDB_20120810.GetItem", in, &out); err != nil {
+		return nil, err
+	}
+	if out.Item == nil || d.expired(out.Item) {
+		return nil, nil
+	}
+	return out.Item, nil
+}
+
+// Putは属性をttlの間記録します。記録がある場合は上書きします
+func (d *DynamoDB) Put(ctx context.Context, key string, item Item, ttl time.Duration) error {
+	attrs := map[string]Attribute{"key": {S: key}, "expires_at": d.expiresAt(ttl)}
+	for name, v := range item {
+		attrs[name] = v
+	}
+	in := map[string]any{
+		"TableName": d.Table,
+		"Item":      attrs,
+	}
+	return d.Client.JSON(ctx, "dynamodb", "DynamoDB_20120810.PutItem", in, nil)
+}
+
+// Addは数値の属性に値を加算し、文字列セットの属性に値を追加して、記録をttlの間保持します。
+// 複数のLambdaから同時に呼び出されても値が失われないように、UpdateItemのADDを使います
+func (d *DynamoDB) Add(ctx context.Context, key string, item Item, ttl time.Duration) error {
+	names := map[string]string{"#expires": "expires_at"}
+	values := map[string]Attribute{":expires": d.expiresAt(ttl)}
+
+	// 式が毎回同じになるように、属性の名前の順に並べます
+	attrs := make([]string, 0, len(item))
+	for name := range item {
+		attrs = append(attrs, name)
+	}
+	slices.Sort(attrs)
+
+	var expr string
+	for i, name := range attrs {
+		// 空の文字列セットは指定できません
+		v := item[name]
+		if v.N == "" && len(v.SS) == 0 {
+			continue
+		}
+		if expr == "" {
+			expr = "ADD "
+		} else {
+			expr += ", "
+		}
+		p := strconv.Itoa(i)
+		expr += "#a" + p + " :a" + p
+		names["#a"+p] = name
+		values[":a"+p] = v
+	}
+	if expr != "" {
+		expr += " "
+	}
+	expr += "SET #expires = :expires"
+
+	in := map[string]any{
+		"TableName":                 d.Table,
+		"Key":                       map[string]Attribute{"key": {S: key}},
+		"UpdateExpression":          expr,
+		"ExpressionAttributeNames":  names,
+		"ExpressionAttributeValues": values,
+	}
+	return d.Client.JSON(ctx, "dynamodb", "DynamoDB_20120810.UpdateItem", in, nil)
+}
+
+// Takeはキーに記録されている属性を返し、記録を削除します。記録が無いか期限が切れている場合はnilです。
+// DeleteItemで削除前の値を返すため、複数のLambdaから同時に呼び出されても同じ値を2回返しません
+func (d *DynamoDB) Take(ctx context.Context, key string) (Item, error) {
+	in := map[string]any{
+		"TableName":    d.Table,
+		"Key":          map[string]Attribute{"key": {S: key}},
+		"ReturnValues": "ALL_OLD",
+	}
+	var out struct {
+		Attributes map[string]Attribute `json:"Attributes"`
+	}
+	if err := d.Client.JSON(ctx, "dynamodb", "DynamoDB_20120810.DeleteItem", in, &out); err != nil {
+		return nil, err
+	}
+	if out.Attributes == nil || d.expired(out.Attributes) {
+		return nil, nil
+	}
+	return out.Attributes, nil
+}
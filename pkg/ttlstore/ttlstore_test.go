@@ -0,0 +1,190 @@
+package ttlstore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/awsapi"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var m Memory[int]
+	m.Now = func() time.Time { return now }
+
+	if _, ok := m.Get("k"); ok {
+		t.Fatalf("unexpected value before put")
+	}
+
+	m.Put("k", 1, time.Hour)
+	m.Update("k", time.Hour, func(v int) int { return v + 2 })
+	if got, ok := m.Get("k"); !ok || got != 3 {
+		t.Fatalf("got: %d, %v", got, ok)
+	}
+
+	// Takeは1回だけ値を返します
+	if got, ok := m.Take("k"); !ok || got != 3 {
+		t.Fatalf("got: %d, %v", got, ok)
+	}
+	if _, ok := m.Take("k"); ok {
+		t.Fatalf("unexpected value after take")
+	}
+
+	// 期限が切れた値は返さず、更新する場合はゼロ値から始めます
+	m.Put("k", 5, time.Hour)
+	now = now.Add(time.Hour)
+	if _, ok := m.Get("k"); ok {
+		t.Fatalf("unexpected value after ttl")
+	}
+	m.Put("k", 5, time.Hour)
+	now = now.Add(time.Hour)
+	m.Update("k", time.Hour, func(v int) int { return v + 1 })
+	if got, _ := m.Get("k"); got != 1 {
+		t.Fatalf("got: %d, want: 1", got)
+	}
+}
+
+// fakeDynamoDBはGetItem、PutItem、UpdateItemのADDとSET、DeleteItemのReturnValuesを再現するサーバーです
+type fakeDynamoDB struct {
+	t     *testing.T
+	items map[string]map[string]Attribute
+}
+
+func (f *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		f.t.Errorf("request is not signed")
+	}
+	b, _ := io.ReadAll(r.Body)
+	var in struct {
+		TableName                 string
+		Key                       map[string]Attribute
+		Item                      map[string]Attribute
+		ConsistentRead            bool
+		UpdateExpression          string
+		ExpressionAttributeNames  map[string]string
+		ExpressionAttributeValues map[string]Attribute
+		ReturnValues              string
+	}
+	json.Unmarshal(b, &in)
+	if in.TableName != "cwl2slack" {
+		f.t.Errorf("unexpected table: %s", in.TableName)
+	}
+	key := in.Key["key"].S
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "DynamoDB_20120810.GetItem":
+		if !in.ConsistentRead {
+			f.t.Errorf("expected consistent read")
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Item": f.items[key]})
+	case "DynamoDB_20120810.PutItem":
+		f.items[in.Item["key"].S] = in.Item
+		w.Write([]byte(`{}`))
+	case "DynamoDB_20120810.UpdateItem":
+		item, ok := f.items[key]
+		if !ok {
+			item = map[string]Attribute{"key": {S: key}}
+		}
+		adds, sets, _ := strings.Cut(strings.TrimPrefix(in.UpdateExpression, "ADD "), "SET ")
+		for _, a := range strings.Split(strings.TrimSpace(adds), ", ") {
+			name, value, ok := strings.Cut(a, " ")
+			if !ok {
+				continue
+			}
+			attr, v := in.ExpressionAttributeNames[name], in.ExpressionAttributeValues[value]
+			if v.N != "" {
+				cur, _ := strconv.Atoi(item[attr].N)
+				n, _ := strconv.Atoi(v.N)
+				item[attr] = Attribute{N: strconv.Itoa(cur + n)}
+			} else {
+				ss := item[attr].SS
+				for _, s := range v.SS {
+					if !slices.Contains(ss, s) {
+						ss = append(ss, s)
+					}
+				}
+				item[attr] = Attribute{SS: ss}
+			}
+		}
+		name, value, _ := strings.Cut(sets, " = ")
+		item[in.ExpressionAttributeNames[name]] = in.ExpressionAttributeValues[value]
+		f.items[key] = item
+		w.Write([]byte(`{}`))
+	case "DynamoDB_20120810.DeleteItem":
+		item, ok := f.items[key]
+		delete(f.items, key)
+		if !ok || in.ReturnValues != "ALL_OLD" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Attributes": item})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"UnknownOperationException"}`))
+	}
+}
+
+func TestDynamoDB(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(&fakeDynamoDB{t: t, items: map[string]map[string]Attribute{}})
+	defer ts.Close()
+
+	c := awsapi.NewClient("ap-northeast-1")
+	c.Credentials = awsapi.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	c.Endpoint = ts.URL
+	d := &DynamoDB{Client: c, Table: "cwl2slack", Now: func() time.Time { return now }}
+
+	// 正常系のテストケース
+	if got, err := d.Get(ctx, "k"); err != nil || got != nil {
+		t.Fatalf("got: %v, %v", got, err)
+	}
+	if err := d.Put(ctx, "k", Item{"ts": {S: "1716792813.000100"}}, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := d.Get(ctx, "k"); err != nil || got["ts"].S != "1716792813.000100" {
+		t.Fatalf("got: %v, %v", got, err)
+	}
+
+	d.Add(ctx, "n", Item{"events": {N: "2"}, "modes": {SS: []string{"plain"}}}, time.Hour)
+	d.Add(ctx, "n", Item{"events": {N: "1"}, "modes": {SS: []string{"plain", "slowquery"}}, "log_groups": {}}, time.Hour)
+	got, err := d.Take(ctx, "n")
+	if err != nil || got["events"].N != "3" || strings.Join(got["modes"].SS, ",") != "plain,slowquery" {
+		t.Fatalf("got: %v, %v", got, err)
+	}
+	if got, _ := d.Take(ctx, "n"); got != nil {
+		t.Fatalf("unexpected item after take: %v", got)
+	}
+
+	// 期限が切れた記録は返さない
+	d.Add(ctx, "n", Item{"events": {N: "1"}}, time.Hour)
+	now = now.Add(2 * time.Hour)
+	if got, _ := d.Get(ctx, "k"); got != nil {
+		t.Fatalf("unexpected item after ttl: %v", got)
+	}
+	if got, _ := d.Take(ctx, "n"); got != nil {
+		t.Fatalf("unexpected item after ttl: %v", got)
+	}
+
+	// 異常系のテストケース
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ResourceNotFoundException"}`))
+	}))
+	defer es.Close()
+	c.Endpoint = es.URL
+	if _, err := d.Get(ctx, "k"); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+	if _, err := d.Take(ctx, "k"); err == nil {
+		t.Fatalf("expected error, but got nil")
+	}
+}
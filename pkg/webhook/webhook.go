@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+)
+
+// DefaultSignatureHeaderは署名を設定するヘッダーの既定の名前です
+const DefaultSignatureHeader = "X-Cwl2slack-Signature"
+
+// TimestampHeaderは署名した時刻(Unix秒)を設定するヘッダーです
+const TimestampHeader = "X-Cwl2slack-Timestamp"
+
+// Eventはテンプレートに渡す通知の内容です。テキストはSlackのエスケープを元に戻したものです
+type Event struct {
+	Title       string         `json:"title"`
+	Text        string         `json:"text"`
+	Severity    string         `json:"severity,omitempty"`
+	Fingerprint string         `json:"fingerprint,omitempty"`
+	Source      string         `json:"source,omitempty"`
+	Timestamp   time.Time      `json:"timestamp"`
+	Fields      []Field        `json:"fields,omitempty"`
+	Links       []Link         `json:"links,omitempty"`
+	Details     map[string]any `json:"details,omitempty"`
+}
+
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type Link struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// Webhookは任意のHTTPエンドポイントに通知をJSONで送信します。
+// Templateが設定されている場合はEventに適用した結果を、設定されていない場合はEventをそのままJSONにして送信します。
+// Secretが設定されている場合は、"時刻.本文"のHMAC-SHA256を"sha256=<16進数>"の形式で署名のヘッダーに設定します
+type Webhook struct {
+	URL             string
+	Template        *template.Template
+	Header          http.Header
+	Secret          string
+	SignatureHeader string
+
+	// 署名する時刻を返す関数です。テストで差し替えるために使います
+	now func() time.Time
+}
+
+// ParseTemplateはJSONの本文のテンプレートを解析します。
+// テンプレートでは値をJSONの文字列などに変換するjson関数を使えます
+//
+//	{"title": {{json .Title}}, "severity": {{json .Severity}}, "details": {{json .Details}}}
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(text)
+}
+
+// Notifyは通知を本文に変換して送信します
+func (w *Webhook) Notify(ctx context.Context, n notifier.Notification) error {
+	body, err := w.Body(NewEvent(n))
+	if err != nil {
+		return err
+	}
+
+	header := w.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if w.Secret != "" {
+		now := time.Now
+		if w.now != nil {
+			now = w.now
+		}
+		timestamp := strconv.FormatInt(now().Unix(), 10)
+
+		name := w.SignatureHeader
+		if name == "" {
+			name = DefaultSignatureHeader
+		}
+		header.Set(TimestampHeader, timestamp)
+		header.Set(name, Sign(w.Secret, timestamp, body))
+	}
+
+	return notifier.Post(ctx, w.URL, header, body)
+}
+
+// BodyはEventを送信する本文にします。テンプレートの結果がJSONとして正しくない場合はエラーを返します
+func (w *Webhook) Body(e Event) ([]byte, error) {
+	if w.Template == nil {
+		return marshal(e)
+	}
+
+	var buf bytes.Buffer
+	if err := w.Template.Execute(&buf, e); err != nil {
+		return nil, fmt.Errorf("failed to execute webhook template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not produce valid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// Signは受信側で検証するための署名を返します。
+// 受信側は同じ秘密鍵でTimestampHeaderの値と本文から署名を計算し、hmac.Equalで比較します
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewEventは通知をテンプレートに渡すEventに変換します
+func NewEvent(n notifier.Notification) Event {
+	e := Event{
+		Text:        notifier.PlainText(n.Payload),
+		Severity:    string(n.Severity),
+		Fingerprint: n.Fingerprint,
+		Source:      n.Source,
+		Details:     n.Details,
+	}
+
+	for _, a := range n.Payload.Attachments {
+		if e.Title == "" {
+			e.Title = notifier.Text(a.Title)
+		}
+		if e.Timestamp.IsZero() && a.Timestamp != 0 {
+			e.Timestamp = time.Unix(a.Timestamp, 0).UTC()
+		}
+		for _, f := range a.Fields {
+			v, ok := notifier.Code(f.Value)
+			if !ok {
+				v = notifier.Text(f.Value)
+			}
+			e.Fields = append(e.Fields, Field{Title: notifier.Text(f.Title), Value: v})
+		}
+		for _, act := range a.Actions {
+			if act.Url != "" {
+				e.Links = append(e.Links, Link{Text: act.Text, URL: act.Url})
+			}
+		}
+	}
+	if e.Title == "" {
+		e.Title = notifier.Text(n.Payload.Text)
+	}
+
+	return e
+}
+
+// toJSONはテンプレートのjson関数です。受信側で読みやすいように<や&はエスケープしません
+func toJSON(v any) (string, error) {
+	b, err := marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// marshalはHTMLの文字をエスケープせずにvをJSONにします
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tomozo6/cwl2slack/pkg/notifier"
+	"github.com/tomozo6/cwl2slack/pkg/slack"
+)
+
+var testNotification = notifier.Notification{
+	Payload: slack.Payload{
+		Attachments: []slack.Attachment{
+			{
+				Title:     ":rotating_light:ロググループ &lt;test&gt; にてアラートを検知しました",
+				Timestamp: 1716792813,
+				Fields: []slack.Field{
+					{Title: "Log Group", Value: "/aws/test"},
+					{Title: "Log Messages", Value: slack.CodeBlock(`say "hi" & <bye>`)},
+				},
+				Actions: []slack.Action{{Text: "Open in CloudWatch", Url: "https://example.com"}},
+			},
+		},
+	},
+	Severity:    notifier.SeverityCritical,
+	Fingerprint: "abc123",
+	Source:      "/aws/test",
+	Details:     map[string]any{"log_messages": []string{`say "hi" & <bye>`}},
+}
+
+func TestNewEvent(t *testing.T) {
+	got := NewEvent(testNotification)
+
+	want := Event{
+		Title:       "🚨ロググループ <test> にてアラートを検知しました",
+		Text:        "🚨ロググループ <test> にてアラートを検知しました\nLog Group: /aws/test\nLog Messages:\nsay \"hi\" & <bye>\nOpen in CloudWatch: https://example.com",
+		Severity:    "critical",
+		Fingerprint: "abc123",
+		Source:      "/aws/test",
+		Timestamp:   time.Date(2024, 5, 27, 6, 53, 33, 0, time.UTC),
+		Fields:      []Field{{Title: "Log Group", Value: "/aws/test"}, {Title: "Log Messages", Value: `say "hi" & <bye>`}},
+		Links:       []Link{{Text: "Open in CloudWatch", URL: "https://example.com"}},
+		Details:     map[string]any{"log_messages": []string{`say "hi" & <bye>`}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n got: %+v;\nwant: %+v", got, want)
+	}
+}
+
+func TestBody(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		isNormal bool
+		want     string
+	}{
+		{
+			name:     "[正常系]テンプレート",
+			template: `{"summary": {{json .Title}}, "level": {{json .Severity}}, "message": {{json (index .Fields 1).Value}}}`,
+			isNormal: true,
+			want:     `{"summary": "🚨ロググループ <test> にてアラートを検知しました", "level": "critical", "message": "say \"hi\" & <bye>"}`,
+		},
+		{
+			name:     "[正常系]テンプレートを指定しない場合",
+			isNormal: true,
+		},
+		{
+			name:     "[異常系]JSONにならないテンプレート",
+			template: `{"summary": {{.Title}}}`,
+			isNormal: false,
+		},
+		{
+			name:     "[異常系]存在しない項目",
+			template: `{"summary": {{json .Unknown}}}`,
+			isNormal: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Webhook{}
+			if tt.template != "" {
+				tmpl, err := ParseTemplate(tt.template)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				w.Template = tmpl
+			}
+
+			got, err := w.Body(NewEvent(testNotification))
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if tt.want != "" && string(got) != tt.want {
+					t.Fatalf("\n got: %s;\nwant: %s", got, tt.want)
+				}
+				if !json.Valid(got) {
+					t.Fatalf("invalid JSON: %s", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1716792813.{"a":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=24f8e7fc2ebb5ab8eefcd1dff86a4381dd4ddd578306c1bca3fab9932ae389b7"
+	if got := Sign("secret", "1716792813", []byte(`{"a":1}`)); got != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		secret   string
+		status   int
+		isNormal bool
+	}{
+		{name: "[正常系]署名なし", status: http.StatusOK, isNormal: true},
+		{name: "[正常系]署名あり", secret: "secret", status: http.StatusNoContent, isNormal: true},
+		{name: "[異常系]エラーが返された場合", status: http.StatusInternalServerError, isNormal: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				header = r.Header
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			w := Webhook{
+				URL:    server.URL,
+				Header: http.Header{"Authorization": []string{"Bearer token"}},
+				Secret: tt.secret,
+				now:    func() time.Time { return time.Unix(1716792813, 0) },
+			}
+			err := w.Notify(context.Background(), testNotification)
+
+			// 正常系のテストケース
+			if tt.isNormal {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if header.Get("Authorization") != "Bearer token" || header.Get("Content-Type") != "application/json" {
+					t.Fatalf("unexpected header: %v", header)
+				}
+				wantSignature := ""
+				if tt.secret != "" {
+					wantSignature = Sign(tt.secret, "1716792813", body)
+				}
+				if got := header.Get(DefaultSignatureHeader); got != wantSignature {
+					t.Fatalf("unexpected signature: %s", got)
+				}
+				// 異常系のテストケース
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, but got nil")
+				}
+			}
+		})
+	}
+}